@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"ipm/pkg/installer"
+	"ipm/pkg/keyring"
+	"ipm/pkg/log"
+	"ipm/pkg/registry"
+
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit the installed packages",
+}
+
+var auditSignaturesCmd = &cobra.Command{
+	Use:   "signatures",
+	Short: "Verify registry signatures and provenance attestations of the locked packages",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		reg := registry.NewNPMRegistry(registryURL, registryToken)
+		inst := installer.NewInstaller(reg)
+		trust, err := loadRegistryTrust(cmd, reg)
+		if err != nil {
+			exitKeys("Failed to load registry keys", err)
+		}
+		inst.RegistryTrust = trust
+		ok, err := inst.AuditSignatures(reg, ".")
+		if err != nil {
+			exitKeys("Failed to audit signatures", err)
+		}
+		inst.ReportRegistry(jsonOutput)
+		if !ok {
+			log.Error("Signature audit failed", fmt.Errorf("packages with missing or invalid registry signatures"))
+			os.Exit(1)
+		}
+		log.Info("Registry signatures verified")
+	},
+}
+
+// loadRegistryTrust lädt die Schlüssel der Registry nach den Flags --registry-keys,
+// --trusted-root, --offline und --refresh-keys.
+func loadRegistryTrust(cmd *cobra.Command, reg *registry.NPMRegistry) (*keyring.RegistryTrust, error) {
+	var opts keyring.RegistryOptions
+	opts.KeysFile, _ = cmd.Flags().GetString("registry-keys")
+	opts.RootsFile, _ = cmd.Flags().GetString("trusted-root")
+	opts.Offline, _ = cmd.Flags().GetBool("offline")
+	opts.Refresh, _ = cmd.Flags().GetBool("refresh-keys")
+	return keyring.LoadRegistryTrust(registryURL, opts, reg.FetchKeys)
+}
+
+// addRegistryTrustFlags registriert die Flags, die loadRegistryTrust liest.
+func addRegistryTrustFlags(cmd *cobra.Command) {
+	cmd.Flags().String("registry-keys", "", "Registry keys file in the format of /-/npm/v1/keys (default: stored in ~/.ipm/keys/registries)")
+	cmd.Flags().String("trusted-root", "", "PEM file with root certificates for provenance certificates (default: ~/.ipm/keys/registries/"+keyring.RootsFile+" if present)")
+	cmd.Flags().Bool("offline", false, "Use only locally stored registry keys and attestation bundles")
+	cmd.Flags().Bool("refresh-keys", false, "Fetch the registry keys again even if they are stored")
+}
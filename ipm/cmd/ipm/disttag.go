@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"ipm/pkg/log"
+	"ipm/pkg/registry"
+
+	"github.com/spf13/cobra"
+)
+
+var distTagCmd = &cobra.Command{
+	Use:   "dist-tag",
+	Short: "Manage distribution tags of a package",
+}
+
+var distTagAddCmd = &cobra.Command{
+	Use:   "add <package>@<version> <tag>",
+	Short: "Point a dist-tag at a package version",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		name, version := splitPackageSpec(args[0])
+		if version == "" {
+			fmt.Fprintln(os.Stderr, "Error: a version is required (<package>@<version>)")
+			os.Exit(1)
+		}
+		reg := registry.NewNPMRegistry(registryURL, registryToken)
+		if err := reg.AddDistTag(name, args[1], version); err != nil {
+			log.Error("Failed to add dist-tag", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("+%s: %s@%s\n", args[1], name, version)
+	},
+}
+
+var distTagRmCmd = &cobra.Command{
+	Use:   "rm <package> <tag>",
+	Short: "Remove a dist-tag from a package",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		name, _ := splitPackageSpec(args[0])
+		reg := registry.NewNPMRegistry(registryURL, registryToken)
+		if err := reg.RemoveDistTag(name, args[1]); err != nil {
+			log.Error("Failed to remove dist-tag", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("-%s: %s\n", args[1], name)
+	},
+}
+
+var distTagLsCmd = &cobra.Command{
+	Use:   "ls <package>",
+	Short: "List the dist-tags of a package",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		name, _ := splitPackageSpec(args[0])
+		reg := registry.NewNPMRegistry(registryURL, registryToken)
+		tags, err := reg.DistTags(name)
+		if err != nil {
+			log.Error("Failed to list dist-tags", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		names := make([]string, 0, len(tags))
+		for tag := range tags {
+			names = append(names, tag)
+		}
+		sort.Strings(names)
+		for _, tag := range names {
+			fmt.Printf("%s: %s\n", tag, tags[tag])
+		}
+	},
+}
+
+var deprecateCmd = &cobra.Command{
+	Use:   "deprecate <package>[@<range>] <message>",
+	Short: "Deprecate versions of a package (an empty message un-deprecates)",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		name, versionRange := splitPackageSpec(args[0])
+		if versionRange == "" {
+			versionRange = "*"
+		}
+		reg := registry.NewNPMRegistry(registryURL, registryToken)
+		versions, err := reg.Deprecate(name, versionRange, args[1])
+		if err != nil {
+			log.Error("Failed to deprecate package", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, v := range versions {
+			if args[1] == "" {
+				fmt.Printf("Un-deprecated %s@%s\n", name, v)
+			} else {
+				fmt.Printf("Deprecated %s@%s\n", name, v)
+			}
+		}
+	},
+}
+
+// splitPackageSpec trennt "name@range" und berücksichtigt Scoped-Namen wie "@acme/plc@1.0.0".
+func splitPackageSpec(spec string) (name, version string) {
+	if idx := strings.LastIndex(spec, "@"); idx > 0 {
+		return spec[:idx], spec[idx+1:]
+	}
+	return spec, ""
+}
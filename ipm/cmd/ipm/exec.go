@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"ipm/pkg/cache"
+	"ipm/pkg/installer"
+	"ipm/pkg/keyring"
+	"ipm/pkg/log"
+	"ipm/pkg/project"
+	"ipm/pkg/registry"
+	"ipm/pkg/runner"
+	"ipm/pkg/semver"
+	"ipm/pkg/shell"
+
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <package>[@<version>] [-- args...]",
+	Short: "Run a bin of a package without adding it to the project",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		binName, _ := cmd.Flags().GetString("bin")
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		name, version := splitPackageSpec(args[0])
+
+		prefix, err := execPrefix(name, version)
+		if err != nil {
+			log.Error("Failed to install package for exec", err, map[string]interface{}{
+				"package": args[0],
+			})
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(execBin(prefix, name, binName, args[1:]))
+	},
+}
+
+// execPrefix liefert das Verzeichnis, dessen node_modules das Paket enthält: das
+// Projekt, wenn es das Paket in passender Version als Abhängigkeit hat, sonst ein
+// Präfix im Cache je Paketangabe. Dorthin wird über den Solver installiert, ohne
+// package.json oder Lockfile des Projekts anzufassen.
+func execPrefix(name, version string) (string, error) {
+	manifest, err := project.LoadManifest(".")
+	if err != nil {
+		return "", err
+	}
+	if manifest != nil {
+		if _, ok := manifest.AllDependencies()[name]; ok {
+			installed, err := project.LoadManifest(filepath.Join("node_modules", name))
+			if err == nil && installed != nil && (version == "" || semver.Satisfies(installed.Version, version)) {
+				log.Debug("Using project dependency", map[string]interface{}{
+					"package": name,
+					"version": installed.Version,
+				})
+				return ".", nil
+			}
+		}
+	}
+
+	c, err := cache.NewCache()
+	if err != nil {
+		return "", fmt.Errorf("failed to open cache: %v", err)
+	}
+	spec := name
+	if version != "" {
+		spec += "@" + version
+	}
+	sum := sha256.Sum256([]byte(spec))
+	prefix := filepath.Join(c.CacheDir, "_exec", hex.EncodeToString(sum[:8]))
+	if err := os.MkdirAll(prefix, 0755); err != nil {
+		return "", fmt.Errorf("failed to create exec prefix: %v", err)
+	}
+
+	reg := registry.NewNPMRegistry(registryURL, registryToken)
+	inst := installer.NewInstaller(reg)
+	inst.Prefix = prefix
+	inst.ScriptConfig = scriptConfig()
+	policy, err := project.LoadScriptPolicy(".")
+	if err != nil {
+		return "", err
+	}
+	inst.ScriptPolicy = policy
+	if inst.Keyring, err = keyring.Load("."); err != nil {
+		return "", err
+	}
+	if inst.SignaturePolicy, err = keyring.LoadPolicy("."); err != nil {
+		return "", err
+	}
+
+	// Installationsmeldungen nach stderr, damit stdout dem Programm gehört
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+	log.Info("Installing package for exec", map[string]interface{}{
+		"package": spec,
+		"prefix":  prefix,
+	})
+	if err := inst.Install(reg, spec, false, ""); err != nil {
+		return "", err
+	}
+	err = inst.RunLifecycleScripts(prefix, false)
+	inst.Report(false)
+	if err != nil {
+		return "", err
+	}
+	return prefix, nil
+}
+
+// execBin startet ein bin des Pakets mit der Umgebung von "ipm run" im aktuellen
+// Verzeichnis und liefert den Exit-Code.
+func execBin(prefix, name, binName string, args []string) int {
+	pkgDir := filepath.Join(prefix, "node_modules", name)
+	bins, err := cache.Bins(pkgDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if binName == "" {
+		binName, err = defaultBin(name, bins)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	} else if _, ok := bins[binName]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: %s has no bin named %s\n", name, binName)
+		return 1
+	}
+
+	manifest, err := project.LoadManifest(".")
+	if err != nil || manifest == nil {
+		manifest = &project.Manifest{}
+	}
+	command := runner.QuoteArgs(binName, args)
+	log.Debug("Running package bin", map[string]interface{}{
+		"package": name,
+		"bin":     binName,
+		"command": command,
+	})
+	// Das bin-Verzeichnis des Präfixes steht vor dem des Projekts im PATH
+	env := runner.Env(manifest, prefix, ".", "exec", command, scriptConfig())
+	err = runner.Exec(".", command, env, os.Stdout, os.Stderr)
+	if err == nil {
+		return 0
+	}
+	var exitErr *shell.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	log.Error("Exec failed", err, map[string]interface{}{
+		"package": name,
+		"bin":     binName,
+	})
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return 1
+}
+
+// defaultBin wählt das einzige bin eines Pakets oder das nach dem Paket benannte.
+func defaultBin(name string, bins map[string]string) (string, error) {
+	if len(bins) == 1 {
+		for bin := range bins {
+			return bin, nil
+		}
+	}
+	if _, ok := bins[path.Base(name)]; ok {
+		return path.Base(name), nil
+	}
+	if len(bins) == 0 {
+		return "", fmt.Errorf("%s has no bin entries", name)
+	}
+	names := make([]string, 0, len(bins))
+	for bin := range bins {
+		names = append(names, bin)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("%s has several bins (%s); choose one with --bin", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ipm/pkg/log"
+	"ipm/pkg/signing"
+
+	"github.com/spf13/cobra"
+)
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a key pair for signing packages",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		keyType, _ := cmd.Flags().GetString("type")
+		outDir, _ := cmd.Flags().GetString("out")
+		name, _ := cmd.Flags().GetString("name")
+		bits, _ := cmd.Flags().GetInt("bits")
+		curve, _ := cmd.Flags().GetString("curve")
+		encrypt, _ := cmd.Flags().GetBool("encrypt")
+		passphraseFile, _ := cmd.Flags().GetString("passphrase-file")
+		force, _ := cmd.Flags().GetBool("force")
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		if name == "" {
+			name = keyType
+		}
+
+		// Mit --passphrase-file wird immer verschlüsselt, mit --encrypt auch über IPM_KEY_PASSPHRASE
+		var passphrase []byte
+		if encrypt || passphraseFile != "" {
+			var err error
+			if passphrase, err = readPassphrase(passphraseFile); err != nil {
+				exitKeys("Failed to read passphrase", err)
+			}
+			if passphrase == nil {
+				exitKeys("Missing passphrase", fmt.Errorf("--encrypt needs a passphrase: use --passphrase-file or set %s", passphraseEnv))
+			}
+			if len(passphrase) == 0 {
+				exitKeys("Missing passphrase", fmt.Errorf("passphrase is empty"))
+			}
+		}
+
+		privFile := filepath.Join(outDir, name+".pem")
+		pubFile := filepath.Join(outDir, name+".pub")
+		if !force {
+			for _, file := range []string{privFile, pubFile} {
+				if _, err := os.Stat(file); err == nil {
+					exitKeys("Key file exists", fmt.Errorf("%s already exists (use --force to overwrite)", file))
+				}
+			}
+		}
+
+		key, err := signing.GenerateKey(keyType, bits, curve)
+		if err != nil {
+			exitKeys("Failed to generate key", err)
+		}
+		privPEM, err := signing.MarshalPrivateKey(key, passphrase)
+		if err != nil {
+			exitKeys("Failed to encode private key", err)
+		}
+		pubPEM, err := signing.MarshalPublicKey(key.Public())
+		if err != nil {
+			exitKeys("Failed to encode public key", err)
+		}
+		keyID, err := signing.KeyID(key.Public())
+		if err != nil {
+			exitKeys("Failed to compute key ID", err)
+		}
+		algorithm, err := signing.DefaultAlgorithm(key.Public())
+		if err != nil {
+			exitKeys("Unsupported key", err)
+		}
+
+		if err := os.MkdirAll(outDir, 0700); err != nil {
+			exitKeys("Failed to create output directory", fmt.Errorf("failed to create output directory: %v", err))
+		}
+		if err := writeKeyFile(privFile, privPEM, 0600); err != nil {
+			exitKeys("Failed to write private key", err)
+		}
+		if err := writeKeyFile(pubFile, pubPEM, 0644); err != nil {
+			exitKeys("Failed to write public key", err)
+		}
+		log.Info("Key pair generated", map[string]interface{}{
+			"privateKey": privFile,
+			"publicKey":  pubFile,
+			"keyId":      keyID,
+			"algorithm":  algorithm,
+			"encrypted":  passphrase != nil,
+		})
+
+		if jsonOutput {
+			data, _ := json.MarshalIndent(map[string]interface{}{
+				"privateKey": privFile,
+				"publicKey":  pubFile,
+				"keyId":      keyID,
+				"algorithm":  algorithm,
+				"encrypted":  passphrase != nil,
+			}, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+		encrypted := ""
+		if passphrase != nil {
+			encrypted = " (encrypted)"
+		}
+		fmt.Printf("Generated %s key pair\n", keyType)
+		fmt.Printf("  Private key: %s%s\n", privFile, encrypted)
+		fmt.Printf("  Public key:  %s\n", pubFile)
+		fmt.Printf("  Key ID:      %s\n", keyID)
+		fmt.Printf("  Algorithm:   %s\n", algorithm)
+		fmt.Printf("Keep the private key secret; share the public key and its key ID, e.g. \"ipm keys add %s %s --scope @scope\"\n", name, pubFile)
+	},
+}
+
+// writeKeyFile schreibt eine Schlüsseldatei mit perm, auch wenn sie schon mit
+// anderen Rechten existiert.
+func writeKeyFile(file string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", file, err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to set permissions of %s: %v", file, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %v", file, err)
+	}
+	return f.Close()
+}
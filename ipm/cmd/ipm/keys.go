@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ipm/pkg/keyring"
+	"ipm/pkg/log"
+	"ipm/pkg/signing"
+
+	"github.com/spf13/cobra"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage trusted public keys for signature verification",
+}
+
+var keysAddCmd = &cobra.Command{
+	Use:   "add <name> <public-key-file>",
+	Short: "Add a public key to the keyring",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		scopes, _ := cmd.Flags().GetStringArray("scope")
+		validFrom, _ := cmd.Flags().GetString("valid-from")
+		expires, _ := cmd.Flags().GetString("expires")
+		revoker, _ := cmd.Flags().GetBool("revoker")
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		store := keysStore(cmd)
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			exitKeys("Failed to read public key", fmt.Errorf("failed to read public key: %v", err))
+		}
+		key, err := keyring.NewKey(args[0], data)
+		if err != nil {
+			exitKeys("Invalid public key", err)
+		}
+		if existing, err := store.Find(key.Name); err == nil {
+			exitKeys("Key already exists", fmt.Errorf("key %s already exists in %s (%s); remove it first", existing.Name, store.Source(), existing.ID))
+		}
+		for _, scope := range scopes {
+			if err := key.Trust(scope); err != nil {
+				exitKeys("Invalid scope", err)
+			}
+		}
+		from, err := keyring.ParseTime(validFrom)
+		if err != nil {
+			exitKeys("Invalid --valid-from", err)
+		}
+		until, err := keyring.ParseTime(expires)
+		if err != nil {
+			exitKeys("Invalid --expires", err)
+		}
+		if err := key.SetValidity(from, until); err != nil {
+			exitKeys("Invalid validity", err)
+		}
+		key.Revoker = revoker
+		if err := store.Save(key); err != nil {
+			exitKeys("Failed to save key", err)
+		}
+		log.Info("Key added", map[string]interface{}{
+			"name":   key.Name,
+			"keyId":  key.ID,
+			"source": store.Source(),
+		})
+		fmt.Printf("Added key %s (%s, %s) to %s\n", key.Name, key.ID, key.Algorithm, store.Source())
+		if len(key.Scopes) == 0 && !key.Revoker {
+			fmt.Printf("Hint: the key is not trusted for any package yet; run \"ipm keys trust %s --scope @scope\"\n", key.Name)
+		}
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the keys of the user and the project",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		ring, err := keyring.Load(".")
+		if err != nil {
+			exitKeys("Failed to load keyring", err)
+		}
+		if jsonOutput {
+			type keyInfo struct {
+				Name      string   `json:"name"`
+				KeyID     string   `json:"keyId"`
+				Algorithm string   `json:"algorithm"`
+				Scopes    []string `json:"scopes"`
+				Revoker   bool     `json:"revoker,omitempty"`
+				ValidFrom string   `json:"validFrom,omitempty"`
+				Expires   string   `json:"expires,omitempty"`
+				Status    string   `json:"status"`
+				RevokedAt string   `json:"revokedAt,omitempty"`
+				Reason    string   `json:"reason,omitempty"`
+				Source    string   `json:"source"`
+			}
+			list := []keyInfo{}
+			for _, k := range ring.Keys {
+				scopes := k.Scopes
+				if scopes == nil {
+					scopes = []string{}
+				}
+				state := ring.State(k)
+				list = append(list, keyInfo{Name: k.Name, KeyID: k.ID, Algorithm: k.Algorithm, Scopes: scopes, Revoker: k.Revoker,
+					ValidFrom: k.ValidFrom, Expires: k.Expires, Status: state.Status, RevokedAt: state.RevokedAt, Reason: state.Reason, Source: k.Source})
+			}
+			data, _ := json.MarshalIndent(map[string]interface{}{"keys": list}, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+		if len(ring.Keys) == 0 {
+			fmt.Println("No keys; add one with \"ipm keys add <name> <public-key-file>\"")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tKEY ID\tALGORITHM\tSCOPES\tEXPIRES\tSTATUS\tSOURCE")
+		for _, k := range ring.Keys {
+			scopes := strings.Join(k.Scopes, ",")
+			if k.Revoker {
+				scopes = strings.TrimPrefix(scopes+",(revoker)", ",")
+			}
+			if scopes == "" {
+				scopes = "(not trusted)"
+			}
+			expires := k.Expires
+			if expires == "" {
+				expires = "-"
+			}
+			state := ring.State(k)
+			status := state.Status
+			if state.Status == keyring.KeyRevoked {
+				status += " " + state.RevokedAt
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.Name, k.ID, k.Algorithm, scopes, expires, status, k.Source)
+		}
+		w.Flush()
+		if len(ring.Revocations) > 0 {
+			fmt.Printf("%d key(s) revoked by revocation lists\n", len(ring.Revocations))
+		}
+	},
+}
+
+var keysRemoveCmd = &cobra.Command{
+	Use:   "remove <name|key-id>",
+	Short: "Remove a key from the keyring",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		store := keysStore(cmd)
+		key, err := store.Find(args[0])
+		if err != nil {
+			exitKeys("Key not found", err)
+		}
+		if err := store.Remove(key.Name); err != nil {
+			exitKeys("Failed to remove key", err)
+		}
+		log.Info("Key removed", map[string]interface{}{
+			"name":   key.Name,
+			"keyId":  key.ID,
+			"source": store.Source(),
+		})
+		fmt.Printf("Removed key %s (%s) from %s\n", key.Name, key.ID, store.Source())
+	},
+}
+
+var keysTrustCmd = &cobra.Command{
+	Use:   "trust <name|key-id> --scope <@scope|package|*>",
+	Short: "Trust a key for a scope or package",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		scopes, _ := cmd.Flags().GetStringArray("scope")
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		if len(scopes) == 0 {
+			exitKeys("Missing scope", fmt.Errorf("at least one --scope is required"))
+		}
+		store := keysStore(cmd)
+		key, err := store.Find(args[0])
+		if err != nil {
+			exitKeys("Key not found", err)
+		}
+		for _, scope := range scopes {
+			if err := key.Trust(scope); err != nil {
+				exitKeys("Invalid scope", err)
+			}
+		}
+		if err := store.Save(key); err != nil {
+			exitKeys("Failed to save key", err)
+		}
+		log.Info("Key trusted", map[string]interface{}{
+			"name":   key.Name,
+			"scopes": key.Scopes,
+			"source": store.Source(),
+		})
+		fmt.Printf("Key %s is trusted for %s\n", key.Name, strings.Join(key.Scopes, ", "))
+	},
+}
+
+var keysExpireCmd = &cobra.Command{
+	Use:   "expire <name|key-id>",
+	Short: "Set the expiry of a key; signatures made later are rejected",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		at, _ := cmd.Flags().GetString("at")
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		expires := time.Now()
+		if at != "" {
+			var err error
+			if expires, err = keyring.ParseTime(at); err != nil {
+				exitKeys("Invalid --at", err)
+			}
+		}
+		store := keysStore(cmd)
+		key, err := store.Find(args[0])
+		if err != nil {
+			exitKeys("Key not found", err)
+		}
+		validFrom, _ := keyring.ParseTime(key.ValidFrom)
+		if err := key.SetValidity(validFrom, expires); err != nil {
+			exitKeys("Invalid expiry", err)
+		}
+		if err := store.Save(key); err != nil {
+			exitKeys("Failed to save key", err)
+		}
+		log.Info("Key expiry set", map[string]interface{}{
+			"name":    key.Name,
+			"expires": key.Expires,
+			"source":  store.Source(),
+		})
+		fmt.Printf("Key %s expires at %s\n", key.Name, key.Expires)
+	},
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate <old-name|key-id> <new-name> <public-key-file>",
+	Short: "Replace a key: the new key takes over its scopes, the old one expires",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		overlap, _ := cmd.Flags().GetDuration("overlap")
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		store := keysStore(cmd)
+		old, err := store.Find(args[0])
+		if err != nil {
+			exitKeys("Key not found", err)
+		}
+		data, err := os.ReadFile(args[2])
+		if err != nil {
+			exitKeys("Failed to read public key", fmt.Errorf("failed to read public key: %v", err))
+		}
+		key, err := keyring.NewKey(args[1], data)
+		if err != nil {
+			exitKeys("Invalid public key", err)
+		}
+		if existing, err := store.Find(key.Name); err == nil {
+			exitKeys("Key already exists", fmt.Errorf("key %s already exists in %s (%s)", existing.Name, store.Source(), existing.ID))
+		}
+		if key.ID == old.ID {
+			exitKeys("Same key", fmt.Errorf("%s is the key being rotated", args[2]))
+		}
+		// Signaturen des alten Schlüssels bleiben gültig, wenn sie vor seinem Ablauf entstanden
+		now := time.Now()
+		key.Scopes, key.Revoker = old.Scopes, old.Revoker
+		if err := key.SetValidity(now, time.Time{}); err != nil {
+			exitKeys("Invalid validity", err)
+		}
+		oldFrom, _ := keyring.ParseTime(old.ValidFrom)
+		if err := old.SetValidity(oldFrom, now.Add(overlap)); err != nil {
+			exitKeys("Invalid expiry", err)
+		}
+		if err := store.Save(key); err != nil {
+			exitKeys("Failed to save key", err)
+		}
+		if err := store.Save(old); err != nil {
+			exitKeys("Failed to save key", err)
+		}
+		log.Info("Key rotated", map[string]interface{}{
+			"old":     old.Name,
+			"new":     key.Name,
+			"expires": old.Expires,
+			"source":  store.Source(),
+		})
+		fmt.Printf("Rotated %s (%s) to %s (%s); %s expires at %s\n", old.Name, old.ID, key.Name, key.ID, old.Name, old.Expires)
+	},
+}
+
+var keysRevokeCmd = &cobra.Command{
+	Use:   "revoke <name|key-id> --key <revoker-private-key>",
+	Short: "Add a key to a signed revocation list; all of its signatures are rejected",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keyFile, _ := cmd.Flags().GetString("key")
+		passphraseFile, _ := cmd.Flags().GetString("passphrase-file")
+		listFile, _ := cmd.Flags().GetString("list")
+		reason, _ := cmd.Flags().GetString("reason")
+		at, _ := cmd.Flags().GetString("at")
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		if keyFile == "" {
+			exitKeys("Missing key", fmt.Errorf("--key with the private key of a revoker is required"))
+		}
+		revokedAt := time.Now()
+		if at != "" {
+			var err error
+			if revokedAt, err = keyring.ParseTime(at); err != nil {
+				exitKeys("Invalid --at", err)
+			}
+		}
+		signer, err := loadSigningKey(keyFile, passphraseFile)
+		if err != nil {
+			exitKeys("Failed to load private key", err)
+		}
+		signerID, err := signing.KeyID(signer.Public())
+		if err != nil {
+			exitKeys("Invalid private key", err)
+		}
+
+		// Schlüssel werden über die Speicherorte gesucht, nicht über keyring.Load:
+		// das prüft die Sperrlisten, die hier erst entstehen
+		var keys []*keyring.Key
+		for _, store := range []*keyring.Store{{}, {Project: "."}} {
+			stored, err := store.Load()
+			if err != nil {
+				exitKeys("Failed to load keys", err)
+			}
+			keys = append(keys, stored...)
+		}
+		keyID := args[0]
+		for _, k := range keys {
+			if k.Name == args[0] || k.ID == args[0] {
+				keyID = k.ID
+			}
+		}
+		if keyID == signerID {
+			// Listen eines gesperrten Sperrschlüssels gelten nicht, auch nicht diese
+			exitKeys("Cannot revoke the signing key", fmt.Errorf("key %s would revoke itself and invalidate this list; sign with another revoker", signerID))
+		}
+		if listFile == "" {
+			dir, err := keyring.UserDir()
+			if err != nil {
+				exitKeys("Failed to locate key directory", err)
+			}
+			listFile = filepath.Join(dir, keyring.RevocationsFile)
+			// Die eigene Liste muss sich mit dem Keyring prüfen lassen, sonst schlägt jede Installation fehl
+			revoker := false
+			for _, k := range keys {
+				revoker = revoker || (k.Revoker && k.ID == signerID)
+			}
+			if !revoker {
+				exitKeys("Not a revoker", fmt.Errorf("key %s is not a revoker in the keyring; add its public key with \"ipm keys add --revoker\" or write the list elsewhere with --list", signerID))
+			}
+		}
+
+		list, err := keyring.ReadRevocations(listFile, signer.Public())
+		if err != nil {
+			exitKeys("Failed to read revocation list", err)
+		}
+		list.Revoke(keyID, revokedAt, reason)
+		data, err := list.Sign(signer)
+		if err != nil {
+			exitKeys("Failed to sign revocation list", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(listFile), 0755); err != nil {
+			exitKeys("Failed to create directory", fmt.Errorf("failed to create directory: %v", err))
+		}
+		if err := os.WriteFile(listFile, data, 0644); err != nil {
+			exitKeys("Failed to write revocation list", fmt.Errorf("failed to write revocation list: %v", err))
+		}
+		log.Info("Key revoked", map[string]interface{}{
+			"keyId":  keyID,
+			"list":   listFile,
+			"signer": signerID,
+		})
+		fmt.Printf("Revoked key %s in %s (%d revoked, signed with %s)\n", keyID, listFile, len(list.Revoked), signerID)
+	},
+}
+
+// keysStore wählt mit --project die ipm.json des Projekts, sonst ~/.ipm/keys.
+func keysStore(cmd *cobra.Command) *keyring.Store {
+	if projectScope, _ := cmd.Flags().GetBool("project"); projectScope {
+		return &keyring.Store{Project: "."}
+	}
+	return &keyring.Store{}
+}
+
+func exitKeys(msg string, err error) {
+	log.Error(msg, err)
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
@@ -1,46 +1,136 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"runtime"
 
 	"ipm/pkg/installer"
+	"ipm/pkg/keyring"
+	"ipm/pkg/lockfile"
 	"ipm/pkg/log"
+	"ipm/pkg/project"
 	"ipm/pkg/registry"
+	"ipm/pkg/signing"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	registryURL string
-	logLevel    string
-	logFile     string
+	registryURL   string
+	registryToken string
+	logLevel      string
+	logFile       string
+	jsonOutput    bool
 )
 
 var rootCmd = &cobra.Command{Use: "ipm"}
 
 var installCmd = &cobra.Command{
 	Use:   "install [package]",
-	Short: "Install a package",
-	Args:  cobra.MinimumNArgs(1),
+	Short: "Install a package, or the project and its workspace members without arguments",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		pubKeyFile, _ := cmd.Flags().GetString("pubkey") // Lokales Flag
+		includePrerelease, _ := cmd.Flags().GetBool("include-prerelease")
+		ignoreScripts, _ := cmd.Flags().GetBool("ignore-scripts")
+		signatures, _ := cmd.Flags().GetString("signatures")
+		registrySignatures, _ := cmd.Flags().GetBool("registry-signatures")
 		if err := log.Init(logLevel, logFile); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 			os.Exit(1)
 		}
+		target := "."
+		if len(args) > 0 {
+			target = args[0]
+		}
 		log.Debug("Starting installation process", map[string]interface{}{
-			"package": args[0],
+			"package": target,
 			"pubkey":  pubKeyFile,
 		})
-		reg := registry.NewNPMRegistry(registryURL, "")
+		reg := registry.NewNPMRegistry(registryURL, registryToken)
+		reg.IncludePrerelease = includePrerelease
 		inst := installer.NewInstaller(reg)
-		if err := inst.Install(reg, args[0], false, pubKeyFile); err != nil {
+		inst.IncludePrerelease = includePrerelease
+		rules, err := project.LoadOverrides(".")
+		if err != nil {
+			log.Error("Failed to load overrides", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inst.Overrides = rules
+		locked, err := lockfile.Load(".")
+		if err != nil {
+			log.Error("Failed to load lockfile", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inst.Locked = locked
+		policy, err := project.LoadScriptPolicy(".")
+		if err != nil {
+			log.Error("Failed to load script policy", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inst.ScriptPolicy = policy
+		inst.IgnoreScripts = ignoreScripts
+		inst.ScriptConfig = scriptConfig()
+		ring, err := keyring.Load(".")
+		if err != nil {
+			log.Error("Failed to load keyring", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inst.Keyring = ring
+		signaturePolicy, err := keyring.LoadPolicy(".")
+		if err != nil {
+			log.Error("Failed to load signature policy", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if signatures != "" {
+			raw, _ := json.Marshal(signatures)
+			override, err := keyring.ParsePolicy(raw, "--signatures")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			// Schwellen für Freigaben gelten weiter, solange die Prüfung nicht aus ist
+			override.Groups, override.Thresholds = signaturePolicy.Groups, signaturePolicy.Thresholds
+			signaturePolicy = override
+		}
+		inst.SignaturePolicy = signaturePolicy
+		if registrySignatures {
+			if inst.RegistryTrust, err = loadRegistryTrust(cmd, reg); err != nil {
+				log.Error("Failed to load registry keys", err)
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if len(args) == 0 {
+			err = inst.InstallProject(reg, ".", jsonOutput, pubKeyFile)
+		} else {
+			err = inst.Install(reg, args[0], jsonOutput, pubKeyFile)
+		}
+		if err != nil {
 			log.Error("Installation failed", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := inst.WriteLockfile("."); err != nil {
+			log.Error("Failed to write lockfile", err)
+			os.Exit(1)
+		}
+		err = inst.RunLifecycleScripts(".", jsonOutput)
+		inst.Report(jsonOutput)
+		if err != nil {
+			log.Error("Lifecycle scripts failed", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 		log.Info("Installation completed", map[string]interface{}{
-			"package": args[0],
+			"package": target,
 		})
 	},
 }
@@ -86,6 +176,12 @@ var signCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		keyFile, _ := cmd.Flags().GetString("key")
+		algorithm, _ := cmd.Flags().GetString("algorithm")
+		signer, _ := cmd.Flags().GetString("signer")
+		detached, _ := cmd.Flags().GetBool("detached")
+		format, _ := cmd.Flags().GetString("format")
+		passphraseFile, _ := cmd.Flags().GetString("passphrase-file")
+		replace, _ := cmd.Flags().GetBool("replace")
 		if err := log.Init(logLevel, logFile); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 			os.Exit(1)
@@ -94,32 +190,79 @@ var signCmd = &cobra.Command{
 			"file": args[0],
 			"key":  keyFile,
 		})
-		if err := signPackage(args[0], keyFile); err != nil {
+		if cmd.Flags().Changed("format") && !detached {
+			fmt.Fprintln(os.Stderr, "Error: --format requires --detached")
+			os.Exit(1)
+		}
+		if !detached {
+			format = ""
+		} else if !cmd.Flags().Changed("format") && !replace {
+			// Eine weitere Signatur kommt in das Format der vorhandenen
+			if existing, err := signing.ReadDetached(args[0]); err == nil && existing != nil {
+				if existingFormat, err := signing.DetachedFormat(existing); err == nil {
+					format = existingFormat
+				}
+			}
+		}
+		if signer == "" {
+			signer = defaultSigner()
+		}
+		meta, cosigned, err := signPackage(args[0], keyFile, passphraseFile, format, replace, signing.SignOptions{Algorithm: algorithm, Signer: signer})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			log.Error("Failed to sign package", err)
 			os.Exit(1)
 		}
 		log.Info("Package signed successfully", map[string]interface{}{
 			"file": args[0],
 		})
-		fmt.Printf("Signed package %s\n", args[0])
+		action := "Signed"
+		if cosigned {
+			action = "Added signature to"
+		}
+		if detached {
+			fmt.Printf("%s package %s (detached signature %s)\n", action, args[0], args[0]+signing.DetachedSuffix)
+		} else {
+			fmt.Printf("%s package %s\n", action, args[0])
+		}
+		fmt.Printf("  %s\n", meta)
 	},
 }
 
 var verifyCmd = &cobra.Command{
 	Use:   "verify [file]",
-	Short: "Verify a package file",
-	Args:  cobra.ExactArgs(1),
+	Short: "Verify a package file, or with --installed the packages in node_modules",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		pubKeyFile, _ := cmd.Flags().GetString("pubkey")
+		pubKeyFiles, _ := cmd.Flags().GetStringArray("pubkey")
+		sigFile, _ := cmd.Flags().GetString("signature")
+		installed, _ := cmd.Flags().GetBool("installed")
 		if err := log.Init(logLevel, logFile); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 			os.Exit(1)
 		}
+		if installed {
+			if len(args) > 0 || sigFile != "" || len(pubKeyFiles) > 1 {
+				fmt.Fprintln(os.Stderr, "Error: --installed takes no file, no --signature and at most one --pubkey")
+				os.Exit(1)
+			}
+			pubKeyFile := ""
+			if len(pubKeyFiles) == 1 {
+				pubKeyFile = pubKeyFiles[0]
+			}
+			verifyInstalled(pubKeyFile)
+			return
+		}
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: a package file or --installed is required")
+			os.Exit(1)
+		}
 		log.Debug("Starting verification process", map[string]interface{}{
 			"file":   args[0],
-			"pubkey": pubKeyFile,
+			"pubkey": pubKeyFiles,
 		})
-		if err := verifyPackage(args[0], pubKeyFile); err != nil {
+		signatures, err := verifyPackage(args[0], pubKeyFiles, sigFile)
+		if err != nil {
 			fmt.Printf("Package verification failed: %v\n", err)
 			log.Error("Failed to verify package", err)
 			os.Exit(1)
@@ -127,23 +270,88 @@ var verifyCmd = &cobra.Command{
 		log.Info("Package verified successfully", map[string]interface{}{
 			"file": args[0],
 		})
+		if len(signatures) == 0 {
+			fmt.Printf("Package %s is not signed\n", args[0])
+			return
+		}
 		fmt.Printf("Verified package %s\n", args[0])
+		for _, s := range signatures {
+			if s.meta.Package != "" {
+				fmt.Printf("  %s@%s %s\n", s.meta.Package, s.meta.Version, s.meta)
+			} else {
+				fmt.Printf("  %s\n", s.meta)
+			}
+			if s.err != nil {
+				fmt.Printf("    signature does not count: %v\n", s.err)
+			} else {
+				fmt.Printf("    key %s\n", s.key)
+			}
+		}
 	},
 }
 
 func main() {
 	rootCmd.PersistentFlags().StringVar(&registryURL, "registry", "https://registry.npmjs.org", "Registry URL")
+	rootCmd.PersistentFlags().StringVar(&registryToken, "token", "", "Authentication token for the registry")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Log level (debug, info, error)")
 	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Log file path")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print machine-readable JSON output where supported")
 
 	// Kommando-spezifische Flags
-	installCmd.Flags().String("pubkey", "", "Public key file for signature verification")
-	signCmd.Flags().String("key", "", "Private key file for signing")
-	verifyCmd.Flags().String("pubkey", "", "Public key file for verification")
+	installCmd.Flags().String("pubkey", "", "Public key file for signature verification of all packages (default: the keys trusted in the keyring)")
+	installCmd.Flags().String("signatures", "", "Signature policy for all packages: required, warn or off (overrides "+project.ConfigFile+")")
+	installCmd.Flags().Bool("include-prerelease", false, "Allow prerelease versions to satisfy any matching range")
+	installCmd.Flags().Bool("ignore-scripts", false, "Do not run lifecycle scripts of the project or its dependencies")
+	installCmd.Flags().Bool("registry-signatures", false, "Verify registry signatures (dist.signatures) and provenance attestations of registry packages")
+	addRegistryTrustFlags(installCmd)
+	addRegistryTrustFlags(auditSignaturesCmd)
+	signCmd.Flags().String("key", "", "Private key file for signing (RSA, ECDSA P-256/P-384 or Ed25519)")
+	signCmd.Flags().String("algorithm", "", "Signature algorithm (rsa-pss-sha256, rsa-pkcs1v15-sha256; default: derived from the key)")
+	signCmd.Flags().String("passphrase-file", "", "File with the passphrase of an encrypted private key (default: $IPM_KEY_PASSPHRASE)")
+	signCmd.Flags().String("signer", "", "Signer identity recorded in the signature (default: user@host)")
+	signCmd.Flags().Bool("detached", false, "Write the signature to <file>.sig instead of embedding it in the tarball")
+	signCmd.Flags().String("format", signing.FormatJSON, "Format of a detached signature (json, dsse)")
+	signCmd.Flags().Bool("replace", false, "Replace existing signatures instead of adding one")
+	verifyCmd.Flags().StringArray("pubkey", nil, "Public key file for verification; repeat it to check the signatures of several keys")
+	verifyCmd.Flags().String("signature", "", "Detached signature file (default: <file>.sig if present)")
+	verifyCmd.Flags().Bool("installed", false, "Verify node_modules against the ipm cache, the lockfile and package signatures")
+	runCmd.Flags().Bool("workspaces", false, "Run the script in all workspace members")
+	runCmd.Flags().StringArray("filter", nil, "Only run in members matching a name, glob or path (repeatable)")
+	runCmd.Flags().String("since", "", "Only run in members changed since a git ref, and their dependents")
+	runCmd.Flags().Int("concurrency", runtime.NumCPU(), "Maximum number of scripts running in parallel")
+	execCmd.Flags().String("bin", "", "Bin of the package to run if it has several")
+	keygenCmd.Flags().String("type", signing.KeyTypeEd25519, "Key type (ed25519, rsa, ecdsa)")
+	keygenCmd.Flags().String("out", ".", "Directory for the key files")
+	keygenCmd.Flags().String("name", "", "Base name of the key files <name>.pem and <name>.pub (default: the key type)")
+	keygenCmd.Flags().Int("bits", 0, "RSA key size in bits (default 3072, at least 2048)")
+	keygenCmd.Flags().String("curve", "p256", "ECDSA curve (p256, p384)")
+	keygenCmd.Flags().Bool("encrypt", false, "Encrypt the private key with a passphrase from --passphrase-file or $IPM_KEY_PASSPHRASE")
+	keygenCmd.Flags().String("passphrase-file", "", "File with the passphrase to encrypt the private key (implies --encrypt)")
+	keygenCmd.Flags().Bool("force", false, "Overwrite existing key files")
+	keysAddCmd.Flags().StringArray("scope", nil, "Trust the key for a scope (@acme), a package or * (repeatable)")
+	keysTrustCmd.Flags().StringArray("scope", nil, "Scope (@acme), package or * to trust the key for (repeatable)")
+	keysAddCmd.Flags().String("valid-from", "", "Reject signatures made before this time (RFC 3339 or YYYY-MM-DD)")
+	keysAddCmd.Flags().String("expires", "", "Reject signatures made after this time (RFC 3339 or YYYY-MM-DD)")
+	keysAddCmd.Flags().Bool("revoker", false, "Allow the key to sign revocation lists")
+	keysExpireCmd.Flags().String("at", "", "Expiry time (RFC 3339 or YYYY-MM-DD; default: now)")
+	keysRotateCmd.Flags().Duration("overlap", 0, "Keep the old key valid for this long after the rotation, e.g. 720h")
+	keysRevokeCmd.Flags().String("key", "", "Private key of a revoker to sign the revocation list")
+	keysRevokeCmd.Flags().String("passphrase-file", "", "File with the passphrase of an encrypted private key (default: $IPM_KEY_PASSPHRASE)")
+	keysRevokeCmd.Flags().String("list", "", "Revocation list to update (default: ~/.ipm/keys/"+keyring.RevocationsFile+")")
+	keysRevokeCmd.Flags().String("reason", "", "Reason recorded in the revocation list, e.g. \"key compromise\"")
+	keysRevokeCmd.Flags().String("at", "", "Time of the revocation (RFC 3339 or YYYY-MM-DD; default: now)")
+	for _, c := range []*cobra.Command{keysAddCmd, keysRemoveCmd, keysTrustCmd, keysExpireCmd, keysRotateCmd} {
+		c.Flags().Bool("project", false, "Use the keys in "+project.ConfigFile+" instead of ~/.ipm/keys")
+	}
 
-	rootCmd.AddCommand(installCmd, initCmd, packCmd, signCmd, verifyCmd)
+	distTagCmd.AddCommand(distTagAddCmd, distTagRmCmd, distTagLsCmd)
+	keysCmd.AddCommand(keysAddCmd, keysListCmd, keysRemoveCmd, keysTrustCmd, keysExpireCmd, keysRotateCmd, keysRevokeCmd)
+	auditCmd.AddCommand(auditSignaturesCmd)
+
+	rootCmd.AddCommand(installCmd, uninstallCmd, initCmd, packCmd, signCmd, verifyCmd, distTagCmd, deprecateCmd, whyCmd, runCmd, execCmd, keysCmd, keygenCmd, auditCmd)
+	registerPlugins(rootCmd)
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
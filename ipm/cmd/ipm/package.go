@@ -4,18 +4,19 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"io"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strings"
 
+	"ipm/pkg/installer"
+	"ipm/pkg/keyring"
 	"ipm/pkg/log"
+	"ipm/pkg/registry"
+	"ipm/pkg/signing"
 )
 
 func initPackage(name string) error {
@@ -74,213 +75,232 @@ func packPackage(dir string) (string, error) {
 	return pkgFile, nil
 }
 
-func signPackage(file, keyFile string) error {
-	if keyFile == "" {
-		return fmt.Errorf("private key file required (--key)")
-	}
+// passphraseEnv enthält die Passphrase verschlüsselter Schlüssel, wenn keine
+// --passphrase-file angegeben ist.
+const passphraseEnv = "IPM_KEY_PASSPHRASE"
 
-	keyData, err := os.ReadFile(keyFile)
-	if err != nil {
-		return fmt.Errorf("failed to read private key: %v", err)
-	}
-	block, _ := pem.Decode(keyData)
-	if block == nil {
-		return fmt.Errorf("invalid private key format")
+// readPassphrase liest die Passphrase aus der ersten Zeile von file oder aus
+// IPM_KEY_PASSPHRASE. Ohne beides liefert sie nil.
+func readPassphrase(file string) ([]byte, error) {
+	if file == "" {
+		if value, ok := os.LookupEnv(passphraseEnv); ok {
+			return []byte(value), nil
+		}
+		return nil, nil
 	}
-
-	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	data, err := os.ReadFile(file)
 	if err != nil {
-		return fmt.Errorf("failed to parse private key: %v", err)
-	}
-
-	privateKey, ok := key.(*rsa.PrivateKey)
-	if !ok {
-		return fmt.Errorf("private key is not an RSA key")
+		return nil, fmt.Errorf("failed to read passphrase file: %v", err)
 	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	return []byte(strings.TrimSuffix(line, "\r")), nil
+}
 
-	// Original-Tarball ohne Signatur laden
-	tarball, err := os.ReadFile(file)
+// loadSigningKey liest einen privaten Schlüssel und entschlüsselt ihn bei Bedarf.
+func loadSigningKey(keyFile, passphraseFile string) (crypto.Signer, error) {
+	passphrase, err := readPassphrase(passphraseFile)
 	if err != nil {
-		return fmt.Errorf("failed to read package file: %v", err)
+		return nil, err
 	}
-
-	// Signatur erstellen (für den Inhalt ohne Signatur)
-	hash := sha256.Sum256(tarball)
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
-	if err != nil {
-		return fmt.Errorf("failed to sign package: %v", err)
+	key, err := signing.LoadPrivateKey(keyFile, passphrase)
+	if err == signing.ErrPassphraseRequired {
+		return nil, fmt.Errorf("%v: use --passphrase-file or set %s", err, passphraseEnv)
 	}
+	return key, err
+}
 
-	// Temporäre Datei für neue .tgz mit Signatur
-	tempFile, err := os.CreateTemp("", "signed-*.tgz")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %v", err)
+// signPackage signiert file. Ohne format wird die Signatur in den Tarball
+// eingebettet, sonst als abgelöste Signatur in <file>.sig geschrieben. Ist das
+// Paket bereits signiert, kommt die Signatur zu den vorhandenen hinzu (cosigned);
+// mit replace ersetzt sie sie.
+func signPackage(file, keyFile, passphraseFile, format string, replace bool, opts signing.SignOptions) (*signing.Metadata, bool, error) {
+	if keyFile == "" {
+		return nil, false, fmt.Errorf("private key file required (--key)")
 	}
-	defer os.Remove(tempFile.Name()) // Wird später überschrieben
-
-	gw := gzip.NewWriter(tempFile)
-	defer gw.Close()
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
-	// Original-Tarball entpacken und kopieren
-	f, err := os.Open(file)
+	key, err := loadSigningKey(keyFile, passphraseFile)
 	if err != nil {
-		return fmt.Errorf("failed to open tarball: %v", err)
+		return nil, false, err
 	}
-	defer f.Close()
 
-	gzr, err := gzip.NewReader(f)
+	tarball, err := os.ReadFile(file)
 	if err != nil {
-		return fmt.Errorf("failed to read gzip: %v", err)
+		return nil, false, fmt.Errorf("failed to read package file: %v", err)
 	}
-	defer gzr.Close()
-
-	tr := tar.NewReader(gzr)
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tarball: %v", err)
+	var out, existing []byte
+	var meta *signing.Metadata
+	target := file
+	if format != "" {
+		target = file + signing.DetachedSuffix
+		if existing, err = signing.ReadDetached(file); err != nil {
+			return nil, false, err
 		}
-		if err := tw.WriteHeader(hdr); err != nil {
-			return fmt.Errorf("failed to write header: %v", err)
+	} else if _, existing, _, err = signing.Unsign(tarball); err != nil {
+		return nil, false, err
+	}
+	cosigned := existing != nil && !replace
+	if cosigned && format != "" {
+		existingFormat, formatErr := signing.DetachedFormat(existing)
+		if formatErr != nil {
+			return nil, false, fmt.Errorf("%s: %v", target, formatErr)
 		}
-		_, err = io.Copy(tw, tr)
-		if err != nil {
-			return fmt.Errorf("failed to copy file: %v", err)
+		if existingFormat != format {
+			return nil, false, fmt.Errorf("%s is in %s format; sign with --format %s or --replace", target, existingFormat, existingFormat)
 		}
 	}
-
-	// Signatur als signature.sig hinzufügen
-	sigHeader := &tar.Header{
-		Name: "signature.sig",
-		Mode: 0644,
-		Size: int64(len(signature)),
+	switch {
+	case format != "" && cosigned:
+		out, meta, err = signing.CosignDetached(existing, tarball, key, opts)
+	case format != "":
+		out, meta, err = signing.SignDetached(tarball, key, opts, format)
+	case cosigned:
+		out, meta, err = signing.CosignTarball(tarball, key, opts)
+	default:
+		out, meta, err = signing.SignTarball(tarball, key, opts)
 	}
-	if err := tw.WriteHeader(sigHeader); err != nil {
-		return fmt.Errorf("failed to write signature header: %v", err)
-	}
-	if _, err := tw.Write(signature); err != nil {
-		return fmt.Errorf("failed to write signature: %v", err)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// Tarball abschließen und umbenennen
-	tw.Close()
-	gw.Close()
+	// Über eine temporäre Datei ersetzen, damit ein Abbruch das Original nicht beschädigt
+	tempFile, err := os.CreateTemp(filepath.Dir(target), "signed-*")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.Write(out); err != nil {
+		tempFile.Close()
+		return nil, false, fmt.Errorf("failed to write signature: %v", err)
+	}
 	tempFile.Close()
-	if err := os.Rename(tempFile.Name(), file); err != nil {
-		return fmt.Errorf("failed to replace original tarball: %v", err)
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		return nil, false, fmt.Errorf("failed to set permissions: %v", err)
+	}
+	if err := os.Rename(tempFile.Name(), target); err != nil {
+		return nil, false, fmt.Errorf("failed to write %s: %v", target, err)
 	}
 
-	return nil
+	log.Info("Package signature created", map[string]interface{}{
+		"file":      target,
+		"algorithm": meta.Algorithm,
+		"keyId":     meta.KeyID,
+		"signer":    meta.Signer,
+		"cosigned":  cosigned,
+	})
+	return meta, cosigned, nil
 }
 
-func verifyPackage(file, pubKeyFile string) error {
-	if pubKeyFile == "" {
-		return fmt.Errorf("public key file required (--pubkey)")
+// defaultSigner ist die Signer-Angabe ohne --signer: Benutzer@Rechner.
+func defaultSigner() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return name + "@" + host
 	}
+	return name
+}
 
-	pubKeyData, err := os.ReadFile(pubKeyFile)
-	if err != nil {
-		return fmt.Errorf("failed to read public key: %v", err)
+// verifiedSignature ist eine gültige Signatur mit dem Zustand ihres Schlüssels;
+// err nennt, warum sie trotzdem nicht gilt (gesperrter oder abgelaufener Schlüssel).
+type verifiedSignature struct {
+	meta *signing.Metadata
+	key  keyring.KeyState
+	err  error
+}
+
+// verifyPackage prüft die Signaturen von file mit den Schlüsseln aus pubKeyFiles.
+// Eine abgelöste Signatur (sigFile oder <file>.sig) hat Vorrang vor der
+// eingebetteten. Geliefert werden alle Signaturen dieser Schlüssel mit dem Zustand
+// des Schlüssels; ein Fehler ergibt sich, wenn keine davon gilt. Ein unsignierter
+// Tarball ergibt keine Signaturen.
+func verifyPackage(file string, pubKeyFiles []string, sigFile string) ([]verifiedSignature, error) {
+	if len(pubKeyFiles) == 0 {
+		return nil, fmt.Errorf("public key file required (--pubkey)")
 	}
-	block, _ := pem.Decode(pubKeyData)
-	if block == nil {
-		return fmt.Errorf("invalid public key format")
+	var keys []crypto.PublicKey
+	for _, pubKeyFile := range pubKeyFiles {
+		publicKey, err := signing.LoadPublicKey(pubKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, publicKey)
 	}
 
-	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	tarball, err := os.ReadFile(file)
 	if err != nil {
-		return fmt.Errorf("failed to parse public key: %v", err)
+		return nil, fmt.Errorf("failed to open package file: %v", err)
 	}
-
-	publicKey, ok := pubKey.(*rsa.PublicKey)
-	if !ok {
-		return fmt.Errorf("public key is not an RSA key")
+	var detached []byte
+	if sigFile != "" {
+		if detached, err = os.ReadFile(sigFile); err != nil {
+			return nil, fmt.Errorf("failed to read detached signature: %v", err)
+		}
+	} else if detached, err = signing.ReadDetached(file); err != nil {
+		return nil, err
 	}
 
-	// Tarball öffnen
-	f, err := os.Open(file)
+	metas, err := signing.VerifyPackageAll(tarball, detached, keys)
 	if err != nil {
-		return fmt.Errorf("failed to open package file: %v", err)
+		return nil, err
 	}
-	defer f.Close()
-
-	gzr, err := gzip.NewReader(f)
-	if err != nil {
-		return fmt.Errorf("failed to read gzip: %v", err)
+	if len(metas) == 0 {
+		log.Warn("Package is not signed", map[string]interface{}{
+			"file": file,
+		})
+		return nil, nil
 	}
-	defer gzr.Close()
-
-	tr := tar.NewReader(gzr)
-	var signature []byte
-	var tarballData []byte
-
-	// Tarball ohne Signatur rekonstruieren und Signatur extrahieren
-	tempFile, err := os.CreateTemp("", "unsigned-*.tgz")
+	// Sperrlisten und Gültigkeitszeiträume gelten auch für Schlüssel aus --pubkey
+	ring, err := keyring.Load(".")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %v", err)
+		return nil, err
 	}
-	defer os.Remove(tempFile.Name())
-
-	gw := gzip.NewWriter(tempFile)
-	defer gw.Close()
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
+	var signatures []verifiedSignature
+	var invalid error
+	for _, meta := range metas {
+		state, err := ring.Validity(meta.KeyID, meta.SignedAt())
+		signatures = append(signatures, verifiedSignature{meta: meta, key: state, err: err})
 		if err != nil {
-			return fmt.Errorf("failed to read tarball: %v", err)
-		}
-		if hdr.Name == "signature.sig" {
-			signature, err = io.ReadAll(tr)
-			if err != nil {
-				return fmt.Errorf("failed to read signature: %v", err)
+			if invalid == nil {
+				invalid = err
 			}
 			continue
 		}
-		if err := tw.WriteHeader(hdr); err != nil {
-			return fmt.Errorf("failed to write header: %v", err)
-		}
-		_, err = io.Copy(tw, tr)
-		if err != nil {
-			return fmt.Errorf("failed to copy file: %v", err)
+		log.Info("Package signature verified", map[string]interface{}{
+			"file":      file,
+			"detached":  detached != nil,
+			"algorithm": meta.Algorithm,
+			"keyId":     meta.KeyID,
+			"signer":    meta.Signer,
+			"key":       state.String(),
+		})
+	}
+	for _, s := range signatures {
+		if s.err == nil {
+			return signatures, nil
 		}
 	}
+	return nil, invalid
+}
 
-	tw.Close()
-	gw.Close()
-	tempFile.Close()
-
-	if signature == nil {
-		log.Warn("Package is not signed", map[string]interface{}{
-			"file": file,
-		})
-		return nil
+// und Signaturen und beendet ipm mit Fehler, sobald etwas abweicht.
+func verifyInstalled(pubKeyFile string) {
+	inst := installer.NewInstaller(registry.NewNPMRegistry(registryURL, registryToken))
+	var err error
+	if inst.Keyring, err = keyring.Load("."); err != nil {
+		exitKeys("Failed to load keyring", err)
 	}
-
-	// Unsigned Tarball laden
-	tarballData, err = os.ReadFile(tempFile.Name())
-	if err != nil {
-		return fmt.Errorf("failed to read unsigned tarball: %v", err)
+	if inst.SignaturePolicy, err = keyring.LoadPolicy("."); err != nil {
+		exitKeys("Failed to load signature policy", err)
 	}
-
-	// Signatur verifizieren
-	hash := sha256.Sum256(tarballData)
-	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature)
+	ok, err := inst.VerifyInstalled(".", pubKeyFile)
 	if err != nil {
-		return fmt.Errorf("package signature verification failed: %v", err)
+		exitKeys("Failed to verify installed packages", err)
 	}
-
-	log.Info("Package signature verified", map[string]interface{}{
-		"file": file,
-	})
-	return nil
-}
\ No newline at end of file
+	inst.ReportInstalled(jsonOutput)
+	if !ok {
+		log.Error("Installed packages do not match", fmt.Errorf("node_modules differs from the installed state"))
+		os.Exit(1)
+	}
+	log.Info("Installed packages verified")
+}
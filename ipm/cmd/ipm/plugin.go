@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"ipm/pkg/log"
+	"ipm/pkg/plugin"
+
+	"github.com/spf13/cobra"
+)
+
+const pluginGroup = "plugins"
+
+// registerPlugins fügt die Kommandos installierter Pakete als Unterkommandos von
+// root hinzu. Eingebaute Kommandos haben Vorrang.
+func registerPlugins(root *cobra.Command) {
+	projectRoot := plugin.FindRoot(".")
+	commands, problems := plugin.Discover(projectRoot)
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", problem)
+	}
+	if len(commands) == 0 {
+		return
+	}
+
+	root.AddGroup(&cobra.Group{ID: pluginGroup, Title: "Commands provided by installed packages:"})
+	for _, p := range commands {
+		if existing, _, err := root.Find([]string{p.Name}); err == nil && existing != root {
+			fmt.Fprintf(os.Stderr, "Warning: command %q of %s is shadowed by a built-in command\n", p.Name, p.Package)
+			continue
+		}
+		root.AddCommand(pluginCommand(p, projectRoot))
+	}
+}
+
+func pluginCommand(p plugin.Command, projectRoot string) *cobra.Command {
+	return &cobra.Command{
+		Use:     p.Name + " [args...]",
+		Short:   p.Description,
+		Long:    fmt.Sprintf("%s\n\nProvided by %s@%s (%s).", p.Description, p.Package, p.Version, p.Path),
+		GroupID: pluginGroup,
+		// Argumente gehören dem Plugin; nur ipm-Flags davor werden ausgewertet
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			args, err := parseGlobalFlags(cmd.Root(), args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err := log.Init(logLevel, logFile); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+				os.Exit(1)
+			}
+			ctx := plugin.Context{
+				Root:     projectRoot,
+				JSON:     jsonOutput,
+				LogLevel: logLevel,
+				LogFile:  logFile,
+				Registry: registryURL,
+			}
+			log.Debug("Running plugin command", map[string]interface{}{
+				"command": p.Name,
+				"package": p.Package,
+				"version": p.Version,
+				"path":    p.Path,
+			})
+			code, err := p.Run(ctx, args, os.Stdin, os.Stdout, os.Stderr)
+			if err != nil {
+				log.Error("Plugin command failed", err, map[string]interface{}{
+					"command": p.Name,
+				})
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(code)
+		},
+	}
+}
+
+// parseGlobalFlags wertet führende ipm-Flags aus ("ipm --json compile" bzw.
+// "ipm compile --log-level debug src") und liefert die übrigen Argumente.
+// Ab dem ersten anderen Argument oder "--" geht alles unverändert an das Plugin.
+func parseGlobalFlags(root *cobra.Command, args []string) ([]string, error) {
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return args[1:], nil
+		}
+		if !strings.HasPrefix(arg, "--") {
+			return args, nil
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		flag := root.PersistentFlags().Lookup(name)
+		if flag == nil {
+			return args, nil
+		}
+		args = args[1:]
+		if !hasValue {
+			if flag.NoOptDefVal != "" {
+				value = flag.NoOptDefVal
+			} else if len(args) > 0 {
+				value, args = args[0], args[1:]
+			} else {
+				return nil, fmt.Errorf("flag --%s needs a value", name)
+			}
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return nil, fmt.Errorf("invalid value for --%s: %v", name, err)
+		}
+	}
+	return args, nil
+}
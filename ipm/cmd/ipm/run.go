@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"ipm/pkg/log"
+	"ipm/pkg/project"
+	"ipm/pkg/runner"
+	"ipm/pkg/shell"
+
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <script> [-- args...]",
+	Short: "Run a script of the project or of workspace members",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		workspaces, _ := cmd.Flags().GetBool("workspaces")
+		filters, _ := cmd.Flags().GetStringArray("filter")
+		since, _ := cmd.Flags().GetString("since")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		ws, err := project.LoadWorkspace(".")
+		if err != nil {
+			log.Error("Failed to load project", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		script, extra := args[0], args[1:]
+		if !workspaces && len(filters) == 0 && since == "" {
+			os.Exit(runProjectScript(ws, script, extra))
+		}
+		if err := runWorkspaceScript(ws, script, extra, filters, since, concurrency); err != nil {
+			log.Error("Workspace run failed", err, map[string]interface{}{
+				"script": script,
+			})
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// runProjectScript führt ein Skript der package.json im aktuellen Verzeichnis aus
+// und liefert dessen Exit-Code.
+func runProjectScript(ws *project.Workspace, script string, extra []string) int {
+	command, ok := ws.Root.Scripts[script]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: missing script: %s\n", script)
+		return 1
+	}
+	command = runner.QuoteArgs(command, extra)
+	fmt.Printf("> %s@%s %s\n> %s\n\n", ws.Root.Name, ws.Root.Version, script, command)
+	log.Debug("Running script", map[string]interface{}{
+		"script":  script,
+		"command": command,
+	})
+	err := runner.Exec(ws.Dir, command, runner.Env(ws.Root, ws.Dir, ws.Dir, script, command, scriptConfig()), os.Stdout, os.Stderr)
+	if err == nil {
+		return 0
+	}
+	log.Error("Script failed", err, map[string]interface{}{
+		"script": script,
+	})
+	var exitErr *shell.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return 1
+}
+
+// runWorkspaceScript führt ein Skript in den gewählten Workspace-Mitgliedern in
+// topologischer Reihenfolge aus. --filter und --since schränken die Auswahl ein.
+func runWorkspaceScript(ws *project.Workspace, script string, extra, filters []string, since string, concurrency int) error {
+	if len(ws.Members) == 0 {
+		return fmt.Errorf("%s defines no workspaces", project.ManifestFile)
+	}
+	selected, err := runner.Select(ws, filters)
+	if err != nil {
+		return err
+	}
+	if since != "" {
+		changed, err := runner.ChangedSince(ws, since)
+		if err != nil {
+			return err
+		}
+		selected = intersect(selected, changed)
+	}
+	tasks, err := runner.Plan(ws, selected, script)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		fmt.Printf("No workspace member to run %q in\n", script)
+		return nil
+	}
+
+	log.Info("Running workspace script", map[string]interface{}{
+		"script":      script,
+		"members":     len(tasks),
+		"concurrency": concurrency,
+	})
+	return runner.Run(tasks, concurrency, os.Stdout, os.Stderr, func(task *runner.Task, stdout, stderr io.Writer) error {
+		dir := filepath.Join(ws.Dir, filepath.FromSlash(task.Member.Dir))
+		command := runner.QuoteArgs(task.Script, extra)
+		fmt.Fprintf(stdout, "> %s@%s %s: %s\n", task.Name(), task.Member.Manifest.Version, script, command)
+		return runner.Exec(dir, command, runner.Env(task.Member.Manifest, dir, ws.Dir, script, command, scriptConfig()), stdout, stderr)
+	})
+}
+
+// scriptConfig liefert die Einstellungen, die Skripte als npm_config_* sehen.
+func scriptConfig() map[string]string {
+	return map[string]string{
+		"registry":   registryURL,
+		"user_agent": fmt.Sprintf("ipm go/%s %s %s", runtime.Version(), runtime.GOOS, runtime.GOARCH),
+	}
+}
+
+func intersect(members, other []project.Member) []project.Member {
+	keep := make(map[string]bool, len(other))
+	for _, member := range other {
+		keep[member.Manifest.Name] = true
+	}
+	var result []project.Member
+	for _, member := range members {
+		if keep[member.Manifest.Name] {
+			result = append(result, member)
+		}
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"ipm/pkg/installer"
+	"ipm/pkg/log"
+
+	"github.com/spf13/cobra"
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall <package>",
+	Short: "Remove a package, its bin links and dependencies nothing else needs",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		name, _ := splitPackageSpec(args[0])
+		removed, err := installer.Uninstall(".", name)
+		if err != nil {
+			log.Error("Failed to uninstall package", err, map[string]interface{}{
+				"package": name,
+			})
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, pkgName := range removed {
+			fmt.Printf("Removed %s\n", pkgName)
+		}
+		log.Info("Uninstall completed", map[string]interface{}{
+			"package": name,
+			"removed": len(removed),
+		})
+	},
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"ipm/pkg/lockfile"
+	"ipm/pkg/log"
+
+	"github.com/spf13/cobra"
+)
+
+var whyCmd = &cobra.Command{
+	Use:   "why <package>",
+	Short: "Explain why a package is installed",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := log.Init(logLevel, logFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+			os.Exit(1)
+		}
+		lf, err := lockfile.Load(".")
+		if err != nil {
+			log.Error("Failed to load lockfile", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		name, _ := splitPackageSpec(args[0])
+		pkg, ok := lf.Packages[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: %s is not installed (not found in %s)\n", name, lockfile.FileName)
+			os.Exit(1)
+		}
+		fmt.Printf("%s@%s\n", name, pkg.Version)
+		explain(lf, name, "", map[string]bool{name: true})
+	},
+}
+
+// dependent ist eine Kante, über die ein Paket angefordert wird.
+type dependent struct {
+	name     string // leer für das Projekt selbst
+	kind     string
+	spec     string
+	override *lockfile.Override
+}
+
+func dependentsOf(lf *lockfile.Lockfile, name string) []dependent {
+	var result []dependent
+	if spec, ok := lf.Dependencies[name]; ok {
+		d := dependent{kind: "requires", spec: spec}
+		if o, ok := lf.Overridden[name]; ok {
+			d.override = &o
+		}
+		result = append(result, d)
+	}
+
+	names := make([]string, 0, len(lf.Packages))
+	for pkgName := range lf.Packages {
+		names = append(names, pkgName)
+	}
+	sort.Strings(names)
+	for _, pkgName := range names {
+		pkg := lf.Packages[pkgName]
+		var d dependent
+		if spec, ok := pkg.PeerDependencies[name]; ok {
+			d = dependent{name: pkgName, kind: "peer-depends on", spec: spec}
+		}
+		if spec, ok := pkg.Dependencies[name]; ok {
+			d = dependent{name: pkgName, kind: "depends on", spec: spec}
+		}
+		if spec, ok := pkg.OptionalDependencies[name]; ok {
+			d = dependent{name: pkgName, kind: "optionally depends on", spec: spec}
+		}
+		if d.kind == "" {
+			continue
+		}
+		if o, ok := pkg.Overridden[name]; ok {
+			d.override = &o
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+func explain(lf *lockfile.Lockfile, name, indent string, seen map[string]bool) {
+	for _, d := range dependentsOf(lf, name) {
+		from := "(project)"
+		if d.name != "" {
+			from = fmt.Sprintf("%s@%s", d.name, lf.Packages[d.name].Version)
+		}
+		line := fmt.Sprintf("%s└─ %s %s %s@%s", indent, from, d.kind, name, d.spec)
+		if d.override != nil {
+			line = fmt.Sprintf("%s└─ %s %s %s@%s, overridden to %s by %s", indent, from, d.kind, name, d.override.From, d.override.To, d.override.Rule)
+		}
+		fmt.Println(line)
+
+		if d.name == "" {
+			continue
+		}
+		if seen[d.name] {
+			fmt.Printf("%s   └─ (cycle)\n", indent)
+			continue
+		}
+		seen[d.name] = true
+		explain(lf, d.name, indent+strings.Repeat(" ", 3), seen)
+		delete(seen, d.name)
+	}
+}
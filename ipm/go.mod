@@ -3,7 +3,6 @@ module ipm
 go 1.21
 
 require (
-	github.com/Masterminds/semver/v3 v3.3.1
 	github.com/sirupsen/logrus v1.9.3
 	github.com/spf13/cobra v1.8.0
 )
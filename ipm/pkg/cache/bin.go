@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"ipm/pkg/log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// BinDir ist das Verzeichnis unterhalb von node_modules für ausführbare Dateien.
+const BinDir = ".bin"
+
+// Bins liest die ausführbaren Dateien eines Pakets aus dessen package.json: "bin"
+// als String (Name = Paketname ohne Scope) oder Objekt, sonst alle Dateien unter
+// "directories.bin". Die Ziele sind Pfade relativ zum Paket mit "/" als Trenner.
+func Bins(pkgPath string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(pkgPath, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %v", err)
+	}
+	var manifest struct {
+		Name        string          `json:"name"`
+		Bin         json.RawMessage `json:"bin"`
+		Directories struct {
+			Bin string `json:"bin"`
+		} `json:"directories"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %v", err)
+	}
+
+	raw := make(map[string]string)
+	switch {
+	case len(manifest.Bin) > 0 && string(manifest.Bin) != "null":
+		var single string
+		if err := json.Unmarshal(manifest.Bin, &single); err == nil {
+			raw[path.Base(manifest.Name)] = single
+		} else if err := json.Unmarshal(manifest.Bin, &raw); err != nil {
+			return nil, fmt.Errorf("invalid bin field in package.json: %v", err)
+		}
+	case manifest.Directories.Bin != "":
+		dir, ok := cleanTarget(manifest.Directories.Bin)
+		if !ok {
+			return nil, fmt.Errorf("invalid directories.bin %q", manifest.Directories.Bin)
+		}
+		root := filepath.Join(pkgPath, filepath.FromSlash(dir))
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			rel, err := filepath.Rel(pkgPath, p)
+			if err != nil {
+				return err
+			}
+			raw[d.Name()] = filepath.ToSlash(rel)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read directories.bin: %v", err)
+		}
+	}
+
+	bins := make(map[string]string, len(raw))
+	for name, target := range raw {
+		cleaned, ok := cleanTarget(target)
+		// Namen mit Pfadtrennern oder Ziele außerhalb des Pakets würden
+		// Dateien außerhalb von node_modules/.bin überschreiben bzw. ausführbar machen
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\:`) || !ok {
+			log.Warn("Ignoring invalid bin entry", map[string]interface{}{
+				"package": manifest.Name,
+				"bin":     name,
+				"target":  target,
+			})
+			continue
+		}
+		bins[name] = cleaned
+	}
+	return bins, nil
+}
+
+// cleanTarget normalisiert einen Pfad im Paket und lehnt Pfade ab, die es verlassen.
+func cleanTarget(target string) (string, bool) {
+	cleaned := path.Clean(strings.ReplaceAll(target, `\`, "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") || path.IsAbs(cleaned) || filepath.VolumeName(cleaned) != "" {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// LinkBins legt für das unter targetDir/name verlinkte Paket die Einträge in
+// targetDir/.bin an: unter Unix relative Symlinks auf die ausführbar gemachten
+// Ziele, unter Windows .cmd- und .ps1-Shims.
+func (c *Cache) LinkBins(targetDir, name string) error {
+	pkgPath := filepath.Join(targetDir, name)
+	bins, err := Bins(pkgPath)
+	if err != nil {
+		return err
+	}
+	if len(bins) == 0 {
+		return nil
+	}
+	binDir := filepath.Join(targetDir, BinDir)
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", binDir, err)
+	}
+
+	for _, bin := range sortedBins(bins) {
+		target := bins[bin]
+		targetPath := filepath.Join(pkgPath, filepath.FromSlash(target))
+		info, err := os.Stat(targetPath)
+		if err != nil {
+			log.Warn("Bin target does not exist, skipping", map[string]interface{}{
+				"package": name,
+				"bin":     bin,
+				"target":  target,
+			})
+			continue
+		}
+		// Relativ zu node_modules/.bin, damit das Projekt verschiebbar bleibt
+		rel := path.Join("..", filepath.ToSlash(name), target)
+		if runtime.GOOS == "windows" {
+			if err := writeShims(binDir, bin, rel, targetPath); err != nil {
+				return err
+			}
+		} else {
+			if err := os.Chmod(targetPath, info.Mode().Perm()|0111); err != nil {
+				return fmt.Errorf("failed to make %s executable: %v", targetPath, err)
+			}
+			if err := replaceSymlink(filepath.Join(binDir, bin), rel); err != nil {
+				return err
+			}
+		}
+		log.Debug("Linked bin", map[string]interface{}{
+			"package": name,
+			"bin":     bin,
+			"target":  target,
+		})
+	}
+	return nil
+}
+
+// UnlinkBins entfernt die Einträge in targetDir/.bin, die auf das Paket
+// targetDir/name zeigen. Es muss vor dem Entfernen des Paket-Links laufen.
+func (c *Cache) UnlinkBins(targetDir, name string) error {
+	pkgPath := filepath.Join(targetDir, name)
+	if _, err := os.Stat(filepath.Join(pkgPath, "package.json")); os.IsNotExist(err) {
+		return nil
+	}
+	bins, err := Bins(pkgPath)
+	if err != nil {
+		return err
+	}
+	binDir := filepath.Join(targetDir, BinDir)
+	for _, bin := range sortedBins(bins) {
+		rel := path.Join("..", filepath.ToSlash(name), bins[bin])
+		// Nur entfernen, was noch zu diesem Paket gehört
+		var files []string
+		if runtime.GOOS == "windows" {
+			if data, err := os.ReadFile(filepath.Join(binDir, bin+".cmd")); err == nil && strings.Contains(string(data), strings.ReplaceAll(rel, "/", `\`)) {
+				files = []string{bin + ".cmd", bin + ".ps1"}
+			}
+		} else if current, err := os.Readlink(filepath.Join(binDir, bin)); err == nil && current == rel {
+			files = []string{bin}
+		}
+		for _, file := range files {
+			if err := os.Remove(filepath.Join(binDir, file)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove bin %s: %v", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+func replaceSymlink(linkPath, target string) error {
+	if current, err := os.Readlink(linkPath); err == nil && current == target {
+		return nil
+	}
+	if err := os.Remove(linkPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove existing bin %s: %v", linkPath, err)
+	}
+	if err := os.Symlink(target, linkPath); err != nil {
+		return fmt.Errorf("failed to link bin %s: %v", linkPath, err)
+	}
+	return nil
+}
+
+// writeShims schreibt .cmd- und .ps1-Shims. Der Interpreter stammt aus der
+// Shebang-Zeile des Ziels (z. B. "#!/usr/bin/env node"); fehlt sie, wird das
+// Ziel direkt gestartet.
+func writeShims(binDir, bin, rel, targetPath string) error {
+	prog, args := Shebang(targetPath)
+	winRel := strings.ReplaceAll(rel, "/", `\`)
+
+	invoke := ""
+	if prog != "" {
+		invoke = fmt.Sprintf("%q ", prog)
+		if args != "" {
+			invoke += args + " "
+		}
+	}
+	cmd := fmt.Sprintf("@ECHO off\r\n%s\"%%~dp0\\%s\" %%*\r\n", invoke, winRel)
+	ps1 := fmt.Sprintf("$basedir = Split-Path $MyInvocation.MyCommand.Definition -Parent\n& %s\"$basedir/%s\" $args\nexit $LASTEXITCODE\n", invoke, rel)
+
+	if err := os.WriteFile(filepath.Join(binDir, bin+".cmd"), []byte(cmd), 0755); err != nil {
+		return fmt.Errorf("failed to write shim %s.cmd: %v", bin, err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, bin+".ps1"), []byte(ps1), 0755); err != nil {
+		return fmt.Errorf("failed to write shim %s.ps1: %v", bin, err)
+	}
+	return nil
+}
+
+// Shebang liefert Interpreter und Argumente aus der ersten Zeile einer Datei.
+// "/usr/bin/env" wird übersprungen, da es unter Windows nicht existiert.
+func Shebang(file string) (string, string) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	if !strings.HasPrefix(line, "#!") {
+		return "", ""
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) > 0 && path.Base(fields[0]) == "env" {
+		fields = fields[1:]
+		if len(fields) > 0 && fields[0] == "-S" {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return path.Base(fields[0]), strings.Join(fields[1:], " ")
+}
+
+func sortedBins(bins map[string]string) []string {
+	names := make([]string, 0, len(bins))
+	for name := range bins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
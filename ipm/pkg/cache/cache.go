@@ -3,10 +3,13 @@ package cache
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"ipm/pkg/log"
+	"ipm/pkg/signing"
 	"ipm/pkg/types"
 	"os"
 	"path/filepath"
@@ -51,9 +54,29 @@ func (c *Cache) GetCachedVersions(name string) ([]string, error) {
 }
 
 func (c *Cache) Store(pkg types.Package, tarball io.ReadCloser) (string, error) {
+	pkgPath := filepath.Join(c.CacheDir, fmt.Sprintf("%s-%s", pkg.Name, pkg.Version))
+	metaPath := filepath.Join(c.CacheDir, fmt.Sprintf("%s-%s.json", pkg.Name, pkg.Version))
+	return c.store(pkg, tarball, pkgPath, metaPath)
+}
+
+// StoreSource legt ein Paket ab, das nicht aus der Registry stammt (Tarball-URL,
+// lokaler Tarball). Der Pfad hängt an der Integrität, damit unterschiedliche Inhalte
+// mit gleicher Version weder einander noch Registry-Versionen überschreiben.
+func (c *Cache) StoreSource(pkg types.Package, integrity string, tarball io.ReadCloser) (string, error) {
+	pkgPath := c.SourceDir(pkg, integrity)
+	return c.store(pkg, tarball, pkgPath, pkgPath+".json")
+}
+
+// SourceDir liefert das Verzeichnis, unter dem StoreSource ein Paket ablegt.
+func (c *Cache) SourceDir(pkg types.Package, integrity string) string {
+	sum := sha256.Sum256([]byte(integrity))
+	name := fmt.Sprintf("%s-%s-%s", strings.ReplaceAll(pkg.Name, "/", "+"), pkg.Version, hex.EncodeToString(sum[:8]))
+	return filepath.Join(c.CacheDir, "_sources", name)
+}
+
+func (c *Cache) store(pkg types.Package, tarball io.ReadCloser, pkgPath, metaPath string) (string, error) {
 	defer tarball.Close()
 
-	pkgPath := filepath.Join(c.CacheDir, fmt.Sprintf("%s-%s", pkg.Name, pkg.Version))
 	if _, err := os.Stat(pkgPath); os.IsNotExist(err) {
 		log.Debug("Cache miss, storing package", map[string]interface{}{
 			"package": pkg.Name,
@@ -81,6 +104,10 @@ func (c *Cache) Store(pkg types.Package, tarball io.ReadCloser) (string, error)
 			}
 
 			targetPath := filepath.Join(pkgPath, strings.TrimPrefix(header.Name, "package/"))
+			if signing.IsSignatureEntry(header.Name) {
+				// Eingebettete Signaturen neben das Paket, nicht zwischen seine Dateien
+				targetPath = filepath.Join(signing.SignatureDir(pkgPath), filepath.Base(header.Name))
+			}
 
 			switch header.Typeflag {
 			case tar.TypeDir:
@@ -109,7 +136,6 @@ func (c *Cache) Store(pkg types.Package, tarball io.ReadCloser) (string, error)
 		}
 
 		// Metadaten speichern
-		metaPath := filepath.Join(c.CacheDir, fmt.Sprintf("%s-%s.json", pkg.Name, pkg.Version))
 		metaData, err := json.Marshal(pkg)
 		if err != nil {
 			return "", fmt.Errorf("failed to marshal package metadata: %v", err)
@@ -128,8 +154,18 @@ func (c *Cache) Store(pkg types.Package, tarball io.ReadCloser) (string, error)
 }
 
 func (c *Cache) Link(pkg types.Package, targetDir string) error {
-	linkPath := filepath.Join(targetDir, pkg.Name)
 	cachedPath := filepath.Join(c.CacheDir, fmt.Sprintf("%s-%s", pkg.Name, pkg.Version))
+	return c.LinkPath(pkg, cachedPath, targetDir, pkg.Name)
+}
+
+// LinkPath verlinkt cachedPath als targetDir/name. So lassen sich Aliase unter
+// anderem Namen sowie Pakete außerhalb des Caches (file:, link:) einbinden.
+// Die "bin"-Einträge des Pakets landen in targetDir/.bin.
+func (c *Cache) LinkPath(pkg types.Package, cachedPath, targetDir, name string) error {
+	linkPath := filepath.Join(targetDir, name)
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return fmt.Errorf("failed to create dir for link %s: %v", linkPath, err)
+	}
 
 	if info, err := os.Lstat(linkPath); err == nil {
 		if info.Mode()&os.ModeSymlink != 0 {
@@ -140,7 +176,7 @@ func (c *Cache) Link(pkg types.Package, targetDir string) error {
 					"link":    linkPath,
 					"target":  cachedPath,
 				})
-				return nil
+				return c.LinkBins(targetDir, name)
 			}
 			log.Debug("Removing outdated symlink", map[string]interface{}{
 				"package": pkg.Name,
@@ -159,7 +195,22 @@ func (c *Cache) Link(pkg types.Package, targetDir string) error {
 		"link":    linkPath,
 		"target":  cachedPath,
 	})
-	return os.Symlink(cachedPath, linkPath)
+	if err := os.Symlink(cachedPath, linkPath); err != nil {
+		return err
+	}
+	return c.LinkBins(targetDir, name)
+}
+
+// Unlink entfernt targetDir/name samt der zugehörigen Einträge in targetDir/.bin.
+func (c *Cache) Unlink(targetDir, name string) error {
+	if err := c.UnlinkBins(targetDir, name); err != nil {
+		return err
+	}
+	linkPath := filepath.Join(targetDir, name)
+	if err := os.Remove(linkPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove link %s: %v", linkPath, err)
+	}
+	return nil
 }
 
 func (c *Cache) Exists(pkg types.Package) bool {
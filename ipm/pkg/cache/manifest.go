@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+)
+
+// manifestPath ist der Ablageort des Inhaltsmanifests neben dem Paketverzeichnis dir.
+func manifestPath(dir string) string {
+	return dir + ".manifest"
+}
+
+// StoreManifest hält das Inhaltsmanifest (signing.Manifest) eines Pakets im Cache
+// fest, damit "ipm verify --installed" spätere Änderungen dateigenau erkennt.
+func (c *Cache) StoreManifest(dir string, manifest []byte) error {
+	if err := os.WriteFile(manifestPath(dir), manifest, 0644); err != nil {
+		return fmt.Errorf("failed to store package manifest: %v", err)
+	}
+	return nil
+}
+
+// LoadManifest liest das Inhaltsmanifest des Pakets in dir; ohne Manifest ergibt sich nil.
+func (c *Cache) LoadManifest(dir string) ([]byte, error) {
+	data, err := os.ReadFile(manifestPath(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package manifest: %v", err)
+	}
+	return data, nil
+}
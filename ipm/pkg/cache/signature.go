@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ipm/pkg/types"
+)
+
+// signaturePath ist der Ablageort der abgelösten Signatur eines Pakets neben
+// seinem Verzeichnis im Cache.
+func (c *Cache) signaturePath(pkg types.Package) string {
+	return filepath.Join(c.CacheDir, fmt.Sprintf("%s-%s.sig", pkg.Name, pkg.Version))
+}
+
+// Dir liefert das Verzeichnis eines Registry-Pakets im Cache.
+func (c *Cache) Dir(pkg types.Package) string {
+	return filepath.Join(c.CacheDir, fmt.Sprintf("%s-%s", pkg.Name, pkg.Version))
+}
+
+// StoreSignature legt eine abgelöste Signatur neben dem Paket ab, damit es sich
+// bei späteren Installationen aus dem Cache erneut prüfen lässt.
+func (c *Cache) StoreSignature(pkg types.Package, data []byte) error {
+	path := c.signaturePath(pkg)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to store signature: %v", err)
+	}
+	return nil
+}
+
+// LoadSignature liest die abgelöste Signatur eines Pakets; ohne Signatur ergibt sich nil.
+func (c *Cache) LoadSignature(pkg types.Package) ([]byte, error) {
+	data, err := os.ReadFile(c.signaturePath(pkg))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached signature: %v", err)
+	}
+	return data, nil
+}
+
+// attestationsPath ist der Ablageort des Attestierungs-Bundles der Registry.
+func (c *Cache) attestationsPath(pkg types.Package) string {
+	return filepath.Join(c.CacheDir, fmt.Sprintf("%s-%s.attestations.json", pkg.Name, pkg.Version))
+}
+
+// StoreAttestations legt das Attestierungs-Bundle eines Pakets ab, damit es sich
+// ohne Netz erneut prüfen lässt.
+func (c *Cache) StoreAttestations(pkg types.Package, data []byte) error {
+	path := c.attestationsPath(pkg)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to store attestations: %v", err)
+	}
+	return nil
+}
+
+// LoadAttestations liest das abgelegte Attestierungs-Bundle; ohne Bundle ergibt sich nil.
+func (c *Cache) LoadAttestations(pkg types.Package) ([]byte, error) {
+	data, err := os.ReadFile(c.attestationsPath(pkg))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached attestations: %v", err)
+	}
+	return data, nil
+}
@@ -49,7 +49,7 @@ func NewRootCmd() *cobra.Command {
 		Short: "Install a package",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return inst.Install(reg, args[0], jsonOutput)
+			return inst.Install(reg, args[0], jsonOutput, "")
 		},
 	}
 
@@ -0,0 +1,171 @@
+package installer
+
+import (
+	"fmt"
+	"sort"
+
+	"ipm/pkg/lockfile"
+	"ipm/pkg/log"
+	"ipm/pkg/registry"
+	"ipm/pkg/signing"
+	"ipm/pkg/source"
+	"ipm/pkg/types"
+)
+
+// registryResult ist der Prüfstatus der Registry-Signatur und der Attestierungen
+// eines Pakets.
+type registryResult struct {
+	Package      string                `json:"package"`
+	Version      string                `json:"version"`
+	Status       string                `json:"status"` // "verified", "missing", "invalid" oder "skipped"
+	KeyID        string                `json:"keyId,omitempty"`
+	Attestations []signing.Attestation `json:"attestations,omitempty"`
+	Reason       string                `json:"reason,omitempty"`
+}
+
+// checkRegistry prüft dist.signatures und die Attestierungen eines Registry-Pakets,
+// sofern RegistryTrust gesetzt ist (--registry-signatures). Ungültige Signaturen
+// brechen die Installation ab, fehlende ergeben eine Warnung.
+func (i *Installer) checkRegistry(reg registry.Registry, pkg types.Package, tarballData []byte) error {
+	if i.RegistryTrust == nil {
+		return nil
+	}
+	result := i.evaluateRegistry(reg, pkg, tarballData)
+	i.registryResults = append(i.registryResults, result)
+	switch result.Status {
+	case "invalid":
+		return fmt.Errorf("registry signature verification of %s@%s failed: %s", pkg.Name, pkg.Version, result.Reason)
+	case "missing":
+		log.Warn("Package has no registry signature", map[string]interface{}{
+			"package": pkg.Name,
+			"version": pkg.Version,
+			"reason":  result.Reason,
+		})
+	case "verified":
+		log.Info("Registry signature verified", map[string]interface{}{
+			"package":      pkg.Name,
+			"version":      pkg.Version,
+			"keyId":        result.KeyID,
+			"attestations": len(result.Attestations),
+		})
+	}
+	return nil
+}
+
+// evaluateRegistry prüft bei Paketen aus der Registry, ob tarballData (sofern
+// vorhanden) zu dist.integrity passt (jeder SRI-Algorithmus, siehe
+// source.CheckIntegrity), ob eine der dist.signatures mit einem
+// Schlüssel der Registry gültig ist und ob die Attestierungen des Pakets gelten.
+// Das Bundle kommt aus dem Cache; fehlt es dort, wird es geladen und abgelegt,
+// außer offline.
+func (i *Installer) evaluateRegistry(reg registry.Registry, pkg types.Package, tarballData []byte) registryResult {
+	result := registryResult{Package: pkg.Name, Version: pkg.Version}
+	if pkg.Tarball == "" && pkg.Integrity == "" {
+		// Lokale Tarballs tragen keine Registry-Metadaten
+		result.Status, result.Reason = "skipped", "not from the registry"
+		return result
+	}
+	if tarballData != nil && pkg.Integrity != "" {
+		if err := source.CheckIntegrity(tarballData, pkg.Integrity); err != nil {
+			result.Status, result.Reason = "invalid", fmt.Sprintf("tarball does not match dist.integrity: %v", err)
+			return result
+		}
+	}
+	if len(pkg.Signatures) == 0 {
+		result.Status, result.Reason = "missing", "no registry signature"
+		if pkg.Integrity == "" {
+			result.Reason = "no dist.integrity recorded; reinstall the package to record it"
+		}
+		return result
+	}
+	keyID, err := signing.VerifyRegistrySignature(i.RegistryTrust.Keys, pkg)
+	if err != nil {
+		result.Status, result.Reason = "invalid", err.Error()
+		return result
+	}
+	result.Status, result.KeyID = "verified", keyID
+
+	if pkg.Attestations == nil {
+		return result
+	}
+	bundle, err := i.cache.LoadAttestations(pkg)
+	if err != nil {
+		result.Status, result.Reason = "invalid", err.Error()
+		return result
+	}
+	fetcher, ok := reg.(registry.AttestationFetcher)
+	if bundle == nil && !i.RegistryTrust.Offline && ok {
+		if bundle, err = fetcher.FetchAttestations(pkg); err != nil {
+			result.Status, result.Reason = "invalid", fmt.Sprintf("failed to fetch attestations: %v", err)
+			return result
+		}
+		if err := i.cache.StoreAttestations(pkg, bundle); err != nil {
+			result.Status, result.Reason = "invalid", err.Error()
+			return result
+		}
+	}
+	if bundle == nil {
+		// Ohne Bundle bleibt es bei der Signatur; geprüft wird, was vorliegt
+		result.Reason = "attestations not available offline"
+		return result
+	}
+	attestations, err := signing.VerifyAttestations(bundle, i.RegistryTrust.Keys, i.RegistryTrust.Roots, pkg)
+	if err != nil {
+		result.Status, result.Reason = "invalid", err.Error()
+		return result
+	}
+	result.Attestations = attestations
+	return result
+}
+
+// AuditSignatures prüft die Registry-Signaturen und Attestierungen aller Pakete
+// der Lockfile in dir anhand der im Cache abgelegten Metadaten und Bundles.
+// Pakete aus anderen Quellen werden übersprungen. Das Ergebnis gibt
+// ReportRegistry aus; ok ist false bei fehlenden oder ungültigen Signaturen.
+func (i *Installer) AuditSignatures(reg registry.Registry, dir string) (bool, error) {
+	if i.RegistryTrust == nil {
+		return false, fmt.Errorf("no registry keys loaded")
+	}
+	lf, err := lockfile.Load(dir)
+	if err != nil {
+		return false, err
+	}
+	if len(lf.Packages) == 0 {
+		return false, fmt.Errorf("no packages in %s; run \"ipm install\" first", lockfile.FileName)
+	}
+	names := make([]string, 0, len(lf.Packages))
+	for name := range lf.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ok := true
+	for _, name := range names {
+		entry := lf.Packages[name]
+		pkg := types.Package{Name: name, Version: entry.Version}
+		realName, version, alias := aliasTarget(entry.Resolved)
+		switch {
+		case alias:
+			pkg.Name, pkg.Version = realName, version
+		case entry.Resolved != "" || entry.Integrity != "":
+			i.registryResults = append(i.registryResults, registryResult{Package: name, Version: entry.Version, Status: "skipped", Reason: "not from the registry"})
+			continue
+		}
+		var result registryResult
+		if meta, err := i.cache.LoadMetadata(pkg); err != nil {
+			result = registryResult{Package: pkg.Name, Version: pkg.Version, Status: "missing", Reason: "not in the cache; reinstall the package"}
+		} else {
+			result = i.evaluateRegistry(reg, meta, nil)
+		}
+		if result.Status == "missing" || result.Status == "invalid" {
+			ok = false
+		}
+		log.Debug("Audited registry signature", map[string]interface{}{
+			"package": result.Package,
+			"version": result.Version,
+			"status":  result.Status,
+		})
+		i.registryResults = append(i.registryResults, result)
+	}
+	return ok, nil
+}
@@ -0,0 +1,275 @@
+package installer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ipm/pkg/cache"
+	"ipm/pkg/keyring"
+	"ipm/pkg/signing"
+	"ipm/pkg/types"
+)
+
+const testRegistryKeyID = "SHA256:test-registry-key"
+
+// registryFixture ist eine Registry ohne Netz: ihr Schlüssel liegt wie eine
+// Kopie von /-/npm/v1/keys in einer Datei, ihre Wurzel als PEM.
+type registryFixture struct {
+	key       *ecdsa.PrivateKey
+	keysFile  string
+	rootsFile string
+	builder   *ecdsa.PrivateKey // Schlüssel des Provenance-Zertifikats
+	cert      []byte
+}
+
+func newRegistryFixture(t *testing.T, expires *string) registryFixture {
+	t.Helper()
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := json.Marshal(map[string]interface{}{"keys": []interface{}{map[string]interface{}{
+		"keyid":   testRegistryKeyID,
+		"keytype": "ecdsa-sha2-nistp256",
+		"scheme":  "ecdsa-sha2-nistp256",
+		"key":     base64.StdEncoding.EncodeToString(der),
+		"expires": expires,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := registryFixture{key: key, keysFile: filepath.Join(dir, "keys.json"), rootsFile: filepath.Join(dir, "roots.pem")}
+	if err := os.WriteFile(f.keysFile, keys, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Selbst signiertes Zertifikat eines Build-Workflows, zugleich Wurzel
+	if f.builder, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	workflow, _ := url.Parse("https://github.com/acme/demo/.github/workflows/release.yml@refs/heads/main")
+	issuer, err := asn1.Marshal("https://token.actions.githubusercontent.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sigstore-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		URIs:                  []*url.URL{workflow},
+		ExtraExtensions:       []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}, Value: issuer}},
+	}
+	if f.cert, err = x509.CreateCertificate(rand.Reader, template, template, f.builder.Public(), f.builder); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(f.rootsFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.cert}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func sri(data []byte) string {
+	sum := sha512.Sum512(data)
+	return "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// registryPackage liefert ein Paket mit Tarball, dist.integrity und einer
+// gültigen dist.signatures-Signatur der Registry.
+func (f registryFixture) registryPackage(t *testing.T) (types.Package, []byte) {
+	t.Helper()
+	tarball := writeTarball(t, packageFiles())
+	pkg := types.Package{Name: "demo", Version: "1.0.0", Tarball: "https://registry.example/demo/-/demo-1.0.0.tgz", Integrity: sri(tarball)}
+	sig, err := signing.Sign(f.key, signing.ECDSAP256, []byte(fmt.Sprintf("%s@%s:%s", pkg.Name, pkg.Version, pkg.Integrity)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg.Signatures = []types.RegistrySignature{{KeyID: testRegistryKeyID, Sig: base64.StdEncoding.EncodeToString(sig)}}
+	return pkg, tarball
+}
+
+// attestation beschreibt eine Attestierung im Bundle.
+type attestation struct {
+	predicateType string
+	subject       string // "pkg:npm/<name>@<version>"
+	integrity     string
+	integrated    time.Time
+}
+
+func (f registryFixture) bundle(t *testing.T, attestations ...attestation) []byte {
+	t.Helper()
+	var entries []interface{}
+	for _, a := range attestations {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(a.integrity, "sha512-"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := json.Marshal(map[string]interface{}{
+			"_type":         "https://in-toto.io/Statement/v1",
+			"subject":       []interface{}{map[string]interface{}{"name": a.subject, "digest": map[string]string{"sha512": hex.EncodeToString(raw)}}},
+			"predicateType": a.predicateType,
+			"predicate": map[string]interface{}{"buildDefinition": map[string]interface{}{"externalParameters": map[string]interface{}{
+				"workflow": map[string]string{"repository": "https://github.com/acme/demo", "ref": "refs/heads/main"},
+			}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		const payloadType = "application/vnd.in-toto+json"
+		message := []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+		var signer crypto.Signer = f.key
+		material := map[string]interface{}{"publicKey": map[string]string{"hint": testRegistryKeyID}}
+		if a.predicateType == signing.ProvenancePredicate {
+			signer = f.builder
+			material = map[string]interface{}{"x509CertificateChain": map[string]interface{}{"certificates": []interface{}{map[string][]byte{"rawBytes": f.cert}}}}
+		}
+		sig, err := signing.Sign(signer, signing.ECDSAP256, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		material["tlogEntries"] = []interface{}{map[string]interface{}{"integratedTime": fmt.Sprint(a.integrated.Unix())}}
+		entries = append(entries, map[string]interface{}{
+			"predicateType": a.predicateType,
+			"bundle": map[string]interface{}{
+				"mediaType":            "application/vnd.dev.sigstore.bundle+json;version=0.2",
+				"verificationMaterial": material,
+				"dsseEnvelope": map[string]interface{}{
+					"payloadType": payloadType,
+					"payload":     payload,
+					"signatures":  []interface{}{map[string]interface{}{"keyid": "", "sig": sig}},
+				},
+			},
+		})
+	}
+	data, err := json.Marshal(map[string]interface{}{"attestations": entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEvaluateRegistryOffline(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	const subject = "pkg:npm/demo@1.0.0"
+
+	tests := []struct {
+		name    string
+		expires *string
+		// change passt Paket, Tarball und Attestierungen an
+		change func(f registryFixture, pkg *types.Package, tarball *[]byte, attestations []attestation)
+		status string
+		reason string
+	}{
+		{name: "valid", status: "verified"},
+		{
+			name: "tampered integrity",
+			change: func(f registryFixture, pkg *types.Package, tarball *[]byte, attestations []attestation) {
+				*tarball = writeTarball(t, append(packageFiles(), testFile{"package/evil.js", []byte("evil()")}))
+				pkg.Integrity = sri(*tarball)
+			},
+			status: "invalid",
+			reason: "registry signature of demo@1.0.0 is invalid",
+		},
+		{
+			name: "tarball does not match integrity",
+			change: func(f registryFixture, pkg *types.Package, tarball *[]byte, attestations []attestation) {
+				*tarball = append([]byte{}, (*tarball)[:len(*tarball)-1]...)
+			},
+			status: "invalid",
+			reason: "does not match dist.integrity",
+		},
+		{
+			name:    "expired registry key",
+			expires: &expired,
+			status:  "invalid",
+			reason:  "expired",
+		},
+		{
+			name: "provenance subject mismatch",
+			change: func(f registryFixture, pkg *types.Package, tarball *[]byte, attestations []attestation) {
+				attestations[1].subject = "pkg:npm/demo@1.0.1"
+			},
+			status: "invalid",
+			reason: "statement is not about pkg:npm/demo@1.0.0",
+		},
+		{
+			name: "provenance digest mismatch",
+			change: func(f registryFixture, pkg *types.Package, tarball *[]byte, attestations []attestation) {
+				attestations[1].integrity = sri([]byte("other"))
+			},
+			status: "invalid",
+			reason: "subject digest does not match",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			f := newRegistryFixture(t, tt.expires)
+			trust, err := keyring.LoadRegistryTrust("https://registry.example", keyring.RegistryOptions{KeysFile: f.keysFile, RootsFile: f.rootsFile, Offline: true}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := cache.NewCache()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkg, tarball := f.registryPackage(t)
+			attestations := []attestation{
+				{signing.PublishPredicate, subject, pkg.Integrity, now},
+				{signing.ProvenancePredicate, subject, pkg.Integrity, now},
+			}
+			if tt.change != nil {
+				tt.change(f, &pkg, &tarball, attestations)
+			}
+			pkg.Attestations = &types.Attestations{URL: "https://registry.example/-/npm/v1/attestations/demo@1.0.0"}
+			if err := c.StoreAttestations(pkg, f.bundle(t, attestations...)); err != nil {
+				t.Fatal(err)
+			}
+
+			i := &Installer{cache: c, RegistryTrust: trust}
+			result := i.evaluateRegistry(nil, pkg, tarball)
+			if result.Status != tt.status || !strings.Contains(result.Reason, tt.reason) {
+				t.Fatalf("evaluateRegistry() = %s (%s), want %s (%s)", result.Status, result.Reason, tt.status, tt.reason)
+			}
+			if tt.status != "verified" {
+				return
+			}
+			if result.KeyID != testRegistryKeyID || len(result.Attestations) != 2 {
+				t.Fatalf("evaluateRegistry() = %+v", result)
+			}
+			provenance := result.Attestations[1]
+			if provenance.Status != signing.AttestationVerified || !provenance.CertificateVerified || provenance.Source != "https://github.com/acme/demo@refs/heads/main" {
+				t.Errorf("provenance = %+v", provenance)
+			}
+		})
+	}
+}
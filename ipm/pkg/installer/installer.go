@@ -1,46 +1,100 @@
 package installer
 
 import (
-	"archive/tar"
 	"bytes"
-	"compress/gzip"
-	"crypto"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"ipm/pkg/cache"
+	"ipm/pkg/keyring"
+	"ipm/pkg/lifecycle"
+	"ipm/pkg/lockfile"
 	"ipm/pkg/log"
+	"ipm/pkg/overrides"
+	"ipm/pkg/platform"
+	"ipm/pkg/project"
 	"ipm/pkg/registry"
+	"ipm/pkg/semver"
 	"ipm/pkg/solver"
+	"ipm/pkg/source"
 	"ipm/pkg/types"
-
-	"github.com/Masterminds/semver/v3"
 )
 
 type Installer struct {
-	cache     *cache.Cache
-	installed map[string]string
-	solver    *solver.Solver
+	// IncludePrerelease lässt Prereleases auf jeden passenden Range zutreffen (--include-prerelease)
+	IncludePrerelease bool
+	// Overrides aus package.json ("overrides", "resolutions") und ipm.json
+	Overrides *overrides.Set
+	// Locked ist die bestehende Lockfile; sie pinnt Aliase und Tarball-Inhalte
+	Locked *lockfile.Lockfile
+	// IgnoreScripts unterdrückt alle Lifecycle-Skripte (--ignore-scripts)
+	IgnoreScripts bool
+	// ScriptPolicy legt fest, welche Abhängigkeiten Lifecycle-Skripte ausführen dürfen
+	ScriptPolicy *lifecycle.Policy
+	// ScriptConfig erscheint in Skripten als npm_config_*
+	ScriptConfig map[string]string
+	// Prefix ist das Verzeichnis, in dessen node_modules installiert wird; leer = aktuelles Verzeichnis
+	Prefix string
+	// Keyring liefert je Paket die vertrauenswürdigen Schlüssel, wenn kein --pubkey angegeben ist
+	Keyring *keyring.Keyring
+	// SignaturePolicy legt fest, ob unsignierte Pakete die Installation abbrechen
+	SignaturePolicy *keyring.Policy
+	// RegistryTrust prüft dist.signatures und Attestierungen der Registry; nil = aus
+	RegistryTrust *keyring.RegistryTrust
+
+	cache            *cache.Cache
+	installed        map[string]string
+	packages         map[string]types.Package
+	roots            map[string]string // direkt angeforderte Pakete → Versionsangabe
+	depth            int               // Rekursionstiefe von Install, 1 = direkt angefordert
+	skipped          []lockfile.Skipped
+	optionalDepth    int    // > 0, solange eine optionalDependency installiert wird
+	base             string // Verzeichnis des Pakets, dessen Abhängigkeiten gerade installiert werden; leer für das Projekt
+	solver           *solver.Solver
+	sources          *source.Sources
+	pinned           map[string]*source.Resolved // Pakete aus anderen Quellen als der Standard-Registry
+	project          *project.Workspace          // gesetzt von InstallProject
+	digests          map[string]string           // Inhaltsdigest je Paket für die Lockfile
+	scriptResults    []scriptResult
+	signatureResults []signatureResult
+	installedResults []installedResult
+	registryResults  []registryResult
 }
 
 func NewInstaller(reg registry.Registry) *Installer {
 	c, _ := cache.NewCache()
+	sources := source.New(reg)
+	s := solver.NewSolver(reg)
+	s.Sources = sources
 	return &Installer{
 		cache:     c,
 		installed: make(map[string]string),
-		solver:    solver.NewSolver(reg),
+		packages:  make(map[string]types.Package),
+		roots:     make(map[string]string),
+		solver:    s,
+		sources:   sources,
+		pinned:    make(map[string]*source.Resolved),
+		digests:   make(map[string]string),
+
+		ScriptPolicy:    &lifecycle.Policy{Mode: lifecycle.Allowlist},
+		SignaturePolicy: &keyring.Policy{Mode: keyring.Warn},
 	}
 }
 
+// modulesDir liefert das node_modules-Verzeichnis, in das installiert wird.
+func (i *Installer) modulesDir() string {
+	return filepath.Join(i.Prefix, "node_modules")
+}
+
 func (i *Installer) Install(reg registry.Registry, pkgSpec string, jsonOutput bool, pubKeyFile string) error {
+	i.depth++
+	defer func() { i.depth-- }()
+
 	// Prüfe, ob pkgSpec eine lokale Datei ist
 	if _, err := os.Stat(pkgSpec); err == nil {
 		log.Debug("Detected local package file", map[string]interface{}{
@@ -58,19 +112,27 @@ func (i *Installer) Install(reg registry.Registry, pkgSpec string, jsonOutput bo
 			return fmt.Errorf("failed to read local tarball: %v", err)
 		}
 
-		// Signatur prüfen
-		if pubKeyFile != "" {
-			if err := verifyTarball(tarballData, pubKeyFile); err != nil {
-				return err
-			}
-		}
-
 		// Metadaten extrahieren
 		pkg, err := extractPackageMetadata(tarballData)
 		if err != nil {
 			return fmt.Errorf("failed to extract package metadata: %v", err)
 		}
 
+		// Signatur prüfen, eingebettet oder abgelöst in <datei>.sig
+		detached, err := i.verifyTarball(reg, pkg, tarballData, pkgSpec, pubKeyFile)
+		if err != nil {
+			return err
+		}
+		if detached != nil {
+			if err := i.cache.StoreSignature(pkg, detached); err != nil {
+				return err
+			}
+		}
+
+		if i.depth == 1 {
+			i.roots[pkg.Name] = pkgSpec
+		}
+
 		// Installation fortsetzen
 		return i.installLocalPackage(reg, pkg, tarballData, jsonOutput, pubKeyFile)
 	}
@@ -85,9 +147,17 @@ func (i *Installer) Install(reg registry.Registry, pkgSpec string, jsonOutput bo
 		"package": name,
 		"version": version,
 	})
+	i.sources.Locked = i.Locked
+	if !source.IsRegistry(version) {
+		return i.installSourceSpec(reg, source.Parse(name, version), jsonOutput, pubKeyFile)
+	}
+	if i.depth == 1 {
+		i.roots[name] = version
+	}
 
+	// Mit Overrides muss der Solver jede Kante sehen, daher keine Abkürzung über den Cache
 	pkg := types.Package{Name: name, Version: version}
-	if i.cache.Exists(pkg) {
+	if i.cache.Exists(pkg) && i.Overrides.Empty() {
 		var err error
 		pkg, err = i.cache.LoadMetadata(pkg)
 		if err != nil {
@@ -100,19 +170,17 @@ func (i *Installer) Install(reg registry.Registry, pkgSpec string, jsonOutput bo
 		return i.installCachedDep(reg, pkg, jsonOutput, pubKeyFile)
 	}
 
-	if err := i.solver.AddPackage(name, version); err != nil {
-		log.Error("Failed to analyze dependencies", err, map[string]interface{}{
-			"package": name,
-			"version": version,
-		})
+	// Tiefere Aufrufe kommen aus installDependency; deren Teilbaum hat der Solver
+	// bereits beim direkt angeforderten Paket aufgelöst
+	if err := i.solve(name, version, jsonOutput); err != nil {
 		return err
 	}
-	if i.solver.HasConflicts() {
-		i.reportConflicts(jsonOutput)
-		os.Exit(1)
+	if resolved, ok := i.solver.ResolvedVersion(name); ok {
+		version = resolved
+		pkg.Version = resolved
 	}
 
-	if version != "latest" && (version[0] == '~' || version[0] == '^' || version[0] == '>') {
+	if !isExactVersion(version) {
 		resolvedVersion, err := reg.ResolveVersion(name, version)
 		if err != nil {
 			log.Error("Failed to resolve version", err, map[string]interface{}{
@@ -153,18 +221,29 @@ func (i *Installer) Install(reg registry.Registry, pkgSpec string, jsonOutput bo
 	}
 	defer tarballReader.Close()
 
-	if pubKeyFile != "" {
-		tarballData, err := io.ReadAll(tarballReader)
-		if err != nil {
-			return fmt.Errorf("failed to read tarball: %v", err)
-		}
-		if err := verifyTarball(tarballData, pubKeyFile); err != nil {
+	tarballData, err := io.ReadAll(tarballReader)
+	if err != nil {
+		return fmt.Errorf("failed to read tarball: %v", err)
+	}
+	detached, err := i.verifyTarball(reg, fetchedPkg, tarballData, "", pubKeyFile)
+	if err != nil {
+		return err
+	}
+	if err := i.checkRegistry(reg, fetchedPkg, tarballData); err != nil {
+		return err
+	}
+	if detached != nil {
+		if err := i.cache.StoreSignature(fetchedPkg, detached); err != nil {
 			return err
 		}
-		tarballReader = io.NopCloser(bytes.NewReader(tarballData))
 	}
+	tarballReader = io.NopCloser(bytes.NewReader(tarballData))
 
 	pkg = fetchedPkg
+	if err := i.checkPlatform(pkg); err != nil {
+		return err
+	}
+	warnDeprecated(pkg)
 	cachedPath, err := i.cache.Store(pkg, tarballReader)
 	if err != nil {
 		log.Error("Failed to store package in cache", err, map[string]interface{}{
@@ -173,8 +252,11 @@ func (i *Installer) Install(reg registry.Registry, pkgSpec string, jsonOutput bo
 		})
 		return err
 	}
+	if err := i.recordContent(name, cachedPath, tarballData); err != nil {
+		return err
+	}
 
-	pkgDir := filepath.Join("node_modules")
+	pkgDir := i.modulesDir()
 	if err := os.MkdirAll(pkgDir, 0755); err != nil {
 		log.Error("Failed to create node_modules directory", err, map[string]interface{}{
 			"dir": pkgDir,
@@ -191,6 +273,7 @@ func (i *Installer) Install(reg registry.Registry, pkgSpec string, jsonOutput bo
 	}
 
 	i.installed[name] = pkg.Version
+	i.packages[name] = pkg
 	log.Info("Package installed", map[string]interface{}{
 		"package": pkg.Name,
 		"version": pkg.Version,
@@ -198,15 +281,258 @@ func (i *Installer) Install(reg registry.Registry, pkgSpec string, jsonOutput bo
 	})
 	fmt.Printf("Installed %s@%s to %s\n", pkg.Name, pkg.Version, cachedPath)
 
-	for depName, depVersion := range pkg.Deps {
-		if err := i.installDependency(reg, depName, depVersion, jsonOutput, pubKeyFile); err != nil {
+	return i.installDependencies(reg, pkg, "", jsonOutput, pubKeyFile)
+}
+
+// InstallProject installiert die Abhängigkeiten des Projekts in dir samt aller
+// Workspace-Mitglieder. Alles wird in einem Solver-Lauf gemeinsam aufgelöst, damit
+// die Ein-Versionen-Regel im ganzen Workspace gilt. Mitglieder werden wie
+// "file:"-Verzeichnisse nach node_modules verlinkt, auch untereinander.
+func (i *Installer) InstallProject(reg registry.Registry, dir string, jsonOutput bool, pubKeyFile string) error {
+	ws, err := project.LoadWorkspace(dir)
+	if err != nil {
+		return err
+	}
+	i.depth++
+	defer func() { i.depth-- }()
+	i.project = ws
+	if err := i.runProjectScripts(dir, []string{"preinstall"}, os.Stdout); err != nil {
+		return err
+	}
+	i.sources.Locked = i.Locked
+	i.solver.IncludePrerelease = i.IncludePrerelease
+	i.solver.Overrides = i.Overrides
+
+	members := make(map[string]*source.Resolved, len(ws.Members))
+	for _, member := range ws.Members {
+		spec := "file:" + filepath.ToSlash(filepath.Join(dir, member.Dir))
+		res, err := i.sources.Resolve(source.Parse(member.Manifest.Name, spec))
+		if err != nil {
+			return fmt.Errorf("failed to load workspace member %s: %v", member.Dir, err)
+		}
+		members[member.Manifest.Name] = res
+		i.roots[member.Manifest.Name] = spec
+	}
+	i.solver.Workspace = members
+
+	root := types.Package{Name: ws.Root.Name, Version: ws.Root.Version}
+	requiredBy := fmt.Sprintf("%s@%s", root.Name, root.Version)
+	required := make(map[string]string)
+	for _, deps := range []map[string]string{ws.Root.DevDependencies, ws.Root.Dependencies} {
+		for name, spec := range deps {
+			if _, optional := ws.Root.OptionalDependencies[name]; !optional {
+				required[name] = spec
+			}
+		}
+	}
+	for name, spec := range required {
+		i.roots[name] = spec
+	}
+	for name, spec := range ws.Root.OptionalDependencies {
+		i.roots[name] = spec
+	}
+	log.Info("Installing project", map[string]interface{}{
+		"project":  requiredBy,
+		"members":  len(members),
+		"required": len(required),
+		"optional": len(ws.Root.OptionalDependencies),
+	})
+
+	// Ein gemeinsamer Solver-Lauf für Mitglieder und Projekt-Abhängigkeiten
+	for _, name := range sortedMembers(members) {
+		if err := i.solver.AddPackage(name, members[name].Spec.Raw); err != nil {
+			return fmt.Errorf("failed to analyze workspace member %s: %v", name, err)
+		}
+	}
+	for _, name := range sortedSpecs(required) {
+		if err := i.solver.AddPackage(name, required[name]); err != nil {
+			log.Error("Failed to analyze dependencies", err, map[string]interface{}{
+				"package": name,
+				"version": required[name],
+			})
+			return err
+		}
+	}
+	for _, name := range sortedSpecs(ws.Root.OptionalDependencies) {
+		i.solver.AddOptional(requiredBy, name, ws.Root.OptionalDependencies[name])
+	}
+	if err := i.solver.ResolvePeers(); err != nil {
+		log.Error("Failed to resolve peer dependencies", err, map[string]interface{}{
+			"project": requiredBy,
+		})
+		return err
+	}
+	if i.solver.HasConflicts() {
+		i.reportConflicts(jsonOutput)
+		os.Exit(1)
+	}
+
+	for _, name := range sortedMembers(members) {
+		if err := i.installDependency(reg, name, members[name].Spec.Raw, jsonOutput, pubKeyFile); err != nil {
 			return err
 		}
 	}
+	for _, name := range sortedSpecs(required) {
+		if err := i.installDependency(reg, name, required[name], jsonOutput, pubKeyFile); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedSpecs(ws.Root.OptionalDependencies) {
+		i.installOptionalDependency(reg, root, name, ws.Root.OptionalDependencies[name], jsonOutput, pubKeyFile)
+	}
+	return nil
+}
+
+func sortedMembers(m map[string]*source.Resolved) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedSpecs(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
+// solve löst den Teilbaum von name mit dem Solver auf. Tiefere Aufrufe kommen aus
+// installDependency; deren Teilbaum hat der Solver bereits beim direkt
+// angeforderten Paket aufgelöst.
+func (i *Installer) solve(name, version string, jsonOutput bool) error {
+	if _, known := i.solver.ResolvedVersion(name); i.depth > 1 && known {
+		return nil
+	}
+	i.solver.IncludePrerelease = i.IncludePrerelease
+	i.solver.Overrides = i.Overrides
+	if err := i.solver.AddPackage(name, version); err != nil {
+		log.Error("Failed to analyze dependencies", err, map[string]interface{}{
+			"package": name,
+			"version": version,
+		})
+		return err
+	}
+	if err := i.solver.ResolvePeers(); err != nil {
+		log.Error("Failed to resolve peer dependencies", err, map[string]interface{}{
+			"package": name,
+			"version": version,
+		})
+		return err
+	}
+	if i.solver.HasConflicts() {
+		i.reportConflicts(jsonOutput)
+		os.Exit(1)
+	}
 	return nil
 }
 
+// installSourceSpec installiert eine direkt angeforderte Angabe, die nicht auf die
+// Standard-Registry verweist. Ohne Namen ergibt er sich aus der package.json der Quelle.
+func (i *Installer) installSourceSpec(reg registry.Registry, spec source.Spec, jsonOutput bool, pubKeyFile string) error {
+	res, err := i.sources.Resolve(spec)
+	if err != nil {
+		log.Error("Failed to resolve package source", err, map[string]interface{}{
+			"spec": spec.Raw,
+		})
+		return err
+	}
+	name := res.Name()
+	if i.depth == 1 {
+		i.roots[name] = spec.Raw
+	}
+	if err := i.solve(name, spec.Raw, jsonOutput); err != nil {
+		return err
+	}
+	return i.installDependency(reg, name, spec.Raw, jsonOutput, pubKeyFile)
+}
+
+// installSource installiert ein Paket aus einer festgelegten Quelle unter name.
+// Tarballs landen im Cache, Verzeichnisse (file:, link:) werden direkt verlinkt.
+func (i *Installer) installSource(reg registry.Registry, name string, res *source.Resolved, jsonOutput bool, pubKeyFile string) error {
+	if existingVersion, ok := i.installed[name]; ok {
+		log.Debug("Dependency already installed", map[string]interface{}{
+			"package":  name,
+			"version":  existingVersion,
+			"resolved": res.Resolved,
+		})
+		return nil
+	}
+
+	pkg := res.Package
+	if err := i.checkPlatform(pkg); err != nil {
+		return err
+	}
+	warnDeprecated(pkg)
+
+	fmt.Printf("Installing %s from %s...\n", name, res.Resolved)
+	target := res.Dir
+	if target == "" {
+		sigPkg, file := res.Package, ""
+		switch res.Spec.Kind {
+		case source.File:
+			file = res.Spec.Path()
+		case source.Tarball:
+			sigPkg.Tarball = res.Spec.Location
+		}
+		if _, err := i.verifyTarball(reg, sigPkg, res.TarballData(), file, pubKeyFile); err != nil {
+			return err
+		}
+		var err error
+		if res.Spec.Kind == source.Alias {
+			target, err = i.cache.Store(pkg, res.Tarball())
+		} else {
+			target, err = i.cache.StoreSource(pkg, res.Integrity, res.Tarball())
+		}
+		if err != nil {
+			log.Error("Failed to store package in cache", err, map[string]interface{}{
+				"package":  name,
+				"resolved": res.Resolved,
+			})
+			return err
+		}
+		if err := i.recordContent(name, target, res.TarballData()); err != nil {
+			return err
+		}
+	}
+
+	pkgDir := i.modulesDir()
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		log.Error("Failed to create node_modules directory", err, map[string]interface{}{
+			"dir": pkgDir,
+		})
+		return err
+	}
+	if err := i.cache.LinkPath(pkg, target, pkgDir, name); err != nil {
+		log.Error("Failed to link package", err, map[string]interface{}{
+			"package":  name,
+			"resolved": res.Resolved,
+		})
+		return err
+	}
+
+	i.installed[name] = pkg.Version
+	i.packages[name] = pkg
+	i.pinned[name] = res
+	log.Info("Package installed", map[string]interface{}{
+		"package":  name,
+		"version":  pkg.Version,
+		"resolved": res.Resolved,
+		"path":     target,
+	})
+	fmt.Printf("Installed %s@%s to %s\n", name, pkg.Version, target)
+
+	// Verlinkte Pakete verwalten ihre Abhängigkeiten selbst
+	if res.Spec.Kind == source.Link {
+		return nil
+	}
+	return i.installDependencies(reg, pkg, res.Dir, jsonOutput, pubKeyFile)
+}
+
 func (i *Installer) installLocalPackage(reg registry.Registry, pkg types.Package, tarballData []byte, jsonOutput bool, pubKeyFile string) error {
 	if existingVersion, ok := i.installed[pkg.Name]; ok {
 		if existingVersion != pkg.Version {
@@ -220,6 +546,9 @@ func (i *Installer) installLocalPackage(reg registry.Registry, pkg types.Package
 		return nil
 	}
 
+	if err := i.checkPlatform(pkg); err != nil {
+		return err
+	}
 	cachedPath, err := i.cache.Store(pkg, io.NopCloser(bytes.NewReader(tarballData)))
 	if err != nil {
 		log.Error("Failed to store package in cache", err, map[string]interface{}{
@@ -228,8 +557,11 @@ func (i *Installer) installLocalPackage(reg registry.Registry, pkg types.Package
 		})
 		return err
 	}
+	if err := i.recordContent(pkg.Name, cachedPath, tarballData); err != nil {
+		return err
+	}
 
-	pkgDir := filepath.Join("node_modules")
+	pkgDir := i.modulesDir()
 	if err := os.MkdirAll(pkgDir, 0755); err != nil {
 		log.Error("Failed to create node_modules directory", err, map[string]interface{}{
 			"dir": pkgDir,
@@ -246,6 +578,7 @@ func (i *Installer) installLocalPackage(reg registry.Registry, pkg types.Package
 	}
 
 	i.installed[pkg.Name] = pkg.Version
+	i.packages[pkg.Name] = pkg
 	log.Info("Package installed", map[string]interface{}{
 		"package": pkg.Name,
 		"version": pkg.Version,
@@ -253,150 +586,44 @@ func (i *Installer) installLocalPackage(reg registry.Registry, pkg types.Package
 	})
 	fmt.Printf("Installed %s@%s to %s\n", pkg.Name, pkg.Version, cachedPath)
 
-	for depName, depVersion := range pkg.Deps {
-		if err := i.installDependency(reg, depName, depVersion, jsonOutput, pubKeyFile); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return i.installDependencies(reg, pkg, "", jsonOutput, pubKeyFile)
 }
 
-func verifyTarball(tarballData []byte, pubKeyFile string) error {
-	pubKeyData, err := os.ReadFile(pubKeyFile)
-	if err != nil {
-		return fmt.Errorf("failed to read public key: %v", err)
-	}
-	block, _ := pem.Decode(pubKeyData)
-	if block == nil {
-		return fmt.Errorf("invalid public key format")
-	}
-
-	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("failed to parse public key: %v", err)
-	}
-
-	publicKey, ok := pubKey.(*rsa.PublicKey)
-	if !ok {
-		return fmt.Errorf("public key is not an RSA key")
-	}
-
-	gzr, err := gzip.NewReader(bytes.NewReader(tarballData))
-	if err != nil {
-		return fmt.Errorf("failed to read gzip: %v", err)
-	}
-	defer gzr.Close()
-
-	tr := tar.NewReader(gzr)
-	var signature []byte
-	var unsignedData []byte
-
-	tempFile, err := os.CreateTemp("", "unsigned-*.tgz")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %v", err)
-	}
-	defer os.Remove(tempFile.Name())
-
-	gw := gzip.NewWriter(tempFile)
-	defer gw.Close()
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
+func extractPackageMetadata(tarballData []byte) (types.Package, error) {
+	return source.ReadTarballManifest(tarballData)
+}
 
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tarball: %v", err)
-		}
-		if hdr.Name == "signature.sig" {
-			signature, err = io.ReadAll(tr)
-			if err != nil {
-				return fmt.Errorf("failed to read signature: %v", err)
-			}
-			continue
-		}
-		if err := tw.WriteHeader(hdr); err != nil {
-			return fmt.Errorf("failed to write header: %v", err)
-		}
-		_, err = io.Copy(tw, tr)
-		if err != nil {
-			return fmt.Errorf("failed to copy file: %v", err)
-		}
+func (i *Installer) installDependency(reg registry.Registry, depName, depVersion string, jsonOutput bool, pubKeyFile string) error {
+	if res, ok := i.solver.ResolvedSource(depName); ok {
+		return i.installSource(reg, depName, res, jsonOutput, pubKeyFile)
 	}
 
-	tw.Close()
-	gw.Close()
-	tempFile.Close()
-
-	if signature == nil {
-		log.Warn("Package is not signed", map[string]interface{}{
-			"file": "downloaded tarball",
+	// Die vom Solver gewählte Version hat Vorrang, damit Overrides und die
+	// Ein-Versionen-Regel auch bei der Installation gelten
+	if resolved, ok := i.solver.ResolvedVersion(depName); ok && resolved != depVersion {
+		log.Debug("Using version chosen by solver", map[string]interface{}{
+			"package": depName,
+			"range":   depVersion,
+			"version": resolved,
 		})
-		return nil
-	}
-
-	unsignedData, err = os.ReadFile(tempFile.Name())
-	if err != nil {
-		return fmt.Errorf("failed to read unsigned tarball: %v", err)
-	}
-
-	hash := sha256.Sum256(unsignedData)
-	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature)
-	if err != nil {
-		return fmt.Errorf("package signature verification failed: %v", err)
-	}
-
-	log.Info("Package signature verified", map[string]interface{}{
-		"file": "downloaded tarball",
-	})
-	return nil
-}
-
-func extractPackageMetadata(tarballData []byte) (types.Package, error) {
-	gzr, err := gzip.NewReader(bytes.NewReader(tarballData))
-	if err != nil {
-		return types.Package{}, fmt.Errorf("failed to read gzip: %v", err)
+		depVersion = resolved
 	}
-	defer gzr.Close()
 
-	tr := tar.NewReader(gzr)
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
+	// Alias, URL, file: und link: ohne Solver-Ergebnis direkt aus der Quelle installieren
+	if !source.IsRegistry(depVersion) {
+		res, err := i.sources.Resolve(source.ParseIn(i.base, depName, depVersion))
 		if err != nil {
-			return types.Package{}, fmt.Errorf("failed to read tarball: %v", err)
-		}
-		if strings.HasSuffix(hdr.Name, "package.json") {
-			data, err := io.ReadAll(tr)
-			if err != nil {
-				return types.Package{}, fmt.Errorf("failed to read package.json: %v", err)
-			}
-			var pkg struct {
-				Name         string            `json:"name"`
-				Version      string            `json:"version"`
-				Dependencies map[string]string `json:"dependencies"`
-			}
-			if err := json.Unmarshal(data, &pkg); err != nil {
-				return types.Package{}, fmt.Errorf("failed to parse package.json: %v", err)
-			}
-			return types.Package{
-				Name:    pkg.Name,
-				Version: pkg.Version,
-				Deps:    pkg.Dependencies,
-			}, nil
+			log.Error("Failed to resolve dependency source", err, map[string]interface{}{
+				"package": depName,
+				"spec":    depVersion,
+			})
+			return err
 		}
+		return i.installSource(reg, depName, res, jsonOutput, pubKeyFile)
 	}
-	return types.Package{}, fmt.Errorf("package.json not found in tarball")
-}
 
-func (i *Installer) installDependency(reg registry.Registry, depName, depVersion string, jsonOutput bool, pubKeyFile string) error {
 	if installedVersion, ok := i.installed[depName]; ok {
-		if satisfiesVersion(installedVersion, depVersion) {
+		if i.satisfiesVersion(installedVersion, depVersion) {
 			log.Debug("Using already installed dependency version", map[string]interface{}{
 				"package": depName,
 				"version": installedVersion,
@@ -414,7 +641,7 @@ func (i *Installer) installDependency(reg registry.Registry, depName, depVersion
 				cachedDep.Version = v
 				if i.cache.Exists(cachedDep) {
 					cachedDep, err = i.cache.LoadMetadata(cachedDep)
-					if err == nil && satisfiesVersion(cachedDep.Version, depVersion) {
+					if err == nil && i.satisfiesVersion(cachedDep.Version, depVersion) {
 						log.Debug("Using cached dependency version directly", map[string]interface{}{
 							"package": depName,
 							"version": cachedDep.Version,
@@ -468,8 +695,21 @@ func (i *Installer) installCachedDep(reg registry.Registry, pkg types.Package, j
 		return nil
 	}
 
+	if err := i.checkPlatform(pkg); err != nil {
+		return err
+	}
+	warnDeprecated(pkg)
+	if err := i.verifyCached(reg, pkg, pubKeyFile); err != nil {
+		return err
+	}
+	if err := i.checkRegistry(reg, pkg, nil); err != nil {
+		return err
+	}
 	cachedPath := filepath.Join(i.cache.CacheDir, fmt.Sprintf("%s-%s", pkg.Name, pkg.Version))
-	pkgDir := filepath.Join("node_modules")
+	if err := i.recordContent(pkg.Name, cachedPath, nil); err != nil {
+		return err
+	}
+	pkgDir := i.modulesDir()
 	if err := os.MkdirAll(pkgDir, 0755); err != nil {
 		log.Error("Failed to create node_modules directory", err, map[string]interface{}{
 			"dir": pkgDir,
@@ -486,27 +726,256 @@ func (i *Installer) installCachedDep(reg registry.Registry, pkg types.Package, j
 	}
 
 	i.installed[pkg.Name] = pkg.Version
+	i.packages[pkg.Name] = pkg
 	log.Info("Cached dependency installed", map[string]interface{}{
 		"package": pkg.Name,
 		"version": pkg.Version,
 		"path":    cachedPath,
 	})
 
+	return i.installDependencies(reg, pkg, "", jsonOutput, pubKeyFile)
+}
+
+// installDependencies installiert die dependencies, optionalDependencies und
+// peerDependencies von pkg. dir ist sein Verzeichnis bei file:, sonst leer;
+// relative file:- und link:-Angaben gelten wie bei npm relativ dazu.
+func (i *Installer) installDependencies(reg registry.Registry, pkg types.Package, dir string, jsonOutput bool, pubKeyFile string) error {
+	parentBase := i.base
+	i.base = dir
+	defer func() { i.base = parentBase }()
+
 	for depName, depVersion := range pkg.Deps {
+		// npm führt optionale Abhängigkeiten zusätzlich unter "dependencies" auf
+		if _, optional := pkg.OptionalDeps[depName]; optional {
+			continue
+		}
 		if err := i.installDependency(reg, depName, depVersion, jsonOutput, pubKeyFile); err != nil {
 			return err
 		}
 	}
 
+	optional := make([]string, 0, len(pkg.OptionalDeps))
+	for depName := range pkg.OptionalDeps {
+		optional = append(optional, depName)
+	}
+	sort.Strings(optional)
+	for _, depName := range optional {
+		i.installOptionalDependency(reg, pkg, depName, pkg.OptionalDeps[depName], jsonOutput, pubKeyFile)
+	}
+
+	return i.installPeerDependencies(reg, pkg, jsonOutput, pubKeyFile)
+}
+
+// installOptionalDependency installiert eine optionalDependency. Scheitert die
+// Auflösung, der Download oder die Plattformprüfung, werden die dabei angelegten
+// Links entfernt und die Abhängigkeit mit einer Warnung übersprungen.
+func (i *Installer) installOptionalDependency(reg registry.Registry, parent types.Package, depName, depVersion string, jsonOutput bool, pubKeyFile string) {
+	requiredBy := fmt.Sprintf("%s@%s", parent.Name, parent.Version)
+	for _, skipped := range i.solver.Skipped() {
+		if skipped.Name == depName && skipped.RequiredBy == requiredBy {
+			i.skipOptional(depName, depVersion, requiredBy, fmt.Errorf("%s", skipped.Reason))
+			return
+		}
+	}
+
+	before := make(map[string]bool, len(i.installed))
+	for name := range i.installed {
+		before[name] = true
+	}
+	for name := range i.digests {
+		before[name] = true
+	}
+	signatures, registrySignatures := len(i.signatureResults), len(i.registryResults)
+
+	i.optionalDepth++
+	err := i.installDependency(reg, depName, depVersion, jsonOutput, pubKeyFile)
+	i.optionalDepth--
+	if err == nil {
+		return
+	}
+
+	// Auch Pakete, die vor dem Verlinken scheiterten, haben schon einen Digest
+	added := map[string]bool{depName: !before[depName]}
+	for _, names := range []map[string]string{i.installed, i.digests} {
+		for name := range names {
+			added[name] = added[name] || !before[name]
+		}
+	}
+	for name, rollback := range added {
+		if !rollback {
+			continue
+		}
+		if _, ok := i.installed[name]; ok {
+			if rmErr := i.cache.Unlink(i.modulesDir(), name); rmErr != nil {
+				log.Error("Failed to remove link of skipped optional dependency", rmErr, map[string]interface{}{
+					"package": name,
+				})
+			}
+		}
+		delete(i.installed, name)
+		delete(i.packages, name)
+		delete(i.pinned, name)
+		delete(i.digests, name)
+		i.solver.Forget(name)
+	}
+	// Lockfile und Signaturberichte beschreiben nur installierte Pakete; der Grund
+	// steht beim übersprungenen Eintrag
+	i.signatureResults = i.signatureResults[:signatures]
+	i.registryResults = i.registryResults[:registrySignatures]
+	i.skipOptional(depName, depVersion, requiredBy, err)
+}
+
+func (i *Installer) skipOptional(depName, depVersion, requiredBy string, reason error) {
+	log.Warn("Skipping optional dependency", map[string]interface{}{
+		"package":    depName,
+		"range":      depVersion,
+		"requiredBy": requiredBy,
+		"reason":     reason.Error(),
+	})
+	fmt.Fprintf(os.Stderr, "Warning: skipping optional dependency %s@%s of %s: %v\n", depName, depVersion, requiredBy, reason)
+	i.skipped = append(i.skipped, lockfile.Skipped{
+		Name:       depName,
+		Range:      depVersion,
+		RequiredBy: requiredBy,
+		Reason:     reason.Error(),
+	})
+}
+
+// checkPlatform prüft os, cpu und engines. Eine unpassende Engine ist für
+// reguläre Abhängigkeiten nur eine Warnung, für optionale ein Grund zum Überspringen.
+func (i *Installer) checkPlatform(pkg types.Package) error {
+	if err := platform.Check(pkg); err != nil {
+		return err
+	}
+	if err := platform.CheckEngines(pkg); err != nil {
+		if i.optionalDepth > 0 {
+			return err
+		}
+		log.Warn("Unsupported engine", map[string]interface{}{
+			"package": pkg.Name,
+			"version": pkg.Version,
+			"engines": pkg.Engines,
+		})
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
+
+// WriteLockfile überträgt die installierten Pakete und die auf dieser Plattform
+// übersprungenen optionalen Abhängigkeiten in die Lockfile in dir.
+func (i *Installer) WriteLockfile(dir string) error {
+	lf, err := lockfile.Load(dir)
+	if err != nil {
+		return err
+	}
+	for name, pkg := range i.packages {
+		entry := lockfile.Package{
+			Version:              pkg.Version,
+			Dependencies:         pkg.Deps,
+			OptionalDependencies: pkg.OptionalDeps,
+			PeerDependencies:     pkg.PeerDeps,
+		}
+		if res, ok := i.pinned[name]; ok {
+			entry.Resolved = res.Resolved
+			entry.Integrity = res.Integrity
+			if res.Package.Name != name {
+				entry.Name = res.Package.Name
+			}
+		}
+		entry.Digest = i.digests[name]
+		lf.Packages[name] = entry
+	}
+	if lf.Dependencies == nil {
+		lf.Dependencies = make(map[string]string)
+	}
+	for name, spec := range i.roots {
+		lf.Dependencies[name] = spec
+		delete(lf.Overridden, name)
+	}
+	for _, edge := range i.solver.Overridden() {
+		override := lockfile.Override{From: edge.From, To: edge.To, Rule: edge.Rule}
+		if edge.Parent.Name == "" {
+			if lf.Overridden == nil {
+				lf.Overridden = make(map[string]lockfile.Override)
+			}
+			lf.Overridden[edge.Name] = override
+			continue
+		}
+		parent, ok := lf.Packages[edge.Parent.Name]
+		if !ok || parent.Version != edge.Parent.Version {
+			continue
+		}
+		if parent.Overridden == nil {
+			parent.Overridden = make(map[string]lockfile.Override)
+		}
+		parent.Overridden[edge.Name] = override
+		lf.Packages[edge.Parent.Name] = parent
+	}
+	lf.SetSkipped(platform.Current(), i.skipped)
+	if err := lf.Save(dir); err != nil {
+		return err
+	}
+	log.Debug("Lockfile written", map[string]interface{}{
+		"path":     filepath.Join(dir, lockfile.FileName),
+		"packages": len(lf.Packages),
+		"skipped":  len(i.skipped),
+	})
 	return nil
 }
 
-func satisfiesVersion(version, rangeSpec string) bool {
+// installPeerDependencies installiert die peerDependencies von pkg in der Version,
+// die der Solver für das gesamte Projekt gewählt hat. Optionale Peers werden nur
+// installiert, wenn sie ohnehin Teil der Auflösung sind.
+func (i *Installer) installPeerDependencies(reg registry.Registry, pkg types.Package, jsonOutput bool, pubKeyFile string) error {
+	peers := make([]string, 0, len(pkg.PeerDeps))
+	for peer := range pkg.PeerDeps {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+
+	for _, peer := range peers {
+		peerRange := pkg.PeerDeps[peer]
+		if version, ok := i.solver.ResolvedVersion(peer); ok {
+			peerRange = version
+		} else if pkg.PeerDepsMeta[peer].Optional {
+			continue
+		}
+		log.Debug("Installing peer dependency", map[string]interface{}{
+			"package": pkg.Name,
+			"peer":    peer,
+			"range":   peerRange,
+		})
+		if err := i.installDependency(reg, peer, peerRange, jsonOutput, pubKeyFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isExactVersion meldet, ob spec eine konkrete Version ist. Ranges und
+// dist-tags wie "latest" oder "beta" müssen erst aufgelöst werden.
+func isExactVersion(spec string) bool {
+	return semver.Valid(spec)
+}
+
+func warnDeprecated(pkg types.Package) {
+	if pkg.Deprecated == "" {
+		return
+	}
+	log.Warn("Package is deprecated", map[string]interface{}{
+		"package":    pkg.Name,
+		"version":    pkg.Version,
+		"deprecated": pkg.Deprecated,
+	})
+	fmt.Fprintf(os.Stderr, "Warning: %s@%s is deprecated: %s\n", pkg.Name, pkg.Version, pkg.Deprecated)
+}
+
+func (i *Installer) satisfiesVersion(version, rangeSpec string) bool {
 	if rangeSpec == "latest" {
 		return false
 	}
 
-	ver, err := semver.NewVersion(version)
+	ver, err := semver.Parse(version)
 	if err != nil {
 		log.Debug("Invalid version format, treating as exact match", map[string]interface{}{
 			"version": version,
@@ -514,7 +983,7 @@ func satisfiesVersion(version, rangeSpec string) bool {
 		return version == rangeSpec
 	}
 
-	constraint, err := semver.NewConstraint(rangeSpec)
+	constraint, err := semver.ParseRangeWithOptions(rangeSpec, semver.Options{IncludePrerelease: i.IncludePrerelease})
 	if err != nil {
 		log.Debug("Invalid range format, treating as exact match", map[string]interface{}{
 			"range": rangeSpec,
@@ -522,7 +991,7 @@ func satisfiesVersion(version, rangeSpec string) bool {
 		return version == rangeSpec
 	}
 
-	return constraint.Check(ver)
+	return constraint.Test(ver)
 }
 
 func (i *Installer) reportConflicts(jsonOutput bool) {
@@ -532,6 +1001,7 @@ func (i *Installer) reportConflicts(jsonOutput bool) {
 			Package    string   `json:"package"`
 			Versions   []string `json:"versions"`
 			Dependents []string `json:"dependents"`
+			Peer       bool     `json:"peer,omitempty"`
 		}
 		output := struct {
 			Message   string           `json:"message"`
@@ -547,6 +1017,7 @@ func (i *Installer) reportConflicts(jsonOutput bool) {
 				Package:    c.Package,
 				Versions:   c.Versions,
 				Dependents: c.Dependents,
+				Peer:       c.Peer,
 			}
 		}
 		jsonData, _ := json.MarshalIndent(output, "", "  ")
@@ -554,6 +1025,13 @@ func (i *Installer) reportConflicts(jsonOutput bool) {
 	} else {
 		fmt.Println("Installation failed due to dependency conflicts:")
 		for _, conflict := range i.solver.GetConflicts() {
+			if conflict.Peer {
+				fmt.Printf("- Peer conflict at '%s':\n", conflict.Package)
+				fmt.Printf("  Resolved version: %s\n", conflict.Versions[0])
+				fmt.Printf("  Required by %s: %s\n", conflict.Dependents[1], conflict.Versions[1])
+				fmt.Printf("  Hint: install a version of %s that satisfies %s, or upgrade %s\n", conflict.Package, conflict.Versions[1], conflict.Dependents[1])
+				continue
+			}
 			fmt.Printf("- Conflict at '%s':\n", conflict.Package)
 			fmt.Printf("  Versions requested: %v\n", conflict.Versions)
 			fmt.Printf("  Dependents: %v\n", conflict.Dependents)
@@ -563,12 +1041,15 @@ func (i *Installer) reportConflicts(jsonOutput bool) {
 	log.Error("Unresolvable dependency conflicts detected", nil)
 }
 
+// parsePackageSpec trennt "name@version" und berücksichtigt Scoped-Namen wie
+// "@acme/plc@1". URLs, "file:" und "link:" tragen keinen Namen; Aliase werden
+// als "name@npm:other@^1" angegeben.
 func parsePackageSpec(spec string) (name, version string) {
-	parts := []rune(spec)
-	for i, r := range parts {
-		if r == '@' {
-			return string(parts[:i]), string(parts[i+1:])
-		}
+	if spec == "" || !source.IsRegistry(spec) {
+		return "", spec
+	}
+	if idx := strings.Index(spec[1:], "@"); idx >= 0 {
+		return spec[:idx+1], spec[idx+2:]
 	}
 	return spec, ""
-}
\ No newline at end of file
+}
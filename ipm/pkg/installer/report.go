@@ -0,0 +1,241 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"ipm/pkg/signing"
+)
+
+// Report fasst nach der Installation Lifecycle-Skripte und Signaturprüfung
+// zusammen, als Text oder als ein JSON-Objekt {"scripts": [...], "signatures": [...]},
+// mit --registry-signatures zusätzlich "registry": [...].
+func (i *Installer) Report(jsonOutput bool) {
+	if jsonOutput {
+		scripts := i.scriptResults
+		if scripts == nil {
+			scripts = []scriptResult{}
+		}
+		signatures := i.signatureResults
+		if signatures == nil {
+			signatures = []signatureResult{}
+		}
+		report := map[string]interface{}{"scripts": scripts, "signatures": signatures}
+		if i.RegistryTrust != nil {
+			report["registry"] = i.registryPackages()
+		}
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	i.reportScripts()
+	i.reportSignatures()
+	if i.RegistryTrust != nil {
+		i.reportRegistry()
+	}
+}
+
+// ReportRegistry gibt das Ergebnis von AuditSignatures aus, als Text oder als
+// JSON-Objekt {"packages": [...]}.
+func (i *Installer) ReportRegistry(jsonOutput bool) {
+	if jsonOutput {
+		data, _ := json.MarshalIndent(map[string]interface{}{"packages": i.registryPackages()}, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	i.reportRegistry()
+}
+
+func (i *Installer) registryPackages() []registryResult {
+	if i.registryResults == nil {
+		return []registryResult{}
+	}
+	return i.registryResults
+}
+
+// reportRegistry fasst wie "npm audit signatures" zusammen, wie viele Pakete eine
+// gültige Registry-Signatur und Attestierungen haben, und listet die übrigen.
+func (i *Installer) reportRegistry() {
+	counts := map[string]int{}
+	attested := 0
+	for _, r := range i.registryResults {
+		counts[r.Status]++
+		for _, a := range r.Attestations {
+			if a.Status == signing.AttestationVerified {
+				attested++
+				break
+			}
+		}
+	}
+	fmt.Printf("Audited %d packages: %d verified registry signatures, %d verified attestations\n",
+		len(i.registryResults)-counts["skipped"], counts["verified"], attested)
+	if counts["skipped"] > 0 {
+		fmt.Printf("%d packages skipped (not from the registry)\n", counts["skipped"])
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range i.registryResults {
+		if r.Status == "skipped" {
+			continue
+		}
+		detail := r.Reason
+		if r.Status == "verified" {
+			detail = "key " + r.KeyID
+			for _, a := range r.Attestations {
+				detail += ", " + attestationName(a)
+			}
+			if r.Reason != "" {
+				detail += " (" + r.Reason + ")"
+			}
+		}
+		fmt.Fprintf(w, "  %s@%s\t%s\t%s\n", r.Package, r.Version, r.Status, detail)
+	}
+	w.Flush()
+	if counts["missing"] > 0 {
+		fmt.Printf("%d packages have missing registry signatures\n", counts["missing"])
+	}
+	if counts["invalid"] > 0 {
+		fmt.Printf("%d packages have invalid registry signatures or attestations\n", counts["invalid"])
+	}
+}
+
+// attestationName beschreibt eine geprüfte Attestierung kurz, bei Provenance mit
+// Identität und Quelle des Builds, sofern ihr Zertifikat geprüft ist.
+func attestationName(a signing.Attestation) string {
+	name := "provenance"
+	if a.PredicateType != signing.ProvenancePredicate {
+		name = "publish attestation"
+	}
+	if a.Status == signing.AttestationUnverified {
+		return name + " unverified (no trusted root for its certificate)"
+	}
+	if a.Source != "" {
+		name += " from " + a.Source
+	}
+	if a.Identity != "" {
+		name += " by " + a.Identity
+	}
+	return name
+}
+
+// reportSignatures listet jedes Paket mit seinem Prüfstatus. Solange weder eine
+// Richtlinie gesetzt ist noch ein Schlüssel gegriffen hat, bleibt sie stumm.
+func (i *Installer) reportSignatures() {
+	counts := map[string]int{}
+	for _, r := range i.signatureResults {
+		counts[r.Status]++
+	}
+	if len(i.signatureResults) == 0 || (i.SignaturePolicy.Source == "" && counts["verified"]+counts["unsigned"]+counts["invalid"] == 0) {
+		return
+	}
+
+	policy := i.SignaturePolicy.Mode
+	if len(i.SignaturePolicy.Scopes) > 0 {
+		policy += " with per-scope rules"
+	}
+	if len(i.SignaturePolicy.Thresholds) > 0 {
+		policy += ", signature thresholds"
+	}
+	fmt.Printf("Signatures (policy %s): %d verified, %d unsigned, %d unverified, %d skipped\n",
+		policy, counts["verified"], counts["unsigned"], counts["unverified"], counts["skipped"])
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range i.signatureResults {
+		detail := r.Reason
+		if r.Status == "verified" {
+			detail = signersText(r)
+			if r.Detached {
+				detail += ", detached"
+			}
+		}
+		if r.Policy != i.SignaturePolicy.Mode {
+			detail += fmt.Sprintf(" (policy %s)", r.Policy)
+		}
+		fmt.Fprintf(w, "  %s@%s\t%s\t%s\n", r.Package, r.Version, r.Status, detail)
+	}
+	w.Flush()
+}
+
+// signersText beschreibt die zählenden Signaturen eines geprüften Pakets, z. B.
+// "alice@build, key alice (valid); key bob (valid); 2 of 2 required signatures from
+// group release (alice, bob)".
+func signersText(r signatureResult) string {
+	var parts []string
+	for _, s := range r.Signatures {
+		if s.Reason != "" {
+			continue
+		}
+		text := "key " + s.Key.String()
+		if s.Signer != "" {
+			text = s.Signer + ", " + text
+		}
+		parts = append(parts, text)
+	}
+	if r.Threshold != nil {
+		parts = append(parts, r.Threshold.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ReportInstalled gibt das Ergebnis von VerifyInstalled aus, als Text oder als
+// JSON-Objekt {"packages": [...]}. Abweichende Pakete folgen mit allen Befunden.
+func (i *Installer) ReportInstalled(jsonOutput bool) {
+	if jsonOutput {
+		packages := i.installedResults
+		if packages == nil {
+			packages = []installedResult{}
+		}
+		data, _ := json.MarshalIndent(map[string]interface{}{"packages": packages}, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	counts := map[string]int{}
+	for _, r := range i.installedResults {
+		counts[r.Status]++
+	}
+	summary := fmt.Sprintf("%d ok", counts["ok"])
+	for _, status := range []string{"unchecked", "modified", "invalid", "missing", "extraneous"} {
+		if counts[status] > 0 {
+			summary += fmt.Sprintf(", %d %s", counts[status], status)
+		}
+	}
+	fmt.Printf("Verified %d installed packages: %s\n", len(i.installedResults), summary)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range i.installedResults {
+		signature := ""
+		if s := r.Signature; s != nil {
+			signature = "signature " + s.Status
+			if s.Status == "verified" {
+				signature += ", " + signersText(*s)
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", installedName(r), r.Status, signature)
+	}
+	w.Flush()
+
+	for _, r := range i.installedResults {
+		if r.Status == "ok" {
+			continue
+		}
+		fmt.Printf("%s:\n", installedName(r))
+		for _, problem := range r.Problems {
+			fmt.Printf("  %s\n", problem)
+		}
+		for _, change := range r.Changes {
+			if change.Kind == "mode" {
+				fmt.Printf("    %-9s %s (now %s)\n", change.Kind, change.Path, change.Mode)
+				continue
+			}
+			fmt.Printf("    %-9s %s\n", change.Kind, change.Path)
+		}
+	}
+}
+
+func installedName(r installedResult) string {
+	if r.Version == "" {
+		return r.Package
+	}
+	return r.Package + "@" + r.Version
+}
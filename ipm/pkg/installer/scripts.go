@@ -0,0 +1,190 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ipm/pkg/lifecycle"
+	"ipm/pkg/log"
+	"ipm/pkg/project"
+	"ipm/pkg/runner"
+	"ipm/pkg/shell"
+	"ipm/pkg/source"
+)
+
+// scriptResult ist das Ergebnis eines Lifecycle-Skripts für den Bericht.
+type scriptResult struct {
+	Package  string `json:"package"`
+	Version  string `json:"version"`
+	Event    string `json:"event"`
+	Script   string `json:"script"`
+	Status   string `json:"status"` // "ok", "failed" oder "skipped"
+	ExitCode int    `json:"exitCode,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// RunLifecycleScripts führt nach der Installation preinstall, install und
+// postinstall der installierten Pakete in Abhängigkeitsreihenfolge aus, danach die
+// des Projekts. prepare läuft nur für das Projekt, Workspace-Mitglieder und
+// git-Abhängigkeiten. Abhängigkeiten brauchen die Erlaubnis der ScriptPolicy;
+// nach dem ersten fehlgeschlagenen Skript wird abgebrochen.
+func (i *Installer) RunLifecycleScripts(dir string, jsonOutput bool) error {
+	if i.IgnoreScripts {
+		log.Info("Lifecycle scripts disabled (--ignore-scripts)")
+		return nil
+	}
+	// Bei JSON-Ausgabe gehört stdout dem Bericht
+	out := io.Writer(os.Stdout)
+	if jsonOutput {
+		out = os.Stderr
+	}
+
+	err := i.runPackageScripts(dir, out)
+	if err == nil && i.project != nil {
+		events := append(append([]string{}, lifecycle.InstallEvents[1:]...), "prepare")
+		err = i.runProjectScripts(dir, events, out)
+	}
+	return err
+}
+
+func (i *Installer) runPackageScripts(dir string, out io.Writer) error {
+	for _, name := range lifecycle.Order(i.packages) {
+		pkg := i.packages[name]
+		pkgDir := filepath.Join(dir, "node_modules", name)
+		scripts, err := lifecycle.Scripts(pkgDir)
+		if err != nil {
+			log.Warn("Failed to read lifecycle scripts", map[string]interface{}{
+				"package": name,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		events := lifecycle.InstallEvents
+		_, member := i.solver.Workspace[name]
+		if res, ok := i.pinned[name]; member || (ok && res.Spec.Kind == source.Git) {
+			events = append(append([]string{}, events...), "prepare")
+		}
+
+		// Workspace-Mitglieder gehören zum Projekt und sind vertrauenswürdig
+		allowed := member || i.ScriptPolicy.Allows(pkg.Name, pkg.Version)
+		for _, event := range events {
+			script, ok := scripts[event]
+			if !ok {
+				continue
+			}
+			if !allowed {
+				i.scriptResults = append(i.scriptResults, scriptResult{
+					Package: name, Version: pkg.Version, Event: event, Script: script,
+					Status: "skipped", Reason: "not allowed by script policy",
+				})
+				continue
+			}
+			if err := i.runScript(name, pkg.Version, event, script, pkgDir, dir, out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runProjectScripts führt Lifecycle-Skripte des Projekts selbst aus.
+func (i *Installer) runProjectScripts(dir string, events []string, out io.Writer) error {
+	if i.IgnoreScripts || i.project == nil {
+		return nil
+	}
+	for _, event := range events {
+		script, ok := i.project.Root.Scripts[event]
+		if !ok || script == "" {
+			continue
+		}
+		if err := i.runScript(i.project.Root.Name, i.project.Root.Version, event, script, dir, dir, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Installer) runScript(name, version, event, script, pkgDir, rootDir string, out io.Writer) error {
+	manifest, err := project.LoadManifest(pkgDir)
+	if err != nil || manifest == nil {
+		manifest = &project.Manifest{Name: name, Version: version}
+	}
+	fmt.Fprintf(out, "> %s@%s %s: %s\n", name, version, event, script)
+	log.Info("Running lifecycle script", map[string]interface{}{
+		"package": name,
+		"version": version,
+		"event":   event,
+		"script":  script,
+	})
+
+	result := scriptResult{Package: name, Version: version, Event: event, Script: script, Status: "ok"}
+	err = runner.Exec(pkgDir, script, runner.Env(manifest, pkgDir, rootDir, event, script, i.ScriptConfig), out, os.Stderr)
+	if err != nil {
+		result.Status = "failed"
+		result.ExitCode = 1
+		var exitErr *shell.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.Code
+		}
+		result.Reason = err.Error()
+		log.Error("Lifecycle script failed", err, map[string]interface{}{
+			"package": name,
+			"version": version,
+			"event":   event,
+		})
+	}
+	i.scriptResults = append(i.scriptResults, result)
+	if err != nil {
+		return fmt.Errorf("%s script of %s@%s failed: %v", event, name, version, err)
+	}
+	return nil
+}
+
+// reportScripts fasst die ausgeführten und übersprungenen Skripte zusammen.
+func (i *Installer) reportScripts() {
+	if len(i.scriptResults) == 0 {
+		return
+	}
+
+	var ok, failed int
+	var skipped []string
+	var allow []string
+	for _, r := range i.scriptResults {
+		switch r.Status {
+		case "ok":
+			ok++
+		case "failed":
+			failed++
+		case "skipped":
+			skipped = append(skipped, fmt.Sprintf("  %s@%s %s: %s", r.Package, r.Version, r.Event, r.Script))
+			if spec := r.Package + "@" + r.Version; !contains(allow, spec) {
+				allow = append(allow, spec)
+			}
+		}
+	}
+	fmt.Printf("Lifecycle scripts: %d succeeded, %d failed, %d skipped\n", ok, failed, len(skipped))
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Printf("Skipped scripts not allowed by the script policy (%s):\n%s\n", i.ScriptPolicy.Mode, strings.Join(skipped, "\n"))
+	if i.ScriptPolicy.Mode == lifecycle.Allowlist {
+		quoted := make([]string, len(allow))
+		for idx, spec := range allow {
+			quoted[idx] = fmt.Sprintf("%q", spec)
+		}
+		fmt.Printf("Hint: to run them, add %s to \"scripts\": {\"allow\": [...]} in %s\n", strings.Join(quoted, ", "), project.ConfigFile)
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, entry := range list {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
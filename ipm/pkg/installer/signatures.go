@@ -0,0 +1,278 @@
+package installer
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ipm/pkg/keyring"
+	"ipm/pkg/log"
+	"ipm/pkg/registry"
+	"ipm/pkg/signing"
+	"ipm/pkg/types"
+)
+
+// signatureResult ist der Prüfstatus eines Pakets für die Zusammenfassung.
+type signatureResult struct {
+	Package  string `json:"package"`
+	Version  string `json:"version"`
+	Policy   string `json:"policy"`
+	Status   string `json:"status"` // "verified", "unsigned", "unverified", "invalid", "insufficient" oder "skipped"
+	Signer   string `json:"signer,omitempty"`
+	KeyID    string `json:"keyId,omitempty"`
+	Detached bool   `json:"detached,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Key ist der Schlüssel, der gültig signiert hat, mit seinem heutigen Zustand
+	Key *keyring.KeyState `json:"key,omitempty"`
+	// Signatures sind alle Signaturen vertrauenswürdiger Schlüssel, auch bei
+	// mehreren Freigaben
+	Signatures []signerResult `json:"signatures,omitempty"`
+	// Threshold ist gesetzt, wenn die Richtlinie Signaturen einer Gruppe verlangt
+	Threshold *thresholdResult `json:"threshold,omitempty"`
+}
+
+// signerResult ist eine kryptographisch gültige Signatur eines vertrauenswürdigen
+// Schlüssels. Reason nennt, warum sie trotzdem nicht zählt, etwa weil der
+// Schlüssel gesperrt ist.
+type signerResult struct {
+	Signer string           `json:"signer,omitempty"`
+	KeyID  string           `json:"keyId"`
+	Key    keyring.KeyState `json:"key"`
+	Reason string           `json:"reason,omitempty"`
+}
+
+// thresholdResult hält fest, welche Mitglieder einer Gruppe gültig signiert haben.
+type thresholdResult struct {
+	Group    string   `json:"group"`
+	Required int      `json:"required"`
+	Members  int      `json:"members"`
+	Signed   []string `json:"signed"`
+}
+
+// String beschreibt den Stand, z. B. "2 of 2 required signatures from group release (alice, bob)".
+func (t *thresholdResult) String() string {
+	text := fmt.Sprintf("%d of %d required signatures from group %s", len(t.Signed), t.Required, t.Group)
+	if len(t.Signed) > 0 {
+		text += " (" + strings.Join(t.Signed, ", ") + ")"
+	}
+	return text
+}
+
+// verificationKeys liefert die Schlüssel, gegen die das Paket name geprüft wird:
+// der aus --pubkey für alle Pakete, sonst die für seinen Scope oder Namen
+// vertrauenswürdigen Schlüssel des Keyrings.
+func (i *Installer) verificationKeys(name, pubKeyFile string) ([]crypto.PublicKey, error) {
+	if pubKeyFile != "" {
+		publicKey, err := signing.LoadPublicKey(pubKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return []crypto.PublicKey{publicKey}, nil
+	}
+	return keyring.PublicKeys(i.Keyring.Trusted(name)), nil
+}
+
+// checkSignature wendet die SignaturePolicy auf ein Paket an (siehe
+// evaluateSignature) und hält das Ergebnis für die Zusammenfassung fest.
+func (i *Installer) checkSignature(pkg types.Package, pubKeyFile string, verify func([]crypto.PublicKey) ([]*signing.Metadata, bool, error)) error {
+	result, metas, err := i.evaluateSignature(pkg, pubKeyFile, verify)
+	i.signatureResults = append(i.signatureResults, result)
+	if err != nil {
+		return err
+	}
+	switch result.Status {
+	case "unsigned":
+		log.Warn("Package is not signed", map[string]interface{}{
+			"package": pkg.Name,
+			"version": pkg.Version,
+		})
+	case "verified":
+		for idx, meta := range metas {
+			signer := result.Signatures[idx]
+			if signer.Reason != "" {
+				log.Warn("Package signature does not count", map[string]interface{}{
+					"package": pkg.Name,
+					"version": pkg.Version,
+					"keyId":   meta.KeyID,
+					"reason":  signer.Reason,
+				})
+				continue
+			}
+			log.Info("Package signature verified", map[string]interface{}{
+				"package":   pkg.Name,
+				"version":   pkg.Version,
+				"detached":  result.Detached,
+				"algorithm": meta.Algorithm,
+				"keyId":     meta.KeyID,
+				"signer":    meta.Signer,
+				"key":       signer.Key.String(),
+			})
+			fmt.Printf("Verified signature of %s@%s: %s; key %s\n", pkg.Name, pkg.Version, meta, signer.Key)
+		}
+		if result.Threshold != nil {
+			fmt.Printf("  %s\n", result.Threshold)
+		}
+	}
+	return nil
+}
+
+// evaluateSignature prüft ein Paket nach der SignaturePolicy; verify prüft die
+// Signaturen gegen die vertrauenswürdigen Schlüssel. Ungültige Signaturen ergeben
+// immer einen Fehler, fehlende Signaturen und Schlüssel nur unter "required".
+// Signaturen gesperrter Schlüssel und solche außerhalb des Gültigkeitszeitraums
+// zählen nicht; gilt keine Signatur, ist das ein Fehler. Verlangt die Richtlinie
+// für das Paket eine Schwelle, müssen genug Mitglieder der Gruppe gültig signiert
+// haben; ihre Schlüssel werden dafür mitgeprüft, sofern nicht --pubkey gilt.
+func (i *Installer) evaluateSignature(pkg types.Package, pubKeyFile string, verify func([]crypto.PublicKey) ([]*signing.Metadata, bool, error)) (signatureResult, []*signing.Metadata, error) {
+	result := signatureResult{Package: pkg.Name, Version: pkg.Version, Policy: i.SignaturePolicy.ModeFor(pkg.Name)}
+	if result.Policy == keyring.Off {
+		result.Status, result.Reason = "skipped", "signature checks are off"
+		return result, nil, nil
+	}
+
+	keys, err := i.verificationKeys(pkg.Name, pubKeyFile)
+	if err != nil {
+		result.Status, result.Reason = "invalid", err.Error()
+		return result, nil, err
+	}
+	threshold := i.SignaturePolicy.ThresholdFor(pkg.Name)
+	var members []keyring.Member
+	if threshold != nil {
+		if members, err = i.SignaturePolicy.Members(threshold.Group, i.Keyring); err != nil {
+			result.Status, result.Reason = "invalid", err.Error()
+			return result, nil, err
+		}
+		result.Threshold = &thresholdResult{Group: threshold.Group, Required: threshold.Required, Members: len(members), Signed: []string{}}
+		if pubKeyFile == "" {
+			for _, member := range members {
+				keys = append(keys, member.Key.Public())
+			}
+		}
+	}
+	if len(keys) == 0 {
+		result.Status, result.Reason = "unverified", "no trusted key"
+		if result.Policy == keyring.Required {
+			return result, nil, fmt.Errorf("%s@%s cannot be verified: no trusted key for it (signatures are required; see \"ipm keys trust\")", pkg.Name, pkg.Version)
+		}
+		return result, nil, nil
+	}
+
+	metas, detached, err := verify(keys)
+	if err != nil {
+		result.Status, result.Reason = "invalid", err.Error()
+		return result, nil, fmt.Errorf("signature verification of %s@%s failed: %v", pkg.Name, pkg.Version, err)
+	}
+	if len(metas) == 0 {
+		result.Status, result.Reason = "unsigned", "no signature"
+		if threshold != nil {
+			return result, nil, fmt.Errorf("%s@%s is not signed (%d signatures from group %s are required)", pkg.Name, pkg.Version, threshold.Required, threshold.Group)
+		}
+		if result.Policy == keyring.Required {
+			return result, nil, fmt.Errorf("%s@%s is not signed (signatures are required)", pkg.Name, pkg.Version)
+		}
+		return result, nil, nil
+	}
+
+	result.Detached = detached
+	var invalid error
+	for _, meta := range metas {
+		state, err := i.Keyring.Validity(meta.KeyID, meta.SignedAt())
+		signer := signerResult{Signer: meta.Signer, KeyID: meta.KeyID, Key: state}
+		if err != nil {
+			signer.Reason = err.Error()
+			if invalid == nil {
+				invalid = err
+			}
+		} else if result.Key == nil {
+			result.Signer, result.KeyID, result.Key = meta.Signer, meta.KeyID, &state
+		}
+		result.Signatures = append(result.Signatures, signer)
+	}
+	if result.Key == nil {
+		first := result.Signatures[0]
+		result.Signer, result.KeyID, result.Key = first.Signer, first.KeyID, &first.Key
+		result.Status, result.Reason = "invalid", invalid.Error()
+		return result, nil, fmt.Errorf("signature of %s@%s is not valid: %v", pkg.Name, pkg.Version, invalid)
+	}
+
+	if threshold != nil {
+		// Gezählt werden verschiedene Schlüssel, nicht Einträge der Gruppe
+		counted := map[string]bool{}
+		for _, s := range result.Signatures {
+			if s.Reason != "" || counted[s.KeyID] {
+				continue
+			}
+			for _, member := range members {
+				if member.Key.ID == s.KeyID {
+					counted[s.KeyID] = true
+					result.Threshold.Signed = append(result.Threshold.Signed, member.Ref)
+				}
+			}
+		}
+		if len(result.Threshold.Signed) < threshold.Required {
+			result.Status, result.Reason = "insufficient", result.Threshold.String()
+			return result, nil, fmt.Errorf("%s@%s has %s", pkg.Name, pkg.Version, result.Threshold)
+		}
+	}
+	result.Status = "verified"
+	return result, metas, nil
+}
+
+// verifyTarball prüft die Signatur eines heruntergeladenen oder lokalen Tarballs.
+// Eine abgelöste Signatur (siehe detachedSignature) hat Vorrang vor der
+// eingebetteten; sie wird geliefert, damit sie im Cache abgelegt werden kann.
+func (i *Installer) verifyTarball(reg registry.Registry, pkg types.Package, tarballData []byte, file, pubKeyFile string) ([]byte, error) {
+	var detached []byte
+	err := i.checkSignature(pkg, pubKeyFile, func(keys []crypto.PublicKey) ([]*signing.Metadata, bool, error) {
+		var err error
+		if detached, err = detachedSignature(reg, pkg, file); err != nil {
+			return nil, false, err
+		}
+		metas, err := signing.VerifyPackageAll(tarballData, detached, keys)
+		return metas, detached != nil, err
+	})
+	return detached, err
+}
+
+// verifyCached prüft ein Paket im Cache anhand der dort abgelegten abgelösten
+// Signatur oder der eingebetteten. Fehlen beide, wird die abgelöste Signatur
+// erneut von der Registry geladen.
+func (i *Installer) verifyCached(reg registry.Registry, pkg types.Package, pubKeyFile string) error {
+	dir := i.cache.Dir(pkg)
+	return i.checkSignature(pkg, pubKeyFile, func(keys []crypto.PublicKey) ([]*signing.Metadata, bool, error) {
+		detached, err := i.cache.LoadSignature(pkg)
+		if err != nil {
+			return nil, false, err
+		}
+		if _, statErr := os.Stat(filepath.Join(signing.SignatureDir(dir), signing.SignatureFile)); detached == nil && os.IsNotExist(statErr) {
+			if detached, err = detachedSignature(reg, pkg, ""); err != nil {
+				return nil, false, err
+			}
+			if detached != nil {
+				if err := i.cache.StoreSignature(pkg, detached); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+		manifest, err := i.cachedManifest(dir)
+		if err != nil {
+			return nil, false, err
+		}
+		metas, err := signing.VerifyManifestAll(manifest, dir, detached, keys)
+		return metas, detached != nil, err
+	})
+}
+
+// detachedSignature sucht eine abgelöste Signatur: <file>.sig bei lokalen Dateien,
+// sonst <tarball-URL>.sig, sofern die Registry das unterstützt.
+func detachedSignature(reg registry.Registry, pkg types.Package, file string) ([]byte, error) {
+	if file != "" {
+		return signing.ReadDetached(file)
+	}
+	if fetcher, ok := reg.(registry.SignatureFetcher); ok {
+		return fetcher.FetchSignature(pkg)
+	}
+	return nil, nil
+}
@@ -0,0 +1,233 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"ipm/pkg/keyring"
+	"ipm/pkg/signing"
+	"ipm/pkg/types"
+)
+
+var testPackage = types.Package{Name: "demo", Version: "1.0.0"}
+
+// testFile ist ein Eintrag eines Test-Tarballs.
+type testFile struct {
+	name string
+	data []byte
+}
+
+func writeTarball(t *testing.T, files []testFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func packageFiles() []testFile {
+	return []testFile{
+		{"package/package.json", []byte(`{"name":"demo","version":"1.0.0"}`)},
+		{"package/index.js", []byte("module.exports = 1\n")},
+	}
+}
+
+// testSigner ist ein Schlüsselpaar mit seinem Eintrag im Keyring.
+type testSigner struct {
+	key   crypto.Signer
+	entry *keyring.Key
+}
+
+func newSigner(t *testing.T, name string) testSigner {
+	t.Helper()
+	key, err := signing.GenerateKey(signing.KeyTypeEd25519, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData, err := signing.MarshalPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := keyring.NewKey(name, pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.Trust("*"); err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{key: key, entry: entry}
+}
+
+// signed signiert den Test-Tarball samt Metadaten zum Zeitpunkt at.
+func (s testSigner) signed(t *testing.T, at time.Time) []byte {
+	t.Helper()
+	tgz, _, err := signing.SignTarball(writeTarball(t, packageFiles()), s.key, signing.SignOptions{Time: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tgz
+}
+
+// manifestOnly signiert nur das Manifest, ohne Schlüssel-ID und mit unsigniertem
+// Zeitstempel at, wie es ein Angreifer mit einem gesperrten Schlüssel könnte.
+func (s testSigner) manifestOnly(t *testing.T, at time.Time) []byte {
+	t.Helper()
+	manifest, err := signing.TarballManifest(writeTarball(t, packageFiles()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signing.Sign(s.key, signing.Ed25519, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := json.Marshal(signing.Metadata{Algorithm: signing.Ed25519, Digest: manifest.Digest(), Timestamp: at.UTC().Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeTarball(t, append(packageFiles(), testFile{signing.SignatureFile, signature}, testFile{signing.MetadataFile, meta}))
+}
+
+func evaluate(t *testing.T, i *Installer, tgz []byte) (signatureResult, error) {
+	t.Helper()
+	result, _, err := i.evaluateSignature(testPackage, "", func(keys []crypto.PublicKey) ([]*signing.Metadata, bool, error) {
+		metas, err := signing.VerifyPackageAll(tgz, nil, keys)
+		return metas, false, err
+	})
+	return result, err
+}
+
+func TestEvaluateSignatureKeyValidity(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-48 * time.Hour)
+	expiredAt := now.Add(-24 * time.Hour)
+
+	valid := newSigner(t, "valid")
+	revoked := newSigner(t, "revoked")
+	expired := newSigner(t, "expired")
+	if err := expired.entry.SetValidity(time.Time{}, expiredAt); err != nil {
+		t.Fatal(err)
+	}
+	ring := &keyring.Keyring{
+		Keys:        []*keyring.Key{valid.entry, revoked.entry, expired.entry},
+		Revocations: []keyring.Revocation{{KeyID: revoked.entry.ID, RevokedAt: now.UTC().Format(time.RFC3339), Reason: "compromised"}},
+	}
+
+	tests := []struct {
+		name   string
+		tgz    []byte
+		status string
+	}{
+		{"valid key", valid.signed(t, now), "verified"},
+		{"missing key ID", valid.manifestOnly(t, now), "verified"},
+		{"revoked key", revoked.signed(t, past), "invalid"},
+		{"revoked key without key ID", revoked.manifestOnly(t, past), "invalid"},
+		{"expired key, signed before expiry", expired.signed(t, past), "verified"},
+		{"expired key, signed after expiry", expired.signed(t, now), "invalid"},
+		{"expired key, unsigned backdated timestamp", expired.manifestOnly(t, past), "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Installer{Keyring: ring, SignaturePolicy: &keyring.Policy{Mode: keyring.Warn}}
+			result, err := evaluate(t, i, tt.tgz)
+			if result.Status != tt.status {
+				t.Fatalf("status = %q (%s), want %q", result.Status, result.Reason, tt.status)
+			}
+			if (err != nil) != (tt.status != "verified") {
+				t.Fatalf("err = %v for status %q", err, result.Status)
+			}
+			if tt.status == "verified" && result.KeyID == "" {
+				t.Error("verified signature names no key")
+			}
+		})
+	}
+}
+
+// cosigned signiert den Test-Tarball mit dem ersten Schlüssel und lässt die
+// übrigen mitsignieren.
+func cosigned(t *testing.T, signers ...testSigner) []byte {
+	t.Helper()
+	tgz := signers[0].signed(t, time.Now())
+	for _, s := range signers[1:] {
+		var err error
+		if tgz, _, err = signing.CosignTarball(tgz, s.key, signing.SignOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tgz
+}
+
+func TestEvaluateSignatureThreshold(t *testing.T) {
+	alice, bob, carol := newSigner(t, "alice"), newSigner(t, "bob"), newSigner(t, "carol")
+	revoked := newSigner(t, "revoked")
+	outsider := newSigner(t, "outsider")
+	ring := &keyring.Keyring{
+		Keys:        []*keyring.Key{alice.entry, bob.entry, carol.entry, revoked.entry, outsider.entry},
+		Revocations: []keyring.Revocation{{KeyID: revoked.entry.ID, RevokedAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), Reason: "compromised"}},
+	}
+	policy := &keyring.Policy{
+		Mode:       keyring.Warn,
+		Groups:     map[string][]string{"release": {"alice", "bob", "carol", revoked.entry.ID}},
+		Thresholds: map[string]keyring.Threshold{"*": {Group: "release", Required: 2}},
+	}
+
+	tests := []struct {
+		name      string
+		tgz       []byte
+		duplicate bool // die erste Signatur wird ein zweites Mal gemeldet
+		status    string
+		signed    []string
+	}{
+		{name: "M-1 signatures", tgz: cosigned(t, alice), status: "insufficient", signed: []string{"alice"}},
+		{name: "M signatures", tgz: cosigned(t, alice, bob), status: "verified", signed: []string{"alice", "bob"}},
+		{name: "all members", tgz: cosigned(t, carol, alice, bob), status: "verified", signed: []string{"carol", "alice", "bob"}},
+		{name: "duplicate signature of one key", tgz: cosigned(t, alice), duplicate: true, status: "insufficient", signed: []string{"alice"}},
+		{name: "non-member key", tgz: cosigned(t, alice, outsider), status: "insufficient", signed: []string{"alice"}},
+		{name: "revoked member", tgz: cosigned(t, alice, revoked), status: "insufficient", signed: []string{"alice"}},
+		{name: "M signatures besides a revoked member", tgz: cosigned(t, revoked, alice, bob), status: "verified", signed: []string{"alice", "bob"}},
+		{name: "only a revoked member", tgz: cosigned(t, revoked), status: "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Installer{Keyring: ring, SignaturePolicy: policy}
+			result, _, err := i.evaluateSignature(testPackage, "", func(keys []crypto.PublicKey) ([]*signing.Metadata, bool, error) {
+				metas, err := signing.VerifyPackageAll(tt.tgz, nil, keys)
+				if err == nil && tt.duplicate {
+					metas = append(metas, metas[0])
+				}
+				return metas, false, err
+			})
+			if result.Status != tt.status {
+				t.Fatalf("status = %q (%s), want %q", result.Status, result.Reason, tt.status)
+			}
+			if (err != nil) != (tt.status != "verified") {
+				t.Fatalf("err = %v for status %q", err, result.Status)
+			}
+			if tt.signed == nil {
+				return
+			}
+			if result.Threshold == nil || result.Threshold.Members != 4 || strings.Join(result.Threshold.Signed, ",") != strings.Join(tt.signed, ",") {
+				t.Errorf("threshold = %+v, want signatures from %v", result.Threshold, tt.signed)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"ipm/pkg/cache"
+	"ipm/pkg/lockfile"
+	"ipm/pkg/log"
+	"ipm/pkg/project"
+)
+
+// Uninstall entfernt ein direkt installiertes Paket aus dir/node_modules samt seiner
+// "bin"-Einträge. Abhängigkeiten, die danach kein anderes Paket mehr braucht, werden
+// ebenfalls entfernt; die Lockfile und die package.json (dependencies,
+// devDependencies, optionalDependencies) werden entsprechend aktualisiert, damit
+// die nächste Installation das Paket nicht zurückholt. Geliefert werden die
+// entfernten Pakete.
+func Uninstall(dir, name string) ([]string, error) {
+	c, err := cache.NewCache()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache: %v", err)
+	}
+	lf, err := lockfile.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	modules := filepath.Join(dir, "node_modules")
+
+	if _, direct := lf.Dependencies[name]; !direct {
+		if _, locked := lf.Packages[name]; locked {
+			return nil, fmt.Errorf("%s is not a direct dependency; it is required by other packages", name)
+		}
+		if _, err := os.Lstat(filepath.Join(modules, name)); err != nil {
+			return nil, fmt.Errorf("%s is not installed", name)
+		}
+		// Nicht in der Lockfile, z. B. nach einer abgebrochenen Installation
+		if err := removeFromManifest(dir, name); err != nil {
+			return nil, err
+		}
+		if err := c.Unlink(modules, name); err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+
+	if err := removeFromManifest(dir, name); err != nil {
+		return nil, err
+	}
+	delete(lf.Dependencies, name)
+	delete(lf.Overridden, name)
+	reachable := make(map[string]bool)
+	var visit func(string)
+	visit = func(current string) {
+		if reachable[current] {
+			return
+		}
+		reachable[current] = true
+		pkg := lf.Packages[current]
+		for _, deps := range []map[string]string{pkg.Dependencies, pkg.OptionalDependencies, pkg.PeerDependencies} {
+			for dep := range deps {
+				if _, ok := lf.Packages[dep]; ok {
+					visit(dep)
+				}
+			}
+		}
+	}
+	for dep := range lf.Dependencies {
+		visit(dep)
+	}
+
+	var removed []string
+	for pkgName := range lf.Packages {
+		if !reachable[pkgName] {
+			removed = append(removed, pkgName)
+		}
+	}
+	sort.Strings(removed)
+	for _, pkgName := range removed {
+		if err := c.Unlink(modules, pkgName); err != nil {
+			return nil, err
+		}
+		delete(lf.Packages, pkgName)
+		log.Debug("Package removed", map[string]interface{}{
+			"package": pkgName,
+		})
+	}
+	if err := lf.Save(dir); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+func removeFromManifest(dir, name string) error {
+	fields, err := project.RemoveDependency(dir, name)
+	if err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		log.Debug("Dependency removed from package.json", map[string]interface{}{
+			"package": name,
+			"fields":  fields,
+		})
+	}
+	return nil
+}
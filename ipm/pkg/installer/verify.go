@@ -0,0 +1,325 @@
+package installer
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"ipm/pkg/cache"
+	"ipm/pkg/lockfile"
+	"ipm/pkg/log"
+	"ipm/pkg/signing"
+	"ipm/pkg/types"
+)
+
+// installedResult ist das Ergebnis von VerifyInstalled für ein Paket in node_modules.
+type installedResult struct {
+	Package   string                   `json:"package"`
+	Version   string                   `json:"version,omitempty"`
+	Status    string                   `json:"status"` // "ok", "unchecked", "modified", "invalid", "missing" oder "extraneous"
+	Link      string                   `json:"link,omitempty"`
+	Changes   []signing.ManifestChange `json:"changes,omitempty"`
+	Problems  []string                 `json:"problems,omitempty"`
+	Signature *signatureResult         `json:"signature,omitempty"`
+}
+
+// installedSeverity ordnet die Status von installedResult; der schwerste gewinnt.
+var installedSeverity = map[string]int{"ok": 0, "unchecked": 1, "modified": 2, "invalid": 3, "missing": 3, "extraneous": 3}
+
+func (r *installedResult) fail(status, problem string) {
+	if installedSeverity[status] > installedSeverity[r.Status] {
+		r.Status = status
+	}
+	if problem != "" {
+		r.Problems = append(r.Problems, problem)
+	}
+}
+
+// recordContent hält das Inhaltsmanifest des Pakets name im Cache neben dir fest
+// und merkt sich dessen Digest für die Lockfile. Mit tarballData wird es aus dem
+// Tarball berechnet, sonst ein vorhandenes übernommen oder aus dir berechnet.
+func (i *Installer) recordContent(name, dir string, tarballData []byte) error {
+	var manifest signing.Manifest
+	var err error
+	if tarballData != nil {
+		manifest, err = signing.TarballManifest(tarballData)
+	} else if manifest, err = i.cache.LoadManifest(dir); err == nil && manifest == nil {
+		manifest, err = signing.DirManifest(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record contents of %s: %v", name, err)
+	}
+	if err := i.cache.StoreManifest(dir, manifest); err != nil {
+		return err
+	}
+	i.digests[name] = manifest.Digest()
+	return nil
+}
+
+// cachedManifest liefert das Manifest des Pakets in dir: das bei der Installation
+// festgehaltene, solange der Inhalt noch dazu passt, sonst das aktuelle.
+func (i *Installer) cachedManifest(dir string) (signing.Manifest, error) {
+	current, err := signing.DirManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := i.cache.LoadManifest(dir)
+	if err != nil || recorded == nil {
+		return current, err
+	}
+	changes, err := contentChanges(recorded, current, dir)
+	if err != nil || len(changes) > 0 {
+		return current, nil
+	}
+	return recorded, nil
+}
+
+// contentChanges vergleicht den aktuellen Inhalt des Pakets in dir mit dem
+// festgehaltenen Manifest. Dass LinkBins die Ziele von "bin" ausführbar macht,
+// gilt nicht als Änderung.
+func contentChanges(recorded, current signing.Manifest, dir string) ([]signing.ManifestChange, error) {
+	changes, err := recorded.Diff(current)
+	if err != nil {
+		return nil, err
+	}
+	bins, _ := cache.Bins(dir)
+	targets := make(map[string]bool, len(bins))
+	for _, target := range bins {
+		targets[strings.TrimPrefix(target, "./")] = true
+	}
+	filtered := changes[:0]
+	for _, change := range changes {
+		if change.Kind == signing.FileMode && change.Mode == "755" && targets[change.Path] {
+			continue
+		}
+		filtered = append(filtered, change)
+	}
+	return filtered, nil
+}
+
+// VerifyInstalled prüft die Pakete in dir/node_modules gegen die Lockfile: ob jeder
+// Eintrag auf den erwarteten Cache-Eintrag verlinkt ist, ob der Inhalt noch zum
+// bei der Installation festgehaltenen Digest passt, welche Dateien seitdem
+// verändert, hinzugefügt oder gelöscht wurden, und ob Signaturen weiter gelten.
+// Das Ergebnis gibt ReportInstalled aus; ok ist false, sobald etwas nicht passt.
+func (i *Installer) VerifyInstalled(dir, pubKeyFile string) (bool, error) {
+	lf, err := lockfile.Load(dir)
+	if err != nil {
+		return false, err
+	}
+	if len(lf.Packages) == 0 {
+		return false, fmt.Errorf("no packages in %s; run \"ipm install\" first", lockfile.FileName)
+	}
+	modulesDir := filepath.Join(dir, "node_modules")
+	entries, err := modulesEntries(modulesDir)
+	if err != nil {
+		return false, err
+	}
+
+	names := make(map[string]bool, len(lf.Packages)+len(entries))
+	for name := range lf.Packages {
+		names[name] = true
+	}
+	for _, name := range entries {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	ok := true
+	for _, name := range sorted {
+		entry, locked := lf.Packages[name]
+		result := i.verifyInstalledPackage(dir, modulesDir, name, entry, locked, pubKeyFile)
+		if installedSeverity[result.Status] > installedSeverity["unchecked"] {
+			ok = false
+		}
+		log.Debug("Verified installed package", map[string]interface{}{
+			"package": name,
+			"status":  result.Status,
+			"changes": len(result.Changes),
+		})
+		i.installedResults = append(i.installedResults, result)
+	}
+	return ok, nil
+}
+
+// modulesEntries liefert die Paketnamen in node_modules, auch "@scope/name".
+// Verzeichnisse mit führendem Punkt wie .bin gehören nicht dazu.
+func modulesEntries(modulesDir string) ([]string, error) {
+	dirs, err := os.ReadDir(modulesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", modulesDir, err)
+	}
+	var names []string
+	for _, d := range dirs {
+		switch {
+		case strings.HasPrefix(d.Name(), "."):
+		case strings.HasPrefix(d.Name(), "@") && d.IsDir():
+			scoped, err := os.ReadDir(filepath.Join(modulesDir, d.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", filepath.Join(modulesDir, d.Name()), err)
+			}
+			for _, s := range scoped {
+				names = append(names, d.Name()+"/"+s.Name())
+			}
+		default:
+			names = append(names, d.Name())
+		}
+	}
+	return names, nil
+}
+
+func (i *Installer) verifyInstalledPackage(dir, modulesDir, name string, entry lockfile.Package, locked bool, pubKeyFile string) installedResult {
+	result := installedResult{Package: name, Version: entry.Version, Status: "ok"}
+	linkPath := filepath.Join(modulesDir, filepath.FromSlash(name))
+	info, err := os.Lstat(linkPath)
+	if !locked {
+		result.fail("extraneous", fmt.Sprintf("not in %s (added since install)", lockfile.FileName))
+		return result
+	}
+	if err != nil {
+		result.fail("missing", "not in node_modules (deleted since install)")
+		return result
+	}
+
+	target := linkPath
+	if info.Mode()&os.ModeSymlink != 0 {
+		if target, err = os.Readlink(linkPath); err != nil {
+			result.fail("invalid", err.Error())
+			return result
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(linkPath), target)
+		}
+	}
+	result.Link = target
+	expected, local, err := i.expectedTarget(dir, name, entry)
+	if err != nil {
+		result.fail("invalid", err.Error())
+		return result
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		result.fail("modified", fmt.Sprintf("is not a link (expected a link to %s)", expected))
+	} else if filepath.Clean(target) != filepath.Clean(expected) {
+		// Der Inhalt eines fremden Ziels lässt sich nicht sinnvoll vergleichen
+		result.fail("modified", fmt.Sprintf("links to %s, expected %s", target, expected))
+		return result
+	}
+	if local {
+		// file:- und link:-Verzeichnisse gehören dem Projekt; ihr Inhalt ändert sich erwartungsgemäß
+		return result
+	}
+
+	current, err := signing.DirManifest(target)
+	if err != nil {
+		result.fail("invalid", err.Error())
+		return result
+	}
+	manifest := current
+	recordedData, err := i.cache.LoadManifest(expected)
+	if err != nil {
+		result.fail("invalid", err.Error())
+		return result
+	}
+	recorded := signing.Manifest(recordedData)
+	switch {
+	case recorded == nil && entry.Digest == "":
+		result.fail("unchecked", "no content digest recorded; reinstall the package to record one")
+	case recorded == nil:
+		if digest := current.Digest(); digest != entry.Digest {
+			result.fail("modified", fmt.Sprintf("contents do not match the digest in %s (%s, locked %s)", lockfile.FileName, digest, entry.Digest))
+		}
+	case entry.Digest != "" && recorded.Digest() != entry.Digest:
+		result.fail("invalid", fmt.Sprintf("manifest in the cache does not match the digest in %s", lockfile.FileName))
+	default:
+		changes, err := contentChanges(recorded, current, target)
+		if err != nil {
+			result.fail("invalid", err.Error())
+			return result
+		}
+		if len(changes) > 0 {
+			result.Changes = changes
+			result.fail("modified", fmt.Sprintf("%d file(s) changed since install", len(changes)))
+		} else {
+			manifest = recorded
+		}
+	}
+
+	sigPkg := types.Package{Name: name, Version: entry.Version}
+	if realName, version, ok := aliasTarget(entry.Resolved); ok {
+		sigPkg.Name, sigPkg.Version = realName, version
+	}
+	signature, _, err := i.evaluateSignature(sigPkg, pubKeyFile, func(keys []crypto.PublicKey) ([]*signing.Metadata, bool, error) {
+		// Abgelöste Signaturen legt der Cache nur für Registry-Pakete und Aliase ab
+		var detached []byte
+		if entry.Integrity == "" {
+			data, err := i.cache.LoadSignature(sigPkg)
+			if err != nil {
+				return nil, false, err
+			}
+			detached = data
+		}
+		metas, err := signing.VerifyManifestAll(manifest, target, detached, keys)
+		return metas, detached != nil, err
+	})
+	signature.Package = name
+	result.Signature = &signature
+	if err != nil {
+		result.fail("invalid", err.Error())
+	}
+	return result
+}
+
+// expectedTarget bestimmt, wohin node_modules/name nach der Lockfile zeigen muss;
+// local meldet ein Verzeichnis des Projekts (file:, link:) statt eines Cache-Eintrags.
+func (i *Installer) expectedTarget(dir, name string, entry lockfile.Package) (string, bool, error) {
+	if realName, version, ok := aliasTarget(entry.Resolved); ok {
+		return i.cache.Dir(types.Package{Name: realName, Version: version}), false, nil
+	}
+	if entry.Integrity == "" {
+		for _, prefix := range []string{"file:", "link:"} {
+			if location := strings.TrimPrefix(entry.Resolved, prefix); location != entry.Resolved {
+				location = filepath.FromSlash(location)
+				if !filepath.IsAbs(location) {
+					abs, err := filepath.Abs(filepath.Join(dir, location))
+					if err != nil {
+						return "", false, err
+					}
+					location = abs
+				}
+				return location, true, nil
+			}
+		}
+		return i.cache.Dir(types.Package{Name: name, Version: entry.Version}), false, nil
+	}
+
+	// Tarballs aus anderen Quellen liegen unter dem Namen aus ihrer package.json,
+	// den die Lockfile festhält; der Inhalt des Linkziels entscheidet nichts
+	pkgName := name
+	if entry.Name != "" {
+		pkgName = entry.Name
+	}
+	return i.cache.SourceDir(types.Package{Name: pkgName, Version: entry.Version}, entry.Integrity), false, nil
+}
+
+// aliasTarget zerlegt ein aufgelöstes Alias "npm:other@1.2.3".
+func aliasTarget(resolved string) (string, string, bool) {
+	target := strings.TrimPrefix(resolved, "npm:")
+	if target == resolved {
+		return "", "", false
+	}
+	idx := strings.LastIndex(target, "@")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return target[:idx], target[idx+1:], true
+}
@@ -0,0 +1,403 @@
+package keyring
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"ipm/pkg/project"
+	"ipm/pkg/signing"
+)
+
+// UserSource kennzeichnet Schlüssel aus ~/.ipm/keys; Projektschlüssel tragen
+// project.ConfigFile als Quelle.
+const UserSource = "user"
+
+// Key ist ein benannter öffentlicher Schlüssel. Er gilt für Pakete, deren Name
+// oder Scope in Scopes steht, z. B. "@acme", "lodash" oder "*" für alle Pakete.
+// In ~/.ipm/keys/<name>.json und unter "keys" in ipm.json steht er als
+// {"publicKey": "<PEM>", "scopes": [...]}, optional mit Gültigkeitszeitraum
+// "validFrom"/"expires" (RFC 3339 oder YYYY-MM-DD) und "revoker", wenn er
+// Sperrlisten signieren darf.
+type Key struct {
+	Name      string   `json:"-"`
+	PublicKey string   `json:"publicKey"`
+	Scopes    []string `json:"scopes,omitempty"`
+	ValidFrom string   `json:"validFrom,omitempty"`
+	Expires   string   `json:"expires,omitempty"`
+	Revoker   bool     `json:"revoker,omitempty"`
+	Source    string   `json:"-"`
+
+	ID        string           `json:"-"`
+	Algorithm string           `json:"-"` // Standardalgorithmus des Schlüsseltyps
+	key       crypto.PublicKey // aus PublicKey gelesen
+	validFrom time.Time
+	expires   time.Time
+}
+
+// Keyring fasst die Schlüssel des Benutzers und des Projekts zusammen, samt der
+// Sperrungen aus ihren Sperrlisten.
+type Keyring struct {
+	Keys        []*Key
+	Revocations []Revocation
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// NewKey liest einen PEM-kodierten öffentlichen Schlüssel unter name.
+func NewKey(name string, pemData []byte) (*Key, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid key name %q (letters, digits, '.', '_' and '-')", name)
+	}
+	if name+".json" == RevocationsFile {
+		return nil, fmt.Errorf("key name %q is reserved for the revocation list", name)
+	}
+	k := &Key{Name: name, PublicKey: string(pemData)}
+	if err := k.parse(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Key) parse() error {
+	pub, err := signing.ParsePublicKey([]byte(k.PublicKey))
+	if err != nil {
+		return fmt.Errorf("key %s: %v", k.Name, err)
+	}
+	if k.ID, err = signing.KeyID(pub); err != nil {
+		return err
+	}
+	if k.Algorithm, err = signing.DefaultAlgorithm(pub); err != nil {
+		return err
+	}
+	for _, scope := range k.Scopes {
+		if err := ValidateScope(scope); err != nil {
+			return fmt.Errorf("key %s: %v", k.Name, err)
+		}
+	}
+	if k.validFrom, err = ParseTime(k.ValidFrom); err != nil {
+		return fmt.Errorf("key %s: invalid validFrom: %v", k.Name, err)
+	}
+	if k.expires, err = ParseTime(k.Expires); err != nil {
+		return fmt.Errorf("key %s: invalid expires: %v", k.Name, err)
+	}
+	if !k.validFrom.IsZero() && !k.expires.IsZero() && !k.expires.After(k.validFrom) {
+		return fmt.Errorf("key %s expires (%s) before it becomes valid (%s)", k.Name, k.Expires, k.ValidFrom)
+	}
+	k.key = pub
+	return nil
+}
+
+// ParseTime liest einen Zeitpunkt als RFC 3339 oder als Datum YYYY-MM-DD (0 Uhr UTC).
+// Eine leere Angabe ergibt den Nullwert.
+func ParseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither RFC 3339 nor YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// SetValidity setzt den Gültigkeitszeitraum; ein Nullwert hebt die Grenze auf.
+func (k *Key) SetValidity(validFrom, expires time.Time) error {
+	k.ValidFrom, k.Expires = "", ""
+	if !validFrom.IsZero() {
+		k.ValidFrom = validFrom.UTC().Format(time.RFC3339)
+	}
+	if !expires.IsZero() {
+		k.Expires = expires.UTC().Format(time.RFC3339)
+	}
+	return k.parse()
+}
+
+// Public liefert den gelesenen öffentlichen Schlüssel.
+func (k *Key) Public() crypto.PublicKey {
+	return k.key
+}
+
+// Trust nimmt scope in die Scopes auf; doppelte Einträge entfallen.
+func (k *Key) Trust(scope string) error {
+	if err := ValidateScope(scope); err != nil {
+		return err
+	}
+	for _, s := range k.Scopes {
+		if s == scope {
+			return nil
+		}
+	}
+	k.Scopes = append(k.Scopes, scope)
+	sort.Strings(k.Scopes)
+	return nil
+}
+
+// Covers meldet, ob der Schlüssel für das Paket name gilt.
+func (k *Key) Covers(name string) bool {
+	for _, scope := range k.Scopes {
+		if MatchScope(scope, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateScope prüft eine Scope-Angabe: "*", "@scope" oder ein Paketname.
+func ValidateScope(scope string) error {
+	switch {
+	case scope == "*":
+		return nil
+	case strings.HasPrefix(scope, "@") && !strings.Contains(scope, "/"):
+		if len(scope) > 1 {
+			return nil
+		}
+	case scope != "" && !strings.ContainsAny(scope, " *"):
+		return nil
+	}
+	return fmt.Errorf("invalid scope %q (expected @scope, a package name or *)", scope)
+}
+
+// MatchScope meldet, ob scope das Paket name umfasst.
+func MatchScope(scope, name string) bool {
+	if scope == "*" || scope == name {
+		return true
+	}
+	return strings.HasPrefix(scope, "@") && !strings.Contains(scope, "/") && strings.HasPrefix(name, scope+"/")
+}
+
+// Trusted liefert die Schlüssel, die für das Paket name gelten.
+func (r *Keyring) Trusted(name string) []*Key {
+	if r == nil {
+		return nil
+	}
+	var keys []*Key
+	for _, k := range r.Keys {
+		if k.Covers(name) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// PublicKeys liefert die öffentlichen Schlüssel, etwa für signing.VerifyPackage.
+func PublicKeys(keys []*Key) []crypto.PublicKey {
+	pubs := make([]crypto.PublicKey, len(keys))
+	for idx, k := range keys {
+		pubs[idx] = k.key
+	}
+	return pubs
+}
+
+// Load liest die Schlüssel des Benutzers und die des Projekts in dir, dazu die
+// Sperrlisten ~/.ipm/keys/revocations.json, sofern vorhanden, und "revocations"
+// aus ipm.json.
+// Eine Sperrliste ohne gültige Signatur eines gültigen Sperrschlüssels ist ein
+// Fehler, ebenso eine, die älter ist als die zuletzt geladene desselben Schlüssels.
+func Load(dir string) (*Keyring, error) {
+	ring := &Keyring{}
+	for _, store := range []*Store{{}, {Project: dir}} {
+		keys, err := store.Load()
+		if err != nil {
+			return nil, err
+		}
+		ring.Keys = append(ring.Keys, keys...)
+	}
+	files, err := revocationFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return ring, nil
+	}
+	seen, err := loadSeen()
+	if err != nil {
+		return nil, err
+	}
+	lists := make([]*RevocationList, len(files))
+	for idx, file := range files {
+		if file, err = filepath.Abs(file); err != nil {
+			return nil, fmt.Errorf("failed to resolve revocation list path: %v", err)
+		}
+		if lists[idx], err = LoadRevocations(file, ring); err != nil {
+			return nil, err
+		}
+		if err := lists[idx].checkIssued(file, seen); err != nil {
+			return nil, err
+		}
+		ring.Revocations = append(ring.Revocations, lists[idx].Revoked...)
+	}
+	// Eine spätere Liste kann den Sperrschlüssel einer früheren sperren
+	for idx, list := range lists {
+		if err := list.checkSigner(ring); err != nil {
+			return nil, fmt.Errorf("revocation list %s: %v", files[idx], err)
+		}
+	}
+	if err := storeSeen(seen); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// Store ist der Speicherort von Schlüsseln: ~/.ipm/keys oder, wenn Project
+// gesetzt ist, "keys" in dessen ipm.json.
+type Store struct {
+	Project string
+}
+
+// Source liefert die Quellangabe der Schlüssel dieses Speicherorts.
+func (s *Store) Source() string {
+	if s.Project != "" {
+		return project.ConfigFile
+	}
+	return UserSource
+}
+
+// UserDir liefert ~/.ipm/keys.
+func UserDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %v", err)
+	}
+	return filepath.Join(home, ".ipm", "keys"), nil
+}
+
+// Load liest alle Schlüssel des Speicherorts, sortiert nach Name.
+func (s *Store) Load() ([]*Key, error) {
+	keys, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Key, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+	return list, nil
+}
+
+func (s *Store) load() (map[string]*Key, error) {
+	keys := map[string]*Key{}
+	if s.Project != "" {
+		config, err := project.LoadConfig(s.Project)
+		if err != nil {
+			return nil, err
+		}
+		if len(config.Keys) > 0 {
+			if err := json.Unmarshal(config.Keys, &keys); err != nil {
+				return nil, fmt.Errorf("invalid %s:keys: %v", project.ConfigFile, err)
+			}
+		}
+	} else {
+		dir, err := UserDir()
+		if err != nil {
+			return nil, err
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if filepath.Base(file) == RevocationsFile {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read key: %v", err)
+			}
+			var k Key
+			if err := json.Unmarshal(data, &k); err != nil {
+				return nil, fmt.Errorf("invalid key file %s: %v", file, err)
+			}
+			keys[strings.TrimSuffix(filepath.Base(file), ".json")] = &k
+		}
+	}
+	for name, k := range keys {
+		if k == nil {
+			return nil, fmt.Errorf("key %s in %s is empty", name, s.Source())
+		}
+		k.Name, k.Source = name, s.Source()
+		if err := k.parse(); err != nil {
+			return nil, fmt.Errorf("%s: %v", s.Source(), err)
+		}
+	}
+	return keys, nil
+}
+
+// Find sucht einen Schlüssel nach Name oder Schlüssel-ID.
+func (s *Store) Find(ref string) (*Key, error) {
+	keys, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := keys[ref]; ok {
+		return k, nil
+	}
+	for _, k := range keys {
+		if k.ID == ref {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("no key %s in %s", ref, s.Source())
+}
+
+// Save legt einen Schlüssel an oder ersetzt ihn.
+func (s *Store) Save(k *Key) error {
+	if s.Project != "" {
+		keys, err := s.load()
+		if err != nil {
+			return err
+		}
+		keys[k.Name] = k
+		return project.SetConfigField(s.Project, "keys", keys)
+	}
+
+	dir, err := UserDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create key directory: %v", err)
+	}
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, k.Name+".json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write key: %v", err)
+	}
+	return nil
+}
+
+// Remove entfernt den Schlüssel name.
+func (s *Store) Remove(name string) error {
+	if s.Project != "" {
+		keys, err := s.load()
+		if err != nil {
+			return err
+		}
+		delete(keys, name)
+		if len(keys) == 0 {
+			return project.SetConfigField(s.Project, "keys", nil)
+		}
+		return project.SetConfigField(s.Project, "keys", keys)
+	}
+
+	dir, err := UserDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, name+".json")); err != nil {
+		return fmt.Errorf("failed to remove key %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package keyring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ipm/pkg/project"
+)
+
+// Richtlinien für Paketsignaturen.
+const (
+	Required = "required" // unsignierte Pakete und Pakete ohne vertrauenswürdigen Schlüssel brechen ab
+	Warn     = "warn"     // fehlende Signaturen werden gemeldet, ungültige brechen ab
+	Off      = "off"      // keine Prüfung
+)
+
+// Policy legt fest, wie streng Signaturen geprüft werden, wahlweise je Scope
+// oder Paket, und welche Pakete von mehreren Schlüsseln einer Gruppe signiert
+// sein müssen.
+type Policy struct {
+	Mode       string
+	Scopes     map[string]string    // Scope ("@acme"), Paketname oder "*" → Modus
+	Groups     map[string][]string  // Gruppe → Namen oder IDs von Schlüsseln des Keyrings
+	Thresholds map[string]Threshold // Scope, Paketname oder "*" → nötige Signaturen
+	Source     string               // Herkunft für Meldungen, leer = Standard
+}
+
+// Threshold verlangt gültige Signaturen von mindestens Required Mitgliedern der
+// Gruppe Group, etwa zwei Freigaben für sicherheitsrelevante Pakete.
+type Threshold struct {
+	Group    string `json:"group"`
+	Required int    `json:"required"`
+}
+
+// ParsePolicy liest die Richtlinie aus ipm.json, entweder als Modus oder als Objekt:
+//
+//	"signatures": {
+//	  "policy": "warn",
+//	  "scopes": { "@acme": "required", "left-pad": "off" },
+//	  "groups": { "release": ["alice", "bob", "carol"] },
+//	  "thresholds": { "@acme/safety": { "group": "release", "required": 2 } }
+//	}
+//
+// Ohne Angabe gilt warn, wie vor Einführung der Richtlinie.
+func ParsePolicy(raw json.RawMessage, source string) (*Policy, error) {
+	policy := &Policy{Mode: Warn}
+	if len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null" {
+		return policy, nil
+	}
+	policy.Source = source
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		policy.Mode = mode
+	} else {
+		var object struct {
+			Policy     string               `json:"policy"`
+			Scopes     map[string]string    `json:"scopes"`
+			Groups     map[string][]string  `json:"groups"`
+			Thresholds map[string]Threshold `json:"thresholds"`
+		}
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", source, err)
+		}
+		if object.Policy != "" {
+			policy.Mode = object.Policy
+		}
+		for scope, mode := range object.Scopes {
+			if err := ValidateScope(scope); err != nil {
+				return nil, fmt.Errorf("invalid %s.scopes: %v", source, err)
+			}
+			if err := validMode(mode); err != nil {
+				return nil, fmt.Errorf("invalid %s.scopes[%q]: %v", source, scope, err)
+			}
+		}
+		for name, members := range object.Groups {
+			if len(members) == 0 {
+				return nil, fmt.Errorf("invalid %s.groups[%q]: no keys", source, name)
+			}
+			seen := make(map[string]bool, len(members))
+			for _, member := range members {
+				if seen[member] {
+					return nil, fmt.Errorf("invalid %s.groups[%q]: %s listed twice", source, name, member)
+				}
+				seen[member] = true
+			}
+		}
+		for scope, threshold := range object.Thresholds {
+			if err := ValidateScope(scope); err != nil {
+				return nil, fmt.Errorf("invalid %s.thresholds: %v", source, err)
+			}
+			members, ok := object.Groups[threshold.Group]
+			if !ok {
+				return nil, fmt.Errorf("invalid %s.thresholds[%q]: unknown group %q", source, scope, threshold.Group)
+			}
+			if threshold.Required < 1 || threshold.Required > len(members) {
+				return nil, fmt.Errorf("invalid %s.thresholds[%q]: required must be between 1 and %d", source, scope, len(members))
+			}
+		}
+		policy.Scopes, policy.Groups, policy.Thresholds = object.Scopes, object.Groups, object.Thresholds
+	}
+	if err := validMode(policy.Mode); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", source, err)
+	}
+	return policy, nil
+}
+
+func validMode(mode string) error {
+	switch mode {
+	case Required, Warn, Off:
+		return nil
+	}
+	return fmt.Errorf("policy %q (expected %s, %s or %s)", mode, Required, Warn, Off)
+}
+
+// LoadPolicy liest die Signaturrichtlinie aus der ipm.json in dir.
+func LoadPolicy(dir string) (*Policy, error) {
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(config.Signatures, project.ConfigFile+":signatures")
+}
+
+// ModeFor liefert den Modus für das Paket name. Der Paketname geht dem Scope vor,
+// der Scope "*" und "*" der allgemeinen Richtlinie.
+func (p *Policy) ModeFor(name string) string {
+	if p == nil {
+		return Warn
+	}
+	for _, scope := range lookupOrder(name) {
+		if mode, ok := p.Scopes[scope]; ok {
+			return mode
+		}
+	}
+	return p.Mode
+}
+
+// ThresholdFor liefert die Schwelle für das Paket name, in derselben Reihenfolge
+// wie ModeFor; nil, wenn eine Signatur genügt.
+func (p *Policy) ThresholdFor(name string) *Threshold {
+	if p == nil {
+		return nil
+	}
+	for _, scope := range lookupOrder(name) {
+		if threshold, ok := p.Thresholds[scope]; ok {
+			return &threshold
+		}
+	}
+	return nil
+}
+
+// lookupOrder liefert die Schlüssel, unter denen Einstellungen für das Paket name
+// stehen können: Paketname, Scope, "*".
+func lookupOrder(name string) []string {
+	order := []string{name}
+	if strings.HasPrefix(name, "@") {
+		if idx := strings.Index(name, "/"); idx > 0 {
+			order = append(order, name[:idx])
+		}
+	}
+	return append(order, "*")
+}
+
+// Member ist ein Mitglied einer Signaturgruppe: der Eintrag aus ipm.json und der
+// Schlüssel, zu dem er aufgelöst wurde.
+type Member struct {
+	Ref string
+	Key *Key
+}
+
+// Members löst die Mitglieder der Gruppe group nach Name oder Schlüssel-ID im
+// Keyring zu je genau einem Schlüssel auf. Ein Mitglied ohne Schlüssel ist ein
+// Fehler, damit ein Tippfehler die Schwelle nicht unerreichbar macht; ebenso ein
+// mehrdeutiges Mitglied und zwei Mitglieder mit demselben Schlüssel, denn sonst
+// zählte eine Signatur doppelt.
+func (p *Policy) Members(group string, ring *Keyring) ([]Member, error) {
+	refs, ok := p.Groups[group]
+	if !ok {
+		return nil, fmt.Errorf("unknown signature group %q", group)
+	}
+	members := make([]Member, 0, len(refs))
+	owners := make(map[string]string, len(refs)) // Schlüssel-ID → Mitglied
+	for _, ref := range refs {
+		var key *Key
+		if ring != nil {
+			for _, k := range ring.Keys {
+				if k.Name != ref && k.ID != ref {
+					continue
+				}
+				if key != nil && key.ID != k.ID {
+					return nil, fmt.Errorf("signature group %s: %s names several keys; use the key ID", group, ref)
+				}
+				key = k
+			}
+		}
+		if key == nil {
+			return nil, fmt.Errorf("signature group %s: no key %s in the keyring", group, ref)
+		}
+		if owner, ok := owners[key.ID]; ok {
+			return nil, fmt.Errorf("signature group %s: %s and %s are the same key %s", group, owner, ref, key.ID)
+		}
+		owners[key.ID] = ref
+		members = append(members, Member{Ref: ref, Key: key})
+	}
+	return members, nil
+}
@@ -0,0 +1,96 @@
+package keyring
+
+import (
+	"strings"
+	"testing"
+
+	"ipm/pkg/signing"
+)
+
+func testKey(t *testing.T, name string) *Key {
+	t.Helper()
+	key, err := signing.GenerateKey(signing.KeyTypeEd25519, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData, err := signing.MarshalPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKey(name, pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestThresholdFor(t *testing.T) {
+	policy := &Policy{Thresholds: map[string]Threshold{
+		"*":          {Group: "all", Required: 1},
+		"@acme":      {Group: "acme", Required: 2},
+		"@acme/core": {Group: "core", Required: 3},
+	}}
+	tests := []struct {
+		name  string
+		group string
+	}{
+		{"left-pad", "all"},
+		{"@acme/util", "acme"},
+		{"@acme/core", "core"},
+		{"@other/core", "all"},
+	}
+	for _, tt := range tests {
+		if got := policy.ThresholdFor(tt.name); got == nil || got.Group != tt.group {
+			t.Errorf("ThresholdFor(%s) = %+v, want group %s", tt.name, got, tt.group)
+		}
+	}
+	if got := (&Policy{}).ThresholdFor("left-pad"); got != nil {
+		t.Errorf("ThresholdFor() without thresholds = %+v", got)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	alice, bob := testKey(t, "alice"), testKey(t, "bob")
+	twin := testKey(t, "alice")
+	ring := &Keyring{Keys: []*Key{alice, bob}}
+
+	tests := []struct {
+		name  string
+		ring  *Keyring
+		refs  []string
+		want  []string // Schlüssel-IDs
+		error string
+	}{
+		{name: "by name and ID", ring: ring, refs: []string{"alice", bob.ID}, want: []string{alice.ID, bob.ID}},
+		{name: "unknown key", ring: ring, refs: []string{"alice", "mallory"}, error: "no key mallory"},
+		{name: "same key twice", ring: ring, refs: []string{"alice", alice.ID}, error: "are the same key"},
+		{name: "ambiguous name", ring: &Keyring{Keys: []*Key{alice, twin}}, refs: []string{"alice"}, error: "names several keys"},
+		{name: "ambiguous name resolved by ID", ring: &Keyring{Keys: []*Key{alice, twin}}, refs: []string{twin.ID}, want: []string{twin.ID}},
+		{name: "no keyring", refs: []string{"alice"}, error: "no key alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &Policy{Groups: map[string][]string{"release": tt.refs}}
+			members, err := policy.Members("release", tt.ring)
+			if tt.error != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.error) {
+					t.Fatalf("Members() error = %v, want %q", err, tt.error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range members {
+				got = append(got, m.Key.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Members() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := (&Policy{}).Members("release", ring); err == nil {
+		t.Error("Members() of an unknown group succeeded")
+	}
+}
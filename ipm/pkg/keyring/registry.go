@@ -0,0 +1,123 @@
+package keyring
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ipm/pkg/log"
+	"ipm/pkg/signing"
+)
+
+// RootsFile ist die Datei mit den Wurzelzertifikaten (PEM) für Provenance-Zertifikate,
+// z. B. die von Sigstore Fulcio, in RegistryDir.
+const RootsFile = "trusted-roots.pem"
+
+// RegistryTrust enthält, wogegen dist.signatures und Attestierungen einer npm-Registry
+// geprüft werden. Alles liegt lokal, damit die Prüfung ohne Netz funktioniert.
+type RegistryTrust struct {
+	Keys  *signing.RegistryKeys
+	Roots *x509.CertPool // nil: Zertifikate werden gelesen, aber nicht auf eine Wurzel geprüft
+	// Offline lädt fehlende Attestierungs-Bundles nicht nach
+	Offline bool
+}
+
+// RegistryOptions legen fest, woher LoadRegistryTrust Schlüssel und Wurzeln nimmt.
+type RegistryOptions struct {
+	KeysFile  string // lokale Kopie von /-/npm/v1/keys; leer = Ablage in RegistryDir
+	RootsFile string // leer = RegistryDir/trusted-roots.pem, sofern vorhanden
+	Offline   bool
+	Refresh   bool // Schlüssel neu laden, auch wenn sie abgelegt sind
+}
+
+// RegistryDir liefert ~/.ipm/keys/registries, die Ablage der Registry-Schlüssel.
+func RegistryDir() (string, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "registries"), nil
+}
+
+// registryKeysFile liefert die Ablage der Schlüssel von registryURL, <host>.json.
+func registryKeysFile(registryURL string) (string, error) {
+	u, err := url.Parse(registryURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid registry URL %q", registryURL)
+	}
+	dir, err := RegistryDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, strings.ReplaceAll(u.Host, ":", "_")+".json"), nil
+}
+
+// LoadRegistryTrust liest die Schlüssel der Registry registryURL und die
+// Wurzelzertifikate. Fehlen abgelegte Schlüssel, lädt fetch sie und legt sie ab;
+// offline ist das ein Fehler.
+func LoadRegistryTrust(registryURL string, opts RegistryOptions, fetch func() ([]byte, error)) (*RegistryTrust, error) {
+	keysFile := opts.KeysFile
+	if keysFile == "" {
+		file, err := registryKeysFile(registryURL)
+		if err != nil {
+			return nil, err
+		}
+		keysFile = file
+		_, statErr := os.Stat(file)
+		if opts.Refresh || os.IsNotExist(statErr) {
+			if opts.Offline {
+				return nil, fmt.Errorf("no keys stored for registry %s; use --registry-keys or run without --offline", registryURL)
+			}
+			data, err := fetch()
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch registry keys: %v", err)
+			}
+			if _, err := signing.ParseRegistryKeys(data); err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create key directory: %v", err)
+			}
+			if err := os.WriteFile(file, data, 0644); err != nil {
+				return nil, fmt.Errorf("failed to store registry keys: %v", err)
+			}
+			log.Info("Registry keys stored", map[string]interface{}{
+				"registry": registryURL,
+				"file":     file,
+			})
+		}
+	}
+	data, err := os.ReadFile(keysFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry keys: %v", err)
+	}
+	keys, err := signing.ParseRegistryKeys(data)
+	if err != nil {
+		return nil, err
+	}
+
+	trust := &RegistryTrust{Keys: keys, Offline: opts.Offline}
+	rootsFile := opts.RootsFile
+	if rootsFile == "" {
+		dir, err := RegistryDir()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(filepath.Join(dir, RootsFile)); err != nil {
+			return trust, nil
+		}
+		rootsFile = filepath.Join(dir, RootsFile)
+	}
+	pemData, err := os.ReadFile(rootsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted roots: %v", err)
+	}
+	trust.Roots = x509.NewCertPool()
+	if !trust.Roots.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("no certificates in %s", rootsFile)
+	}
+	return trust, nil
+}
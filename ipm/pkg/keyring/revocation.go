@@ -0,0 +1,352 @@
+package keyring
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"ipm/pkg/project"
+	"ipm/pkg/signing"
+)
+
+// RevocationsFile ist die Sperrliste des Benutzers in ~/.ipm/keys.
+const RevocationsFile = "revocations.json"
+
+// RevocationsSeenFile hält in ~/.ipm/keys je Sperrliste und Sperrschlüssel den
+// jüngsten Ausstellungszeitpunkt fest, damit ältere Listen nicht wieder gelten.
+const RevocationsSeenFile = "revocations.seen"
+
+// RevocationsPayloadType kennzeichnet eine Sperrliste im DSSE-Umschlag.
+const RevocationsPayloadType = "application/vnd.ipm.revocations.v1+json"
+
+// Gültigkeit eines Schlüssels, siehe KeyState.
+const (
+	KeyValid       = "valid"
+	KeyExpired     = "expired"
+	KeyNotYetValid = "not yet valid"
+	KeyRevoked     = "revoked"
+	KeyUnknown     = "unknown" // Signatur ohne Schlüssel-ID (ältere ipm-Versionen)
+)
+
+// Revocation sperrt einen Schlüssel ab RevokedAt. Signaturen eines gesperrten
+// Schlüssels gelten nie, auch nicht solche mit früherem Zeitstempel: wer den
+// Schlüssel kennt, kann auch den Zeitstempel fälschen.
+type Revocation struct {
+	KeyID     string `json:"keyId"`
+	RevokedAt string `json:"revokedAt"` // RFC 3339
+	Reason    string `json:"reason,omitempty"`
+}
+
+// RevocationList ist die signierte Nutzlast einer Sperrliste.
+type RevocationList struct {
+	Issued  string       `json:"issued"` // RFC 3339
+	Revoked []Revocation `json:"revoked"`
+
+	signer string // Schlüssel-ID des Sperrschlüssels, nach LoadRevocations
+}
+
+// revocationFiles liefert die Sperrlisten des Benutzers und des Projekts in dir.
+func revocationFiles(dir string) ([]string, error) {
+	userDir, err := UserDir()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if _, err := os.Stat(filepath.Join(userDir, RevocationsFile)); err == nil {
+		files = append(files, filepath.Join(userDir, RevocationsFile))
+	}
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	if config.Revocations != "" {
+		file := config.Revocations
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// LoadRevocations liest die Sperrliste file und prüft ihre Signatur gegen die
+// Sperrschlüssel (Revoker) in ring. Gesperrte Sperrschlüssel zählen nicht, und der
+// Schlüssel muss zum Ausstellungszeitpunkt gültig gewesen sein.
+func LoadRevocations(file string, ring *Keyring) (*RevocationList, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocation list: %v", err)
+	}
+	var revokers []crypto.PublicKey
+	for _, k := range ring.Keys {
+		if k.Revoker && ring.revocation(k.ID) == nil {
+			revokers = append(revokers, k.key)
+		}
+	}
+	if len(revokers) == 0 {
+		return nil, fmt.Errorf("revocation list %s cannot be verified: no unrevoked key in the keyring may sign revocation lists (see \"ipm keys add --revoker\")", file)
+	}
+	payload, signer, err := signing.VerifyPayload(data, RevocationsPayloadType, revokers)
+	if err != nil {
+		return nil, fmt.Errorf("revocation list %s: %v", file, err)
+	}
+	list, err := parseRevocations(payload)
+	if err != nil {
+		return nil, fmt.Errorf("revocation list %s: %v", file, err)
+	}
+	list.signer = signer
+	if err := list.checkSigner(ring); err != nil {
+		return nil, fmt.Errorf("revocation list %s: %v", file, err)
+	}
+	return list, nil
+}
+
+// checkSigner prüft den Sperrschlüssel der Liste mit Validity gegen die
+// Sperrungen in ring zum Ausstellungszeitpunkt.
+func (l *RevocationList) checkSigner(ring *Keyring) error {
+	issued, _ := ParseTime(l.Issued)
+	if _, err := ring.Validity(l.signer, issued); err != nil {
+		return fmt.Errorf("signed with revoker %s: %v", l.signer, err)
+	}
+	return nil
+}
+
+// checkIssued lehnt eine Liste ab, die älter ist als die zuletzt von demselben
+// Sperrschlüssel unter file geladene, und merkt sich sonst ihren
+// Ausstellungszeitpunkt in seen. So lässt sich eine Sperrung nicht durch
+// Zurückspielen einer älteren, gültig signierten Liste aufheben.
+func (l *RevocationList) checkIssued(file string, seen map[string]map[string]string) error {
+	issued, _ := ParseTime(l.Issued)
+	if last, ok := seen[file][l.signer]; ok {
+		if lastIssued, err := ParseTime(last); err == nil && issued.Before(lastIssued) {
+			return fmt.Errorf("revocation list %s was issued at %s, before the list already loaded from key %s (%s); an older list must not replace a newer one", file, l.Issued, l.signer, last)
+		}
+	}
+	if seen[file] == nil {
+		seen[file] = map[string]string{}
+	}
+	seen[file][l.signer] = l.Issued
+	return nil
+}
+
+// loadSeen liest die zuletzt geladenen Ausstellungszeitpunkte (RevocationsSeenFile)
+// als Sperrliste → Schlüssel-ID → Zeitpunkt.
+func loadSeen() (map[string]map[string]string, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]map[string]string{}
+	data, err := os.ReadFile(filepath.Join(dir, RevocationsSeenFile))
+	if os.IsNotExist(err) {
+		return seen, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", RevocationsSeenFile, err)
+	}
+	if err := json.Unmarshal(data, &seen); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RevocationsSeenFile, err)
+	}
+	return seen, nil
+}
+
+func storeSeen(seen map[string]map[string]string) error {
+	dir, err := UserDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(seen, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", RevocationsSeenFile, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create key directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, RevocationsSeenFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", RevocationsSeenFile, err)
+	}
+	return nil
+}
+
+func parseRevocations(payload []byte) (*RevocationList, error) {
+	var list RevocationList
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return nil, fmt.Errorf("invalid revocation list: %v", err)
+	}
+	if _, err := ParseTime(list.Issued); err != nil || list.Issued == "" {
+		return nil, fmt.Errorf("invalid issue time %q", list.Issued)
+	}
+	for _, r := range list.Revoked {
+		if r.KeyID == "" {
+			return nil, fmt.Errorf("revocation without keyId")
+		}
+		if _, err := ParseTime(r.RevokedAt); err != nil || r.RevokedAt == "" {
+			return nil, fmt.Errorf("key %s: invalid revokedAt %q", r.KeyID, r.RevokedAt)
+		}
+	}
+	return &list, nil
+}
+
+// ReadRevocations liest eine eigene Sperrliste zum Fortschreiben, geprüft mit dem
+// öffentlichen Schlüssel, mit dem sie wieder signiert wird. Eine fehlende Datei
+// ergibt eine leere Liste.
+func ReadRevocations(file string, pub crypto.PublicKey) (*RevocationList, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &RevocationList{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocation list: %v", err)
+	}
+	payload, _, err := signing.VerifyPayload(data, RevocationsPayloadType, []crypto.PublicKey{pub})
+	if err != nil {
+		return nil, fmt.Errorf("revocation list %s: %v", file, err)
+	}
+	return parseRevocations(payload)
+}
+
+// Revoke nimmt keyID in die Liste auf oder aktualisiert den Eintrag.
+func (l *RevocationList) Revoke(keyID string, at time.Time, reason string) {
+	entry := Revocation{KeyID: keyID, RevokedAt: at.UTC().Format(time.RFC3339), Reason: reason}
+	for idx := range l.Revoked {
+		if l.Revoked[idx].KeyID == keyID {
+			l.Revoked[idx] = entry
+			return
+		}
+	}
+	l.Revoked = append(l.Revoked, entry)
+	sort.Slice(l.Revoked, func(a, b int) bool { return l.Revoked[a].KeyID < l.Revoked[b].KeyID })
+}
+
+// Sign setzt den Ausstellungszeitpunkt und signiert die Liste mit key.
+func (l *RevocationList) Sign(key crypto.Signer) ([]byte, error) {
+	l.Issued = time.Now().UTC().Format(time.RFC3339)
+	if l.Revoked == nil {
+		l.Revoked = []Revocation{}
+	}
+	payload, err := json.Marshal(l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode revocation list: %v", err)
+	}
+	return signing.SignPayload(key, RevocationsPayloadType, payload)
+}
+
+// KeyState beschreibt den Schlüssel, mit dem ein Paket signiert wurde, und ob er
+// noch gilt.
+type KeyState struct {
+	KeyID     string `json:"keyId"`
+	Name      string `json:"name,omitempty"`
+	Status    string `json:"status"` // KeyValid, KeyExpired, KeyNotYetValid, KeyRevoked oder KeyUnknown
+	ValidFrom string `json:"validFrom,omitempty"`
+	Expires   string `json:"expires,omitempty"`
+	RevokedAt string `json:"revokedAt,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// String beschreibt den Zustand für Meldungen, z. B. "acme (valid until 2027-01-01T00:00:00Z)".
+func (s KeyState) String() string {
+	name := s.Name
+	if name == "" {
+		name = s.KeyID
+	}
+	switch s.Status {
+	case KeyRevoked:
+		detail := "revoked at " + s.RevokedAt
+		if s.Reason != "" {
+			detail += ": " + s.Reason
+		}
+		return fmt.Sprintf("%s (%s)", name, detail)
+	case KeyExpired:
+		return fmt.Sprintf("%s (expired at %s)", name, s.Expires)
+	case KeyNotYetValid:
+		return fmt.Sprintf("%s (valid from %s)", name, s.ValidFrom)
+	case KeyUnknown:
+		return "unknown key"
+	}
+	if s.Expires != "" {
+		return fmt.Sprintf("%s (valid until %s)", name, s.Expires)
+	}
+	return fmt.Sprintf("%s (valid)", name)
+}
+
+// State liefert den heutigen Zustand eines Schlüssels des Keyrings.
+func (r *Keyring) State(k *Key) KeyState {
+	state := KeyState{KeyID: k.ID, Name: k.Name, Status: KeyValid, ValidFrom: k.ValidFrom, Expires: k.Expires}
+	now := time.Now()
+	rev := r.revocation(k.ID)
+	switch {
+	case rev != nil:
+		state.Status, state.RevokedAt, state.Reason = KeyRevoked, rev.RevokedAt, rev.Reason
+	case !k.expires.IsZero() && now.After(k.expires):
+		state.Status = KeyExpired
+	case !k.validFrom.IsZero() && now.Before(k.validFrom):
+		state.Status = KeyNotYetValid
+	}
+	return state
+}
+
+// Validity prüft, ob eine Signatur des Schlüssels keyID zum Zeitpunkt signedAt
+// gilt. Abgelehnt werden Signaturen gesperrter Schlüssel und solche außerhalb des
+// Gültigkeitszeitraums; ohne signedAt gilt der aktuelle Zeitpunkt. Ein später
+// abgelaufener Schlüssel bleibt für frühere Signaturen gültig, der Zustand
+// meldet dann KeyExpired. Schlüssel außerhalb des Keyrings (--pubkey) haben
+// keinen Zeitraum, können aber gesperrt sein. Ohne keyID lässt sich nichts
+// davon prüfen; das ist ein Fehler.
+func (r *Keyring) Validity(keyID string, signedAt time.Time) (KeyState, error) {
+	if keyID == "" {
+		return KeyState{Status: KeyUnknown}, fmt.Errorf("signature does not identify its key")
+	}
+	state := KeyState{KeyID: keyID, Status: KeyValid}
+	var key *Key
+	if r != nil {
+		for _, k := range r.Keys {
+			if k.ID == keyID {
+				key = k
+				state = r.State(k)
+				break
+			}
+		}
+		if rev := r.revocation(keyID); rev != nil {
+			state.Status, state.RevokedAt, state.Reason = KeyRevoked, rev.RevokedAt, rev.Reason
+		}
+	}
+	if state.Status == KeyRevoked {
+		name := state.Name
+		if name == "" {
+			name = keyID
+		}
+		if state.Reason != "" {
+			return state, fmt.Errorf("key %s was revoked at %s: %s", name, state.RevokedAt, state.Reason)
+		}
+		return state, fmt.Errorf("key %s was revoked at %s", name, state.RevokedAt)
+	}
+	if key == nil {
+		return state, nil
+	}
+	if signedAt.IsZero() {
+		signedAt = time.Now()
+	}
+	if !key.validFrom.IsZero() && signedAt.Before(key.validFrom) {
+		return state, fmt.Errorf("signed at %s, before key %s became valid (%s)", signedAt.UTC().Format(time.RFC3339), key.Name, key.ValidFrom)
+	}
+	if !key.expires.IsZero() && signedAt.After(key.expires) {
+		return state, fmt.Errorf("signed at %s, after key %s expired (%s)", signedAt.UTC().Format(time.RFC3339), key.Name, key.Expires)
+	}
+	return state, nil
+}
+
+func (r *Keyring) revocation(keyID string) *Revocation {
+	if r == nil {
+		return nil
+	}
+	for idx := range r.Revocations {
+		if r.Revocations[idx].KeyID == keyID {
+			return &r.Revocations[idx]
+		}
+	}
+	return nil
+}
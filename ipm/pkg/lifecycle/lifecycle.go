@@ -0,0 +1,199 @@
+package lifecycle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"ipm/pkg/semver"
+	"ipm/pkg/types"
+)
+
+// Richtlinien für Lifecycle-Skripte von Abhängigkeiten.
+const (
+	AllowAll  = "allow-all"
+	DenyAll   = "deny-all"
+	Allowlist = "allowlist"
+)
+
+// InstallEvents sind die Skripte, die nach der Installation eines Pakets laufen, in dieser Reihenfolge.
+var InstallEvents = []string{"preinstall", "install", "postinstall"}
+
+// Entry erlaubt die Skripte eines Pakets, z. B. "esbuild" oder "node-sass@^9.0.0".
+type Entry struct {
+	Name  string
+	Range string // leer = jede Version
+}
+
+func (e Entry) String() string {
+	if e.Range == "" {
+		return e.Name
+	}
+	return e.Name + "@" + e.Range
+}
+
+// Policy legt fest, welche Abhängigkeiten Skripte ausführen dürfen. Das Projekt
+// selbst und Workspace-Mitglieder sind davon ausgenommen.
+type Policy struct {
+	Mode   string
+	Allow  []Entry
+	Source string // Herkunft für Meldungen, z. B. "ipm.json:scripts"
+}
+
+// ParsePolicy liest die Richtlinie aus ipm.json, entweder als Modus
+// ("allow-all", "deny-all") oder als Objekt:
+//
+//	"scripts": { "policy": "allowlist", "allow": ["esbuild", "node-sass@^9.0.0"] }
+//
+// Ohne Angabe gilt eine leere Allowlist: Skripte von Abhängigkeiten laufen nur,
+// wenn sie ausdrücklich erlaubt sind.
+func ParsePolicy(raw json.RawMessage, source string) (*Policy, error) {
+	policy := &Policy{Mode: Allowlist, Source: source}
+	if len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null" {
+		return policy, nil
+	}
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		policy.Mode = mode
+	} else {
+		var object struct {
+			Policy string   `json:"policy"`
+			Allow  []string `json:"allow"`
+		}
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", source, err)
+		}
+		if object.Policy != "" {
+			policy.Mode = object.Policy
+		}
+		for _, spec := range object.Allow {
+			entry, err := parseEntry(spec)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s.allow entry %q: %v", source, spec, err)
+			}
+			policy.Allow = append(policy.Allow, entry)
+		}
+	}
+	switch policy.Mode {
+	case AllowAll, DenyAll, Allowlist:
+	default:
+		return nil, fmt.Errorf("invalid %s policy %q (expected %s, %s or %s)", source, policy.Mode, AllowAll, DenyAll, Allowlist)
+	}
+	if policy.Mode != Allowlist && len(policy.Allow) > 0 {
+		return nil, fmt.Errorf("%s.allow requires the %s policy", source, Allowlist)
+	}
+	return policy, nil
+}
+
+func parseEntry(spec string) (Entry, error) {
+	name, rng := spec, ""
+	if idx := strings.LastIndex(spec, "@"); idx > 0 {
+		name, rng = spec[:idx], spec[idx+1:]
+	}
+	if name == "" {
+		return Entry{}, fmt.Errorf("missing package name")
+	}
+	if rng != "" && !semver.ValidRange(rng) {
+		return Entry{}, fmt.Errorf("invalid version range %q", rng)
+	}
+	return Entry{Name: name, Range: rng}, nil
+}
+
+// Allows meldet, ob name@version Lifecycle-Skripte ausführen darf.
+func (p *Policy) Allows(name, version string) bool {
+	if p == nil {
+		return false
+	}
+	switch p.Mode {
+	case AllowAll:
+		return true
+	case DenyAll:
+		return false
+	}
+	for _, entry := range p.Allow {
+		if entry.Name == name && (entry.Range == "" || semver.Satisfies(version, entry.Range)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scripts liest die Lifecycle-Skripte aus der package.json in dir. Wie bei npm gilt
+// "node-gyp rebuild" als install-Skript, wenn ein binding.gyp existiert und weder
+// preinstall noch install definiert sind.
+func Scripts(dir string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %v", err)
+	}
+	var manifest struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %v", err)
+	}
+	scripts := make(map[string]string)
+	for _, event := range append(InstallEvents, "prepare") {
+		if script := manifest.Scripts[event]; script != "" {
+			scripts[event] = script
+		}
+	}
+	if scripts["preinstall"] == "" && scripts["install"] == "" {
+		if _, err := os.Stat(filepath.Join(dir, "binding.gyp")); err == nil {
+			scripts["install"] = "node-gyp rebuild"
+		}
+	}
+	return scripts, nil
+}
+
+// Order sortiert die Pakete so, dass Abhängigkeiten vor ihren Abhängigen stehen.
+// Zyklen werden nach Name aufgebrochen, damit die Reihenfolge deterministisch bleibt.
+func Order(packages map[string]types.Package) []string {
+	pending := make(map[string]bool, len(packages))
+	for name := range packages {
+		pending[name] = true
+	}
+	var result []string
+	for len(pending) > 0 {
+		var ready []string
+		for name := range pending {
+			if !waiting(packages[name], pending, name) {
+				ready = append(ready, name)
+			}
+		}
+		sort.Strings(ready)
+		if len(ready) == 0 {
+			ready = []string{first(pending)}
+		}
+		for _, name := range ready {
+			delete(pending, name)
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// waiting meldet, ob pkg noch auf eine nicht einsortierte Abhängigkeit wartet.
+func waiting(pkg types.Package, pending map[string]bool, self string) bool {
+	for _, deps := range []map[string]string{pkg.Deps, pkg.OptionalDeps, pkg.PeerDeps} {
+		for dep := range deps {
+			if dep != self && pending[dep] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func first(set map[string]bool) string {
+	var names []string
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names[0]
+}
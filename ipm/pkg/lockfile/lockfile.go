@@ -0,0 +1,133 @@
+package lockfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	FileName = "ipm-lock.json"
+	Version  = 1
+)
+
+type Lockfile struct {
+	LockfileVersion int `json:"lockfileVersion"`
+	// Dependencies enthält die direkt installierten Pakete mit der angeforderten Versionsangabe
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	// Overridden markiert direkt installierte Pakete, deren Angabe ein Override ersetzt hat
+	Overridden map[string]Override `json:"overridden,omitempty"`
+	Packages   map[string]Package  `json:"packages"`
+	// SkippedOptional enthält je Plattform (z. B. "linux-x64") die übersprungenen optionalDependencies
+	SkippedOptional map[string][]Skipped `json:"skippedOptional,omitempty"`
+}
+
+type Package struct {
+	// Name ist wie bei npm der Name aus der package.json des Pakets, sofern er vom
+	// Namen unter node_modules abweicht, z. B. bei "lib": "file:lib-1.0.0.tgz"
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version"`
+	// Resolved und Integrity pinnen Pakete, die nicht aus der Standard-Registry stammen,
+	// z. B. "npm:other@1.2.3", eine Tarball-URL oder "file:../lib". Digest ist der
+	// Inhaltsdigest (signing.Manifest) bei der Installation für "ipm verify --installed"
+	Resolved             string            `json:"resolved,omitempty"`
+	Integrity            string            `json:"integrity,omitempty"`
+	Digest               string            `json:"digest,omitempty"`
+	Dependencies         map[string]string `json:"dependencies,omitempty"`
+	OptionalDependencies map[string]string `json:"optionalDependencies,omitempty"`
+	PeerDependencies     map[string]string `json:"peerDependencies,omitempty"`
+	// Overridden markiert Kanten zu Abhängigkeiten, deren Angabe ein Override ersetzt hat
+	Overridden map[string]Override `json:"overridden,omitempty"`
+}
+
+type Override struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Rule string `json:"rule"`
+}
+
+type Skipped struct {
+	Name       string `json:"name"`
+	Range      string `json:"range"`
+	RequiredBy string `json:"requiredBy"`
+	Reason     string `json:"reason"`
+}
+
+func New() *Lockfile {
+	return &Lockfile{
+		LockfileVersion: Version,
+		Packages:        make(map[string]Package),
+	}
+}
+
+// Load liest die Lockfile aus dir. Existiert keine, wird eine leere geliefert.
+func Load(dir string) (*Lockfile, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %v", err)
+	}
+	lf := New()
+	if err := json.Unmarshal(data, lf); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile: %v", err)
+	}
+	if lf.LockfileVersion > Version {
+		return nil, fmt.Errorf("lockfile version %d is not supported (max %d)", lf.LockfileVersion, Version)
+	}
+	if lf.Packages == nil {
+		lf.Packages = make(map[string]Package)
+	}
+	return lf, nil
+}
+
+// Save schreibt die Lockfile deterministisch (sortierte Schlüssel und Listen) nach dir.
+func (l *Lockfile) Save(dir string) error {
+	for platform, skipped := range l.SkippedOptional {
+		if len(skipped) == 0 {
+			delete(l.SkippedOptional, platform)
+			continue
+		}
+		sort.Slice(skipped, func(a, b int) bool {
+			if skipped[a].Name != skipped[b].Name {
+				return skipped[a].Name < skipped[b].Name
+			}
+			return skipped[a].RequiredBy < skipped[b].RequiredBy
+		})
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // Ranges wie "<2.0.0" lesbar halten
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(l); err != nil {
+		return fmt.Errorf("failed to marshal lockfile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %v", err)
+	}
+	return nil
+}
+
+// SetSkipped ergänzt die übersprungenen optionalen Abhängigkeiten einer Plattform.
+// Inzwischen installierte Pakete werden aus der Liste entfernt.
+func (l *Lockfile) SetSkipped(platform string, skipped []Skipped) {
+	if l.SkippedOptional == nil {
+		l.SkippedOptional = make(map[string][]Skipped)
+	}
+	merged := make(map[string]Skipped)
+	for _, s := range append(l.SkippedOptional[platform], skipped...) {
+		if _, installed := l.Packages[s.Name]; installed {
+			continue
+		}
+		merged[s.Name+"\x00"+s.RequiredBy] = s
+	}
+	list := make([]Skipped, 0, len(merged))
+	for _, s := range merged {
+		list = append(list, s)
+	}
+	l.SkippedOptional[platform] = list
+}
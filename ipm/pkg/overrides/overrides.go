@@ -0,0 +1,260 @@
+package overrides
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ipm/pkg/semver"
+)
+
+// Globstar steht in einem Muster für beliebig viele (auch keine) Vorfahren.
+const Globstar = "**"
+
+// Segment ist ein Element eines Musters, z. B. "bar" oder "qux@2".
+type Segment struct {
+	Name  string
+	Range string // leer = jede Version
+}
+
+func (s Segment) String() string {
+	if s.Range == "" {
+		return s.Name
+	}
+	return s.Name + "@" + s.Range
+}
+
+// Rule ersetzt die Versionsangabe aller Kanten, deren Pfad auf Pattern passt.
+// Das letzte Segment ist das Ziel, alle vorherigen beschreiben die Vorfahren.
+type Rule struct {
+	Pattern []Segment
+	Spec    string
+	Source  string // z. B. "overrides.bar.baz" oder "resolutions[a/**/foo]"
+}
+
+// specificity zählt die konkreten Segmente; spezifischere Regeln gewinnen.
+func (r Rule) specificity() int {
+	n := 0
+	for _, seg := range r.Pattern {
+		if seg.Name != Globstar {
+			n++
+		}
+	}
+	return n
+}
+
+// Ancestor ist ein bereits aufgelöster Knoten auf dem Pfad von der Wurzel zur Kante.
+type Ancestor struct {
+	Name    string
+	Version string
+}
+
+func (a Ancestor) String() string {
+	return a.Name + "@" + a.Version
+}
+
+type Set struct {
+	Rules []Rule
+}
+
+func (s *Set) Empty() bool {
+	return s == nil || len(s.Rules) == 0
+}
+
+// Match liefert die spezifischste Regel für die Kante von ancestors (Wurzel zuerst)
+// zum Paket name. resolve liefert die Version, auf die die ursprüngliche Angabe
+// auflöst, und wird nur für Ziele mit Range-Selektor (z. B. "foo@1") aufgerufen.
+func (s *Set) Match(ancestors []Ancestor, name string, resolve func() (string, error)) (*Rule, error) {
+	if s.Empty() {
+		return nil, nil
+	}
+	var best *Rule
+	var resolved string
+	for idx := range s.Rules {
+		rule := &s.Rules[idx]
+		target := rule.Pattern[len(rule.Pattern)-1]
+		if target.Name != name || !matchPath(rule.Pattern[:len(rule.Pattern)-1], ancestors) {
+			continue
+		}
+		if target.Range != "" {
+			if resolved == "" {
+				v, err := resolve()
+				if err != nil {
+					return nil, err
+				}
+				resolved = v
+			}
+			if !semver.Satisfies(resolved, target.Range) {
+				continue
+			}
+		}
+		if best == nil || rule.specificity() > best.specificity() {
+			best = rule
+		}
+	}
+	return best, nil
+}
+
+// matchPath prüft, ob pattern die Vorfahren vollständig beschreibt.
+func matchPath(pattern []Segment, ancestors []Ancestor) bool {
+	if len(pattern) == 0 {
+		return len(ancestors) == 0
+	}
+	if pattern[0].Name == Globstar {
+		for skip := 0; skip <= len(ancestors); skip++ {
+			if matchPath(pattern[1:], ancestors[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(ancestors) == 0 || !matchSegment(pattern[0], ancestors[0]) {
+		return false
+	}
+	return matchPath(pattern[1:], ancestors[1:])
+}
+
+func matchSegment(seg Segment, a Ancestor) bool {
+	if seg.Name != a.Name {
+		return false
+	}
+	return seg.Range == "" || semver.Satisfies(a.Version, seg.Range)
+}
+
+// parseSelector trennt "name@range" und berücksichtigt Scoped-Namen wie "@acme/plc@1".
+func parseSelector(key string) Segment {
+	if idx := strings.LastIndex(key, "@"); idx > 0 {
+		return Segment{Name: key[:idx], Range: key[idx+1:]}
+	}
+	return Segment{Name: key}
+}
+
+// ParseNPM liest einen "overrides"-Abschnitt mit npm-Semantik. Verschachtelte
+// Objekte gelten im Teilbaum ihres Schlüssels, "." überschreibt den Schlüssel
+// selbst, und "$name" verweist auf die Angabe in den Abhängigkeiten des Projekts.
+func ParseNPM(raw json.RawMessage, rootDeps map[string]string, source string) ([]Rule, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	var rules []Rule
+	if err := parseNPMObject(dec, nil, rootDeps, source, &rules); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", source, err)
+	}
+	return rules, nil
+}
+
+// parseNPMObject liest die Schlüssel in Dateireihenfolge, damit das Ergebnis deterministisch ist.
+func parseNPMObject(dec *json.Decoder, prefix []Segment, rootDeps map[string]string, source string, rules *[]Rule) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected object at %s", source)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		keySource := source + "." + key
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		var spec string
+		if err := json.Unmarshal(value, &spec); err != nil {
+			// Verschachteltes Objekt: gilt im Teilbaum von key
+			nested := append(append([]Segment{}, prefix...), Segment{Name: Globstar}, parseSelector(key))
+			if err := parseNPMObject(json.NewDecoder(bytes.NewReader(value)), nested, rootDeps, keySource, rules); err != nil {
+				return err
+			}
+			continue
+		}
+
+		spec, err = expandReference(spec, rootDeps)
+		if err != nil {
+			return fmt.Errorf("%s: %v", keySource, err)
+		}
+		var pattern []Segment
+		if key == "." {
+			if len(prefix) == 0 {
+				return fmt.Errorf("%s: \".\" is only allowed in nested overrides", keySource)
+			}
+			pattern = append([]Segment{}, prefix...)
+		} else {
+			pattern = append(append([]Segment{}, prefix...), Segment{Name: Globstar}, parseSelector(key))
+		}
+		*rules = append(*rules, Rule{Pattern: pattern, Spec: spec, Source: keySource})
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func expandReference(spec string, rootDeps map[string]string) (string, error) {
+	if !strings.HasPrefix(spec, "$") {
+		return spec, nil
+	}
+	name := spec[1:]
+	if ref, ok := rootDeps[name]; ok {
+		return ref, nil
+	}
+	return "", fmt.Errorf("unable to resolve reference %s: %s is not a dependency of the project", spec, name)
+}
+
+// ParseYarn liest einen "resolutions"-Abschnitt mit yarn-Semantik: "foo" und
+// "**/foo" gelten überall, "a/foo" nur für die direkte Abhängigkeit foo des
+// Projekt-Pakets a, "a/**/foo" für foo an beliebiger Stelle unterhalb von a.
+func ParseYarn(resolutions map[string]string, source string) ([]Rule, error) {
+	keys := make([]string, 0, len(resolutions))
+	for key := range resolutions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var rules []Rule
+	for _, key := range keys {
+		pattern, err := parseYarnPattern(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s[%s]: %v", source, key, err)
+		}
+		rules = append(rules, Rule{
+			Pattern: pattern,
+			Spec:    resolutions[key],
+			Source:  fmt.Sprintf("%s[%s]", source, key),
+		})
+	}
+	return rules, nil
+}
+
+func parseYarnPattern(key string) ([]Segment, error) {
+	parts := strings.Split(key, "/")
+	var pattern []Segment
+	for idx := 0; idx < len(parts); idx++ {
+		part := parts[idx]
+		if strings.HasPrefix(part, "@") {
+			// Scoped-Name umfasst zwei Pfadteile
+			if idx+1 >= len(parts) {
+				return nil, fmt.Errorf("incomplete scoped package name %q", part)
+			}
+			idx++
+			part += "/" + parts[idx]
+		}
+		if part == "" {
+			return nil, fmt.Errorf("empty path segment")
+		}
+		pattern = append(pattern, parseSelector(part))
+	}
+	if pattern[len(pattern)-1].Name == Globstar {
+		return nil, fmt.Errorf("pattern must end with a package name")
+	}
+	if len(pattern) == 1 {
+		pattern = append([]Segment{{Name: Globstar}}, pattern...)
+	}
+	return pattern, nil
+}
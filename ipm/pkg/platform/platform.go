@@ -0,0 +1,115 @@
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"ipm/pkg/semver"
+	"ipm/pkg/types"
+)
+
+// OS liefert das Betriebssystem in der Schreibweise von Node (process.platform).
+func OS() string {
+	if runtime.GOOS == "windows" {
+		return "win32"
+	}
+	return runtime.GOOS
+}
+
+// Arch liefert die CPU-Architektur in der Schreibweise von Node (process.arch).
+func Arch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64"
+	case "386":
+		return "ia32"
+	case "ppc64le":
+		return "ppc64"
+	}
+	return runtime.GOARCH
+}
+
+// Current liefert die Plattform, z. B. "linux-x64" oder "win32-x64".
+func Current() string {
+	return OS() + "-" + Arch()
+}
+
+// Check prüft die Felder "os" und "cpu" eines Pakets gegen die aktuelle Plattform.
+func Check(pkg types.Package) error {
+	if !matches(pkg.Os, OS()) {
+		return fmt.Errorf("unsupported platform for %s@%s: os %s not in %v", pkg.Name, pkg.Version, OS(), pkg.Os)
+	}
+	if !matches(pkg.Cpu, Arch()) {
+		return fmt.Errorf("unsupported platform for %s@%s: cpu %s not in %v", pkg.Name, pkg.Version, Arch(), pkg.Cpu)
+	}
+	return nil
+}
+
+// matches wertet os/cpu-Listen wie npm aus: leere Liste erlaubt alles,
+// "!x" schließt x aus, sonst muss der Wert (oder "any") enthalten sein.
+func matches(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	allowed, positive := false, false
+	for _, entry := range list {
+		if strings.HasPrefix(entry, "!") {
+			if entry[1:] == value {
+				return false
+			}
+			continue
+		}
+		positive = true
+		if entry == value || entry == "any" {
+			allowed = true
+		}
+	}
+	return allowed || !positive
+}
+
+var (
+	nodeOnce    sync.Once
+	nodeVersion string
+)
+
+// NodeVersion liefert die Version des installierten Node.js oder "", wenn keines gefunden wurde.
+func NodeVersion() string {
+	nodeOnce.Do(func() {
+		out, err := exec.Command("node", "--version").Output()
+		if err == nil {
+			nodeVersion = strings.TrimPrefix(strings.TrimSpace(string(out)), "v")
+		}
+	})
+	return nodeVersion
+}
+
+// CheckEngines prüft "engines.node" gegen das installierte Node.js. Ohne Node.js
+// lässt sich die Anforderung nicht prüfen und gilt als erfüllt.
+func CheckEngines(pkg types.Package) error {
+	want, ok := pkg.Engines["node"]
+	if !ok {
+		return nil
+	}
+	have := NodeVersion()
+	if have == "" || semver.Satisfies(have, want) {
+		return nil
+	}
+	return fmt.Errorf("unsupported engine for %s@%s: wanted node %s, current node %s", pkg.Name, pkg.Version, want, have)
+}
+
+// ParseEngines liest das Feld "engines". Ältere Pakete verwenden statt eines
+// Objekts ein Array; solche Angaben werden ignoriert.
+func ParseEngines(raw json.RawMessage) map[string]string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var engines map[string]string
+	if err := json.Unmarshal(raw, &engines); err != nil {
+		return nil
+	}
+	return engines
+}
@@ -0,0 +1,295 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"ipm/pkg/cache"
+)
+
+// ProtocolVersion ist die Version der Aufrufkonvention für Plugin-Kommandos.
+const ProtocolVersion = "1"
+
+// Command ist ein von einem Paket bereitgestelltes Kommando. Pakete melden
+// Kommandos in ihrer package.json an:
+//
+//	"ipm": {
+//	  "commands": {
+//	    "compile": "bin/compile",
+//	    "lint": { "bin": "bin/lint", "description": "Lint ST sources" }
+//	  }
+//	}
+type Command struct {
+	Name        string
+	Description string
+	Package     string
+	Version     string
+	Dir         string // Verzeichnis des Pakets in node_modules
+	Path        string // ausführbare Datei
+}
+
+// Context sind die Einstellungen, die ipm an Plugins weitergibt.
+type Context struct {
+	Root     string
+	JSON     bool
+	LogLevel string
+	LogFile  string
+	Registry string
+}
+
+// FindRoot sucht ausgehend von dir das nächste Verzeichnis mit package.json oder
+// node_modules. Ohne Treffer ist dir selbst die Wurzel.
+func FindRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	for current := abs; ; {
+		for _, marker := range []string{"package.json", "node_modules"} {
+			if _, err := os.Stat(filepath.Join(current, marker)); err == nil {
+				return current
+			}
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return abs
+		}
+		current = parent
+	}
+}
+
+// Discover liest die Kommandos aller Pakete in root/node_modules, sortiert nach
+// Name. Melden zwei Pakete dasselbe Kommando an, wird es nicht registriert; der
+// Konflikt und ungültige Einträge werden als Fehler geliefert.
+func Discover(root string) ([]Command, []error) {
+	modules := filepath.Join(root, "node_modules")
+	dirs, err := packageDirs(modules)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, []error{fmt.Errorf("failed to read %s: %v", modules, err)}
+	}
+
+	var problems []error
+	byName := make(map[string][]Command)
+	for _, dir := range dirs {
+		commands, err := readCommands(dir)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		for _, cmd := range commands {
+			byName[cmd.Name] = append(byName[cmd.Name], cmd)
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var result []Command
+	for _, name := range names {
+		candidates := byName[name]
+		if len(candidates) > 1 {
+			var providers []string
+			for _, cmd := range candidates {
+				providers = append(providers, cmd.Package)
+			}
+			problems = append(problems, fmt.Errorf("command %q is provided by several packages (%s) and was not registered", name, strings.Join(providers, ", ")))
+			continue
+		}
+		result = append(result, candidates[0])
+	}
+	return result, problems
+}
+
+// packageDirs liefert die Paketverzeichnisse in node_modules inklusive @scope/name.
+func packageDirs(modules string) ([]string, error) {
+	entries, err := os.ReadDir(modules)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(modules, name)
+		if !strings.HasPrefix(name, "@") {
+			dirs = append(dirs, path)
+			continue
+		}
+		scoped, err := os.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, s := range scoped {
+			dirs = append(dirs, filepath.Join(path, s.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func readCommands(dir string) ([]Command, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, nil // kein Paket, z. B. ein leeres Scope-Verzeichnis
+	}
+	var manifest struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Ipm     struct {
+			Commands map[string]json.RawMessage `json:"commands"`
+		} `json:"ipm"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filepath.Join(dir, "package.json"), err)
+	}
+
+	var commands []Command
+	for name, raw := range manifest.Ipm.Commands {
+		var entry struct {
+			Bin         string `json:"bin"`
+			Description string `json:"description"`
+		}
+		if err := json.Unmarshal(raw, &entry.Bin); err != nil {
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				return nil, fmt.Errorf("invalid ipm command %q in %s: %v", name, manifest.Name, err)
+			}
+		}
+		target := filepath.Clean(filepath.FromSlash(entry.Bin))
+		if !validName(name) || entry.Bin == "" || filepath.IsAbs(target) || target == ".." || strings.HasPrefix(target, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("invalid ipm command %q in %s", name, manifest.Name)
+		}
+		description := entry.Description
+		if description == "" {
+			description = fmt.Sprintf("Provided by %s@%s", manifest.Name, manifest.Version)
+		}
+		commands = append(commands, Command{
+			Name:        name,
+			Description: description,
+			Package:     manifest.Name,
+			Version:     manifest.Version,
+			Dir:         dir,
+			Path:        filepath.Join(dir, target),
+		})
+	}
+	return commands, nil
+}
+
+// validName erlaubt nur Namen, die sich als Unterkommando tippen lassen.
+func validName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return false
+	}
+	for _, c := range name {
+		if !(c == '-' || c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// Run startet das Kommando mit den Argumenten unverändert im aktuellen Verzeichnis.
+// Ein- und Ausgabe werden durchgereicht; geliefert wird der Exit-Code. Die
+// Umgebung beschreibt Env.
+func (c Command) Run(ctx Context, args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	if _, err := os.Stat(c.Path); err != nil {
+		return 1, fmt.Errorf("command %s of %s: %v", c.Name, c.Package, err)
+	}
+	name, cmdArgs := c.Path, args
+	if runtime.GOOS == "windows" {
+		// Skripte mit Shebang über den Interpreter starten, z. B. "node bin/compile"
+		if prog, progArgs := cache.Shebang(c.Path); prog != "" {
+			name = prog
+			cmdArgs = append(append(strings.Fields(progArgs), c.Path), args...)
+		}
+	}
+
+	cmd := exec.Command(name, cmdArgs...)
+	cmd.Env = c.Env(ctx)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, fmt.Errorf("failed to run command %s of %s: %v", c.Name, c.Package, err)
+	}
+	return 0, nil
+}
+
+// Env liefert die Umgebung des Plugin-Prozesses. Zusätzlich zu der von ipm gilt:
+//
+//	IPM_PLUGIN_PROTOCOL  Version dieser Aufrufkonvention ("1")
+//	IPM_COMMAND          Name des Kommandos
+//	IPM_PROJECT_ROOT     absoluter Pfad des Projekts
+//	IPM_PLUGIN_PACKAGE   Name und
+//	IPM_PLUGIN_VERSION   Version des bereitstellenden Pakets
+//	IPM_PLUGIN_DIR       absoluter Pfad dieses Pakets
+//	IPM_JSON             "1", wenn JSON-Ausgabe gewünscht ist (--json), sonst "0"
+//	IPM_LOG_LEVEL        Wert von --log-level (leer: keine Logs)
+//	IPM_LOG_FILE         Wert von --log-file
+//	IPM_REGISTRY         Registry-URL
+//	IPM_EXECPATH         Pfad der ipm-Binary für Rückrufe
+//
+// node_modules/.bin des Projekts steht vorn im PATH.
+func (c Command) Env(ctx Context) []string {
+	jsonOutput := "0"
+	if ctx.JSON {
+		jsonOutput = "1"
+	}
+	execPath, _ := os.Executable()
+	vars := map[string]string{
+		"IPM_PLUGIN_PROTOCOL": ProtocolVersion,
+		"IPM_COMMAND":         c.Name,
+		"IPM_PROJECT_ROOT":    ctx.Root,
+		"IPM_PLUGIN_PACKAGE":  c.Package,
+		"IPM_PLUGIN_VERSION":  c.Version,
+		"IPM_PLUGIN_DIR":      c.Dir,
+		"IPM_JSON":            jsonOutput,
+		"IPM_LOG_LEVEL":       ctx.LogLevel,
+		"IPM_LOG_FILE":        ctx.LogFile,
+		"IPM_REGISTRY":        ctx.Registry,
+		"IPM_EXECPATH":        execPath,
+	}
+
+	bin := filepath.Join(ctx.Root, "node_modules", cache.BinDir)
+	var env []string
+	pathSet := false
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		if _, ok := vars[key]; ok {
+			continue
+		}
+		if strings.EqualFold(key, "PATH") {
+			entry = key + "=" + bin + string(os.PathListSeparator) + value
+			pathSet = true
+		}
+		env = append(env, entry)
+	}
+	if !pathSet {
+		env = append(env, "PATH="+bin)
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+	return env
+}
@@ -0,0 +1,276 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ipm/pkg/lifecycle"
+	"ipm/pkg/overrides"
+)
+
+const (
+	ManifestFile = "package.json"
+	ConfigFile   = "ipm.json"
+)
+
+// Manifest enthält die für IPM relevanten Felder der package.json eines Projekts.
+type Manifest struct {
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+	Scripts              map[string]string `json:"scripts"`
+	Overrides            json.RawMessage   `json:"overrides"`   // npm
+	Resolutions          map[string]string `json:"resolutions"` // yarn
+	Workspaces           json.RawMessage   `json:"workspaces"`  // ["packages/*"] oder {"packages": [...]}
+}
+
+// Config ist die projektbezogene IPM-Konfiguration aus ipm.json.
+type Config struct {
+	Overrides  json.RawMessage `json:"overrides"`
+	Workspaces []string        `json:"workspaces"`
+	Scripts    json.RawMessage `json:"scripts"`    // Richtlinie für Lifecycle-Skripte von Abhängigkeiten
+	Keys       json.RawMessage `json:"keys"`       // vertrauenswürdige Signaturschlüssel, siehe keyring
+	Signatures json.RawMessage `json:"signatures"` // Richtlinie für Paketsignaturen
+	// Revocations ist der Pfad einer signierten Sperrliste für Schlüssel, relativ zum Projekt
+	Revocations string `json:"revocations"`
+}
+
+// LoadManifest liest die package.json aus dir. Fehlt sie, wird nil geliefert.
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", ManifestFile, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ManifestFile, err)
+	}
+	return &m, nil
+}
+
+// LoadConfig liest die ipm.json aus dir. Eine fehlende oder leere Datei ergibt eine leere Konfiguration.
+func LoadConfig(dir string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", ConfigFile, err)
+	}
+	var c Config
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &c, nil
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ConfigFile, err)
+	}
+	return &c, nil
+}
+
+// SetConfigField setzt ein Feld der ipm.json in dir und lässt die übrigen
+// unverändert. Ist value nil, wird das Feld entfernt.
+func SetConfigField(dir, field string, value interface{}) error {
+	path := filepath.Join(dir, ConfigFile)
+	fields := map[string]json.RawMessage{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", ConfigFile, err)
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", ConfigFile, err)
+		}
+	}
+	if value == nil {
+		delete(fields, field)
+	} else {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %v", field, err)
+		}
+		fields[field] = raw
+	}
+	out, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", ConfigFile, err)
+	}
+	if err := os.WriteFile(path, append(out, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", ConfigFile, err)
+	}
+	return nil
+}
+
+// dependencyFields sind die Felder der package.json, aus denen RemoveDependency entfernt.
+var dependencyFields = []string{"dependencies", "devDependencies", "optionalDependencies"}
+
+// RemoveDependency entfernt name aus dependencies, devDependencies und
+// optionalDependencies der package.json in dir. Die Reihenfolge der übrigen
+// Felder bleibt erhalten. Geliefert werden die Felder, aus denen name entfernt
+// wurde; ohne package.json oder Eintrag bleibt die Datei unverändert.
+func RemoveDependency(dir, name string) ([]string, error) {
+	path := filepath.Join(dir, ManifestFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", ManifestFile, err)
+	}
+	fields, err := readObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ManifestFile, err)
+	}
+	var removed []string
+	for idx, field := range fields {
+		if !contains(dependencyFields, field.name) || string(field.value) == "null" {
+			continue
+		}
+		deps, err := readObject(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s:%s: %v", ManifestFile, field.name, err)
+		}
+		for depIdx, dep := range deps {
+			if dep.name == name {
+				fields[idx].value = writeObject(append(deps[:depIdx:depIdx], deps[depIdx+1:]...))
+				removed = append(removed, field.name)
+				break
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, writeObject(fields), "", "  "); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %v", ManifestFile, err)
+	}
+	out.WriteByte('\n')
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", ManifestFile, err)
+	}
+	return removed, nil
+}
+
+// member ist ein Feld eines JSON-Objekts in der Reihenfolge der Datei.
+type member struct {
+	name  string
+	value json.RawMessage
+}
+
+func readObject(data []byte) ([]member, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	var members []member
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		members = append(members, member{name: tok.(string), value: value})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func writeObject(members []member) json.RawMessage {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for idx, m := range members {
+		if idx > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(m.name)
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(m.value)
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AllDependencies fasst alle Abhängigkeitsarten zusammen; "dependencies" hat Vorrang.
+func (m *Manifest) AllDependencies() map[string]string {
+	all := make(map[string]string)
+	for _, deps := range []map[string]string{m.PeerDependencies, m.OptionalDependencies, m.DevDependencies, m.Dependencies} {
+		for name, spec := range deps {
+			all[name] = spec
+		}
+	}
+	return all
+}
+
+// LoadOverrides sammelt die Overrides eines Projekts: "overrides" aus ipm.json,
+// danach "overrides" (npm) und "resolutions" (yarn) aus package.json. Bei gleich
+// spezifischen Regeln gewinnt die zuerst genannte.
+func LoadOverrides(dir string) (*overrides.Set, error) {
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	config, err := LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rootDeps := map[string]string{}
+	if manifest != nil {
+		rootDeps = manifest.AllDependencies()
+	}
+
+	set := &overrides.Set{}
+	rules, err := overrides.ParseNPM(config.Overrides, rootDeps, ConfigFile+":overrides")
+	if err != nil {
+		return nil, err
+	}
+	set.Rules = append(set.Rules, rules...)
+
+	if manifest != nil {
+		rules, err = overrides.ParseNPM(manifest.Overrides, rootDeps, "overrides")
+		if err != nil {
+			return nil, err
+		}
+		set.Rules = append(set.Rules, rules...)
+
+		rules, err = overrides.ParseYarn(manifest.Resolutions, "resolutions")
+		if err != nil {
+			return nil, err
+		}
+		set.Rules = append(set.Rules, rules...)
+	}
+	return set, nil
+}
+
+// LoadScriptPolicy liest die Richtlinie für Lifecycle-Skripte aus ipm.json.
+func LoadScriptPolicy(dir string) (*lifecycle.Policy, error) {
+	config, err := LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	return lifecycle.ParsePolicy(config.Scripts, ConfigFile+":scripts")
+}
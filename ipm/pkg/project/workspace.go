@@ -0,0 +1,102 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Member ist ein Projekt innerhalb eines Workspaces.
+type Member struct {
+	Dir      string // relativ zur Wurzel, mit "/" getrennt
+	Manifest *Manifest
+}
+
+// Workspace ist ein Projekt mit seinen Mitgliedern. Ohne "workspaces" ist Members leer.
+type Workspace struct {
+	Dir     string
+	Root    *Manifest
+	Members []Member
+}
+
+// WorkspacePatterns liest "workspaces" als Liste oder in der Form {"packages": [...]}.
+func (m *Manifest) WorkspacePatterns() ([]string, error) {
+	if len(m.Workspaces) == 0 || string(m.Workspaces) == "null" {
+		return nil, nil
+	}
+	var patterns []string
+	if err := json.Unmarshal(m.Workspaces, &patterns); err == nil {
+		return patterns, nil
+	}
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(m.Workspaces, &object); err != nil {
+		return nil, fmt.Errorf("invalid workspaces in %s: %v", ManifestFile, err)
+	}
+	return object.Packages, nil
+}
+
+// LoadWorkspace liest das Projekt in dir und seine Mitglieder. Die Glob-Muster
+// stammen aus "workspaces" in ipm.json oder, falls dort keine stehen, aus der
+// package.json. Treffer ohne package.json werden ignoriert.
+func LoadWorkspace(dir string) (*Workspace, error) {
+	root, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no %s found in %s", ManifestFile, dir)
+	}
+	config, err := LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	patterns := config.Workspaces
+	if len(patterns) == 0 {
+		if patterns, err = root.WorkspacePatterns(); err != nil {
+			return nil, err
+		}
+	}
+
+	ws := &Workspace{Dir: dir, Root: root}
+	seen := make(map[string]string)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace pattern %q: %v", pattern, err)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || !info.IsDir() {
+				continue
+			}
+			manifest, err := LoadManifest(match)
+			if err != nil {
+				return nil, err
+			}
+			if manifest == nil {
+				continue
+			}
+			rel, err := filepath.Rel(dir, match)
+			if err != nil {
+				return nil, err
+			}
+			rel = filepath.ToSlash(rel)
+			if manifest.Name == "" || manifest.Version == "" {
+				return nil, fmt.Errorf("workspace member %s needs a name and a version", rel)
+			}
+			if other, ok := seen[manifest.Name]; ok {
+				if other == rel {
+					continue
+				}
+				return nil, fmt.Errorf("workspace members %s and %s are both named %s", other, rel, manifest.Name)
+			}
+			seen[manifest.Name] = rel
+			ws.Members = append(ws.Members, Member{Dir: rel, Manifest: manifest})
+		}
+	}
+	return ws, nil
+}
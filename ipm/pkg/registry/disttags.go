@@ -0,0 +1,192 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"ipm/pkg/log"
+	"ipm/pkg/semver"
+)
+
+// escapeName kodiert Scoped-Namen (@scope/name) so, wie es die npm-API erwartet.
+func escapeName(name string) string {
+	return url.PathEscape(name)
+}
+
+func (r *NPMRegistry) newRequest(method, reqURL string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, reqURL, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	return req, nil
+}
+
+func (r *NPMRegistry) do(req *http.Request) ([]byte, error) {
+	log.Debug("Sending request to registry", map[string]interface{}{
+		"method": req.Method,
+		"url":    req.URL.String(),
+	})
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %v", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Error("Registry request failed", nil, map[string]interface{}{
+			"status": resp.Status,
+			"url":    req.URL.String(),
+		})
+		return nil, fmt.Errorf("%s %s failed with status: %s", req.Method, req.URL, resp.Status)
+	}
+	return data, nil
+}
+
+// DistTags liefert alle dist-tags eines Pakets (z. B. "latest", "beta").
+func (r *NPMRegistry) DistTags(name string) (map[string]string, error) {
+	req, err := r.newRequest("GET", fmt.Sprintf("%s/-/package/%s/dist-tags", r.BaseURL, escapeName(name)), nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("failed to parse dist-tags: %v", err)
+	}
+	return tags, nil
+}
+
+// AddDistTag setzt tag auf die angegebene, exakte Version.
+func (r *NPMRegistry) AddDistTag(name, tag, version string) error {
+	if semver.ValidRange(tag) {
+		return fmt.Errorf("dist-tag %q is a valid version range and cannot be used as a tag", tag)
+	}
+	body, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	req, err := r.newRequest("PUT", fmt.Sprintf("%s/-/package/%s/dist-tags/%s", r.BaseURL, escapeName(name), url.PathEscape(tag)), body)
+	if err != nil {
+		return err
+	}
+	if _, err := r.do(req); err != nil {
+		return err
+	}
+	log.Info("Dist-tag added", map[string]interface{}{
+		"package": name,
+		"tag":     tag,
+		"version": version,
+	})
+	return nil
+}
+
+// RemoveDistTag entfernt einen dist-tag. "latest" kann nicht entfernt werden.
+func (r *NPMRegistry) RemoveDistTag(name, tag string) error {
+	if tag == "latest" {
+		return fmt.Errorf("the 'latest' dist-tag cannot be removed")
+	}
+	req, err := r.newRequest("DELETE", fmt.Sprintf("%s/-/package/%s/dist-tags/%s", r.BaseURL, escapeName(name), url.PathEscape(tag)), nil)
+	if err != nil {
+		return err
+	}
+	if _, err := r.do(req); err != nil {
+		return err
+	}
+	log.Info("Dist-tag removed", map[string]interface{}{
+		"package": name,
+		"tag":     tag,
+	})
+	return nil
+}
+
+// Deprecate setzt die Deprecation-Nachricht für alle Versionen, die versionRange
+// erfüllen. Eine leere Nachricht hebt die Deprecation wieder auf.
+// Zurückgegeben werden die betroffenen Versionen.
+func (r *NPMRegistry) Deprecate(name, versionRange, message string) ([]string, error) {
+	constraint, err := semver.ParseRange(versionRange)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgURL := fmt.Sprintf("%s/%s", r.BaseURL, escapeName(name))
+	req, err := r.newRequest("GET", pkgURL+"?write=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Packument generisch halten, damit unbekannte Felder beim PUT erhalten bleiben
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %v", err)
+	}
+	versions, ok := doc["versions"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no versions found for %s", name)
+	}
+
+	var affected []string
+	for verStr, raw := range versions {
+		ver, err := semver.Parse(verStr)
+		if err != nil || !constraint.Test(ver) {
+			continue
+		}
+		manifest, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if message == "" {
+			delete(manifest, "deprecated")
+		} else {
+			manifest["deprecated"] = message
+		}
+		affected = append(affected, verStr)
+	}
+	if len(affected) == 0 {
+		return nil, fmt.Errorf("no version found for %s matching %s", name, versionRange)
+	}
+	sort.Strings(affected)
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	req, err = r.newRequest("PUT", pkgURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.do(req); err != nil {
+		return nil, err
+	}
+	log.Info("Package versions deprecated", map[string]interface{}{
+		"package":  name,
+		"range":    versionRange,
+		"versions": affected,
+	})
+	return affected, nil
+}
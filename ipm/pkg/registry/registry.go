@@ -7,9 +7,9 @@ import (
 	"net/http"
 
 	"ipm/pkg/log"
+	"ipm/pkg/platform"
+	"ipm/pkg/semver"
 	"ipm/pkg/types"
-
-	"github.com/Masterminds/semver/v3"
 )
 
 type Registry interface {
@@ -21,6 +21,8 @@ type NPMRegistry struct {
 	BaseURL string
 	Token   string
 	Client  *http.Client
+	// IncludePrerelease erlaubt Prereleases für jeden passenden Range (--include-prerelease)
+	IncludePrerelease bool
 }
 
 func NewNPMRegistry(baseURL, token string) *NPMRegistry {
@@ -64,12 +66,22 @@ func (r *NPMRegistry) FetchPackageTarball(name, version string) (io.ReadCloser,
 	}
 
 	var pkgData struct {
-		Name         string            `json:"name"`
-		Version      string            `json:"version"`
-		Dist         struct {
-			Tarball string `json:"tarball"`
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Dist    struct {
+			Tarball      string                    `json:"tarball"`
+			Integrity    string                    `json:"integrity"`
+			Signatures   []types.RegistrySignature `json:"signatures"`
+			Attestations *types.Attestations       `json:"attestations"`
 		} `json:"dist"`
-		Dependencies map[string]string `json:"dependencies"`
+		Dependencies         map[string]string                   `json:"dependencies"`
+		PeerDependencies     map[string]string                   `json:"peerDependencies"`
+		PeerDependenciesMeta map[string]types.PeerDependencyMeta `json:"peerDependenciesMeta"`
+		OptionalDependencies map[string]string                   `json:"optionalDependencies"`
+		Os                   []string                            `json:"os"`
+		Cpu                  []string                            `json:"cpu"`
+		Engines              json.RawMessage                     `json:"engines"`
+		Deprecated           string                              `json:"deprecated"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&pkgData); err != nil {
 		log.Error("Failed to parse metadata", err, map[string]interface{}{
@@ -109,9 +121,20 @@ func (r *NPMRegistry) FetchPackageTarball(name, version string) (io.ReadCloser,
 	}
 
 	pkg := types.Package{
-		Name:    pkgData.Name,
-		Version: pkgData.Version,
-		Deps:    pkgData.Dependencies,
+		Name:         pkgData.Name,
+		Version:      pkgData.Version,
+		Deps:         pkgData.Dependencies,
+		PeerDeps:     pkgData.PeerDependencies,
+		PeerDepsMeta: pkgData.PeerDependenciesMeta,
+		OptionalDeps: pkgData.OptionalDependencies,
+		Os:           pkgData.Os,
+		Cpu:          pkgData.Cpu,
+		Engines:      platform.ParseEngines(pkgData.Engines),
+		Deprecated:   pkgData.Deprecated,
+		Tarball:      pkgData.Dist.Tarball,
+		Integrity:    pkgData.Dist.Integrity,
+		Signatures:   pkgData.Dist.Signatures,
+		Attestations: pkgData.Dist.Attestations,
 	}
 	return tarballResp.Body, pkg, nil
 }
@@ -148,8 +171,8 @@ func (r *NPMRegistry) ResolveVersion(name, versionRange string) (string, error)
 	}
 
 	var pkgData struct {
-		Versions  map[string]interface{} `json:"versions"`
-		DistTags  map[string]string      `json:"dist-tags"` // Hinzugefügt für dist-tags
+		Versions map[string]interface{} `json:"versions"`
+		DistTags map[string]string      `json:"dist-tags"` // Hinzugefügt für dist-tags
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&pkgData); err != nil {
 		log.Error("Failed to parse metadata", err, map[string]interface{}{
@@ -158,54 +181,75 @@ func (r *NPMRegistry) ResolveVersion(name, versionRange string) (string, error)
 		return "", fmt.Errorf("failed to parse metadata: %v", err)
 	}
 
-	// Behandle dist-tags wie "latest"
-	if versionRange == "latest" {
-		if latest, ok := pkgData.DistTags["latest"]; ok {
-			log.Debug("Resolved dist-tag 'latest'", map[string]interface{}{
+	// Beliebige dist-tags wie "latest", "beta" oder "next" auflösen
+	if tagged, ok := pkgData.DistTags[versionRange]; ok {
+		// Ein Tag kann auf eine zurückgezogene oder nie veröffentlichte Version zeigen
+		if _, published := pkgData.Versions[tagged]; !published {
+			log.Error("Dist-tag points to unpublished version", nil, map[string]interface{}{
 				"package": name,
-				"version": latest,
+				"tag":     versionRange,
+				"version": tagged,
 			})
-			return latest, nil
+			return "", fmt.Errorf("dist-tag %s of %s points to unpublished version %s", versionRange, name, tagged)
 		}
+		log.Debug("Resolved dist-tag", map[string]interface{}{
+			"package": name,
+			"tag":     versionRange,
+			"version": tagged,
+		})
+		return tagged, nil
+	}
+	if versionRange == "latest" {
 		log.Error("No 'latest' dist-tag found", nil, map[string]interface{}{
 			"package": name,
 		})
 		return "", fmt.Errorf("no 'latest' dist-tag found for %s", name)
 	}
 
-	constraint, err := semver.NewConstraint(versionRange)
+	constraint, err := semver.ParseRangeWithOptions(versionRange, semver.Options{IncludePrerelease: r.IncludePrerelease})
 	if err != nil {
-		log.Error("Invalid version range", err, map[string]interface{}{
+		log.Error("Invalid version range or unknown dist-tag", err, map[string]interface{}{
 			"range": versionRange,
 		})
-		return "", fmt.Errorf("invalid version range %s: %v", versionRange, err)
+		return "", fmt.Errorf("invalid version range or unknown dist-tag %s for %s: %v", versionRange, name, err)
 	}
 
-	var latest *semver.Version
+	versions := make([]string, 0, len(pkgData.Versions))
 	for verStr := range pkgData.Versions {
-		ver, err := semver.NewVersion(verStr)
-		if err != nil {
-			continue
-		}
-		if constraint.Check(ver) {
-			if latest == nil || ver.GreaterThan(latest) {
-				latest = ver
+		versions = append(versions, verStr)
+	}
+	latest := constraint.MaxSatisfying(versions)
+	// Wie npm den dist-tag "latest" bevorzugen, sofern er den Range erfüllt,
+	// etwa bei "*" oder leerem Range neben neueren Versionen
+	if tagged, ok := pkgData.DistTags["latest"]; ok && latest != "" {
+		if _, published := pkgData.Versions[tagged]; published {
+			if v, err := semver.Parse(tagged); err == nil && constraint.Test(v) {
+				latest = tagged
 			}
 		}
 	}
 
-	if latest == nil {
+	if latest == "" {
 		log.Error("No version found matching range", nil, map[string]interface{}{
 			"package": name,
 			"range":   versionRange,
 		})
+		if !r.IncludePrerelease {
+			// Hinweis, falls nur Prereleases den Range erfüllen würden
+			if pre, err := semver.ParseRangeWithOptions(versionRange, semver.Options{IncludePrerelease: true}); err == nil {
+				if candidate := pre.MaxSatisfying(versions); candidate != "" {
+					return "", fmt.Errorf("no version found for %s matching %s (prerelease %s matches; use --include-prerelease or name the prerelease in the range)", name, versionRange, candidate)
+				}
+			}
+		}
 		return "", fmt.Errorf("no version found for %s matching %s", name, versionRange)
 	}
 
 	log.Debug("Version resolved", map[string]interface{}{
-		"package": name,
-		"range":   versionRange,
-		"version": latest.Original(),
+		"package":    name,
+		"range":      versionRange,
+		"normalized": constraint.String(),
+		"version":    latest,
 	})
-	return latest.Original(), nil
-}
\ No newline at end of file
+	return latest, nil
+}
@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"ipm/pkg/log"
+)
+
+func TestMain(m *testing.M) {
+	// Wie ipm ohne --log-level: keine Logs
+	if err := log.Init("", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestResolveVersionDistTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"versions": {"1.0.0": {}, "1.1.0": {}, "2.0.0-beta.1": {}},
+			"dist-tags": {"latest": "1.0.0", "beta": "2.0.0-beta.1", "next": "3.0.0"}
+		}`))
+	}))
+	defer server.Close()
+	r := NewNPMRegistry(server.URL, "")
+
+	tests := []struct {
+		versionRange string
+		want         string
+		error        string
+	}{
+		{versionRange: "latest", want: "1.0.0"},
+		{versionRange: "beta", want: "2.0.0-beta.1"},
+		{versionRange: "*", want: "1.0.0"},
+		{versionRange: "^1.0.0", want: "1.0.0"},
+		{versionRange: "next", error: "dist-tag next of demo points to unpublished version 3.0.0"},
+	}
+	for _, tt := range tests {
+		got, err := r.ResolveVersion("demo", tt.versionRange)
+		if tt.error != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("ResolveVersion(%s) = %q, %v, want error %q", tt.versionRange, got, err, tt.error)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveVersion(%s) = %q, %v, want %s", tt.versionRange, got, err, tt.want)
+		}
+	}
+}
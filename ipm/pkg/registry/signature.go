@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"ipm/pkg/log"
+	"ipm/pkg/types"
+)
+
+// SignatureFetcher lädt abgelöste Signaturen (<tarball>.sig) neben dem Tarball.
+type SignatureFetcher interface {
+	FetchSignature(pkg types.Package) ([]byte, error)
+}
+
+// FetchSignature lädt die abgelöste Signatur eines Pakets von <dist.tarball>.sig.
+// Gibt es keine, ist das Ergebnis nil.
+func (r *NPMRegistry) FetchSignature(pkg types.Package) ([]byte, error) {
+	if pkg.Tarball == "" {
+		return nil, nil
+	}
+	req, err := r.newRequest("GET", pkg.Tarball+".sig", nil)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Fetching detached signature", map[string]interface{}{
+		"url": req.URL.String(),
+	})
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signature for %s@%s: %v", pkg.Name, pkg.Version, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signature request failed with status: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %v", err)
+	}
+	return data, nil
+}
+
+// AttestationFetcher lädt die Signaturschlüssel einer npm-Registry und die
+// Attestierungs-Bundles ihrer Pakete.
+type AttestationFetcher interface {
+	FetchKeys() ([]byte, error)
+	FetchAttestations(pkg types.Package) ([]byte, error)
+}
+
+// FetchKeys lädt /-/npm/v1/keys, die Schlüssel hinter dist.signatures.
+func (r *NPMRegistry) FetchKeys() ([]byte, error) {
+	req, err := r.newRequest("GET", r.BaseURL+"/-/npm/v1/keys", nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.do(req)
+}
+
+// FetchAttestations lädt das Bundle unter dist.attestations.url.
+func (r *NPMRegistry) FetchAttestations(pkg types.Package) ([]byte, error) {
+	if pkg.Attestations == nil || pkg.Attestations.URL == "" {
+		return nil, fmt.Errorf("%s@%s has no attestations", pkg.Name, pkg.Version)
+	}
+	req, err := r.newRequest("GET", pkg.Attestations.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.do(req)
+}
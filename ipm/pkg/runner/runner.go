@@ -0,0 +1,255 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"ipm/pkg/log"
+	"ipm/pkg/project"
+)
+
+// Task ist ein Skript eines Workspace-Mitglieds.
+type Task struct {
+	Member project.Member
+	Script string   // Befehl aus "scripts"
+	After  []string // Mitglieder, deren Task vorher abgeschlossen sein muss
+}
+
+func (t *Task) Name() string {
+	return t.Member.Manifest.Name
+}
+
+// Plan bildet für die gewählten Mitglieder, die das Skript script definieren, die
+// Tasks in topologischer Reihenfolge. Ein Task wartet auf alle gewählten Mitglieder,
+// von denen er direkt oder über nicht gewählte Mitglieder abhängt.
+func Plan(ws *project.Workspace, selected []project.Member, script string) ([]*Task, error) {
+	byName := make(map[string]project.Member, len(ws.Members))
+	for _, member := range ws.Members {
+		byName[member.Manifest.Name] = member
+	}
+	if cycle := findCycle(ws, byName); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle between workspace members: %s", strings.Join(cycle, " -> "))
+	}
+
+	tasks := make(map[string]*Task)
+	for _, member := range selected {
+		cmd, ok := member.Manifest.Scripts[script]
+		if !ok {
+			log.Debug("Workspace member has no such script, skipping", map[string]interface{}{
+				"member": member.Manifest.Name,
+				"script": script,
+			})
+			continue
+		}
+		tasks[member.Manifest.Name] = &Task{Member: member, Script: cmd}
+	}
+
+	for name, task := range tasks {
+		reached := make(map[string]bool)
+		var visit func(string)
+		visit = func(current string) {
+			for _, dep := range memberDeps(ws, byName[current]) {
+				if reached[dep] {
+					continue
+				}
+				reached[dep] = true
+				visit(dep)
+			}
+		}
+		visit(name)
+		for dep := range reached {
+			if _, ok := tasks[dep]; ok {
+				task.After = append(task.After, dep)
+			}
+		}
+		sort.Strings(task.After)
+	}
+	return order(tasks), nil
+}
+
+// order sortiert die Tasks topologisch, bei Gleichstand nach Name.
+func order(tasks map[string]*Task) []*Task {
+	done := make(map[string]bool)
+	var result []*Task
+	for len(result) < len(tasks) {
+		var ready []string
+		for name, task := range tasks {
+			if done[name] {
+				continue
+			}
+			if allDone(task.After, done) {
+				ready = append(ready, name)
+			}
+		}
+		sort.Strings(ready)
+		for _, name := range ready {
+			done[name] = true
+			result = append(result, tasks[name])
+		}
+	}
+	return result
+}
+
+func allDone(names []string, done map[string]bool) bool {
+	for _, name := range names {
+		if !done[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// findCycle liefert einen Zyklus im Abhängigkeitsgraphen der Mitglieder oder nil.
+func findCycle(ws *project.Workspace, byName map[string]project.Member) []string {
+	const (
+		visiting = 1
+		finished = 2
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range memberDeps(ws, byName[name]) {
+			switch state[dep] {
+			case visiting:
+				for idx, entry := range stack {
+					if entry == dep {
+						return append(append([]string{}, stack[idx:]...), dep)
+					}
+				}
+			case 0:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = finished
+		return nil
+	}
+	for _, member := range ws.Members {
+		if state[member.Manifest.Name] == 0 {
+			if cycle := visit(member.Manifest.Name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Run führt die Tasks aus, sobald ihre Abhängigkeiten abgeschlossen sind, mit
+// höchstens concurrency gleichzeitig. Die Ausgabe jedes Tasks wird zeilenweise mit
+// dem Namen des Mitglieds versehen. Nach einem Fehler starten keine neuen Tasks mehr.
+func Run(tasks []*Task, concurrency int, stdout, stderr io.Writer, run func(task *Task, stdout, stderr io.Writer) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	width := 0
+	for _, task := range tasks {
+		if len(task.Name()) > width {
+			width = len(task.Name())
+		}
+	}
+
+	type result struct {
+		task *Task
+		err  error
+	}
+	results := make(chan result)
+	var mu sync.Mutex
+	started := make(map[string]bool)
+	done := make(map[string]bool)
+	var failed []string
+	running := 0
+
+	for {
+		if len(failed) == 0 {
+			for _, task := range tasks {
+				if running >= concurrency {
+					break
+				}
+				if started[task.Name()] || !allDone(task.After, done) {
+					continue
+				}
+				started[task.Name()] = true
+				running++
+				prefix := fmt.Sprintf("%-*s | ", width, task.Name())
+				out := &prefixWriter{mu: &mu, out: stdout, prefix: prefix}
+				errOut := &prefixWriter{mu: &mu, out: stderr, prefix: prefix}
+				go func(task *Task) {
+					err := run(task, out, errOut)
+					out.Flush()
+					errOut.Flush()
+					results <- result{task: task, err: err}
+				}(task)
+			}
+		}
+		if running == 0 {
+			break
+		}
+		r := <-results
+		running--
+		if r.err != nil {
+			log.Error("Workspace task failed", r.err, map[string]interface{}{
+				"member": r.task.Name(),
+			})
+			failed = append(failed, fmt.Sprintf("%s (%v)", r.task.Name(), r.err))
+			continue
+		}
+		done[r.task.Name()] = true
+	}
+
+	if len(failed) > 0 {
+		var skipped []string
+		for _, task := range tasks {
+			if !started[task.Name()] {
+				skipped = append(skipped, task.Name())
+			}
+		}
+		if len(skipped) > 0 {
+			return fmt.Errorf("failed: %s; not started: %s", strings.Join(failed, ", "), strings.Join(skipped, ", "))
+		}
+		return fmt.Errorf("failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// prefixWriter schreibt vollständige Zeilen mit Präfix; mu schützt die gemeinsame Ausgabe.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := strings.IndexByte(string(w.buf), '\n')
+		if idx < 0 {
+			break
+		}
+		w.writeLine(w.buf[:idx+1])
+		w.buf = w.buf[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush schreibt eine unvollständige letzte Zeile.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+}
@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ipm/pkg/project"
+	"ipm/pkg/shell"
+)
+
+// Exec führt ein Skript in dir mit dem eingebauten Shell-Interpreter aus. So
+// verhalten sich "&&", Quoting, Zuweisungen und Globbing unter bash, cmd und
+// PowerShell gleich.
+func Exec(dir, script string, env []string, stdout, stderr io.Writer) error {
+	sh := shell.New(dir, env)
+	sh.Stdout = stdout
+	sh.Stderr = stderr
+	return sh.Run(script)
+}
+
+// Env liefert die Umgebung für ein Skript wie bei npm: npm_lifecycle_event und
+// -script, die Felder der package.json als npm_package_* (verschachtelte Objekte
+// mit "_" verbunden, z. B. npm_package_config_port), die Konfiguration als
+// npm_config_* und node_modules/.bin des Projekts und der Workspace-Wurzel vorn im PATH.
+func Env(manifest *project.Manifest, dir, rootDir, event, script string, config map[string]string) []string {
+	var bins []string
+	for _, d := range []string{dir, rootDir} {
+		if abs, err := filepath.Abs(filepath.Join(d, "node_modules", ".bin")); err == nil && !contains(bins, abs) {
+			bins = append(bins, abs)
+		}
+	}
+
+	vars := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(dir, project.ManifestFile)); err == nil {
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err == nil {
+			flatten(vars, "npm_package", raw)
+		}
+	}
+	vars["npm_package_name"] = manifest.Name
+	vars["npm_package_version"] = manifest.Version
+	for key, value := range config {
+		vars["npm_config_"+envName(key)] = value
+	}
+	vars["npm_lifecycle_event"] = event
+	vars["npm_lifecycle_script"] = script
+	if abs, err := filepath.Abs(filepath.Join(dir, project.ManifestFile)); err == nil {
+		vars["npm_package_json"] = abs
+	}
+	if exe, err := os.Executable(); err == nil {
+		vars["npm_execpath"] = exe
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		vars["INIT_CWD"] = cwd
+	}
+
+	var env []string
+	pathSet := false
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		if _, ok := vars[key]; ok || strings.HasPrefix(key, "npm_package_") || strings.HasPrefix(key, "npm_lifecycle_") {
+			continue // Werte eines äußeren Skripts nicht vererben
+		}
+		if strings.EqualFold(key, "PATH") {
+			entry = key + "=" + strings.Join(append(bins, value), string(os.PathListSeparator))
+			pathSet = true
+		}
+		env = append(env, entry)
+	}
+	if !pathSet {
+		env = append(env, "PATH="+strings.Join(bins, string(os.PathListSeparator)))
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+	return env
+}
+
+// flatten legt die Werte eines JSON-Objekts als Variablen mit Präfix ab. Arrays
+// werden nach Index nummeriert, null-Werte ausgelassen.
+func flatten(vars map[string]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flatten(vars, prefix+"_"+envName(key), child)
+		}
+	case []interface{}:
+		for idx, child := range v {
+			flatten(vars, prefix+"_"+strconv.Itoa(idx), child)
+		}
+	case string:
+		vars[prefix] = v
+	case bool:
+		vars[prefix] = strconv.FormatBool(v)
+	case float64:
+		vars[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+// envName macht aus einem Schlüssel einen gültigen Variablennamen ("dry-run" -> "dry_run").
+func envName(key string) string {
+	return strings.Map(func(c rune) rune {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			return c
+		}
+		return '_'
+	}, key)
+}
+
+// QuoteArgs hängt zusätzliche Argumente ("ipm run build -- --prod") an ein Skript an.
+func QuoteArgs(script string, args []string) string {
+	for _, arg := range args {
+		script += " " + quote(arg)
+	}
+	return script
+}
+
+func quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`&|;<>()*?[]{}~!#") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func contains(list []string, value string) bool {
+	for _, entry := range list {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"ipm/pkg/project"
+)
+
+// Select wählt die Mitglieder, auf die mindestens ein Filter passt. Ein Filter ist
+// ein Name, ein Glob auf den Namen (z. B. "@plc/*") oder ein Pfad relativ zur
+// Wurzel ("./packages/a", "packages/*"). Ohne Filter sind alle Mitglieder gewählt.
+func Select(ws *project.Workspace, filters []string) ([]project.Member, error) {
+	if len(filters) == 0 {
+		return ws.Members, nil
+	}
+	selected := make(map[string]bool)
+	for _, filter := range filters {
+		matched := false
+		for _, member := range ws.Members {
+			ok, err := matchFilter(filter, member)
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter %q: %v", filter, err)
+			}
+			if ok {
+				selected[member.Manifest.Name] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no workspace member matches filter %q", filter)
+		}
+	}
+	var result []project.Member
+	for _, member := range ws.Members {
+		if selected[member.Manifest.Name] {
+			result = append(result, member)
+		}
+	}
+	return result, nil
+}
+
+func matchFilter(filter string, member project.Member) (bool, error) {
+	if isPathFilter(filter) {
+		return path.Match(path.Clean(strings.TrimPrefix(filter, "./")), member.Dir)
+	}
+	return path.Match(filter, member.Manifest.Name)
+}
+
+// isPathFilter unterscheidet Pfade von (Scoped-)Namen wie "@plc/compiler".
+func isPathFilter(filter string) bool {
+	if strings.HasPrefix(filter, ".") {
+		return true
+	}
+	return strings.Contains(filter, "/") && !strings.HasPrefix(filter, "@")
+}
+
+// ChangedSince liefert die Mitglieder, in deren Verzeichnis sich seit ref etwas
+// geändert hat (Commits, Arbeitskopie, neue Dateien), sowie alle Mitglieder, die
+// direkt oder indirekt von ihnen abhängen.
+func ChangedSince(ws *project.Workspace, ref string) ([]project.Member, error) {
+	diff, err := git(ws.Dir, "diff", "--name-only", "--relative", ref)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(ws.Dir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(diff+"\n"+untracked, "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+
+	changed := make(map[string]bool)
+	for _, member := range ws.Members {
+		for _, file := range files {
+			if file == member.Dir || strings.HasPrefix(file, member.Dir+"/") {
+				changed[member.Manifest.Name] = true
+				break
+			}
+		}
+	}
+
+	// Abhängige Mitglieder gelten ebenfalls als geändert
+	for {
+		added := false
+		for _, member := range ws.Members {
+			if changed[member.Manifest.Name] {
+				continue
+			}
+			for _, dep := range memberDeps(ws, member) {
+				if changed[dep] {
+					changed[member.Manifest.Name] = true
+					added = true
+					break
+				}
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	var result []project.Member
+	for _, member := range ws.Members {
+		if changed[member.Manifest.Name] {
+			result = append(result, member)
+		}
+	}
+	return result, nil
+}
+
+// memberDeps liefert die Mitglieder, von denen member direkt abhängt, sortiert.
+func memberDeps(ws *project.Workspace, member project.Member) []string {
+	names := make(map[string]bool, len(ws.Members))
+	for _, m := range ws.Members {
+		names[m.Manifest.Name] = true
+	}
+	m := member.Manifest
+	seen := make(map[string]bool)
+	var deps []string
+	for _, group := range []map[string]string{m.Dependencies, m.DevDependencies, m.OptionalDependencies, m.PeerDependencies} {
+		for name := range group {
+			if names[name] && name != m.Name && !seen[name] {
+				seen[name] = true
+				deps = append(deps, name)
+			}
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
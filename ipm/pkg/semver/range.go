@@ -0,0 +1,423 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Teilversion mit Platzhaltern (x, X, *) oder fehlenden Stellen, z. B. "1.2" oder "1.x"
+const xRangePlain = `[v=\s]*(\d+|[xX*])(?:\.(\d+|[xX*])(?:\.(\d+|[xX*])` + prerelease + `?` + build + `?)?)?`
+
+var (
+	orSplitRe   = regexp.MustCompile(`\s*\|\|\s*`)
+	hyphenRe    = regexp.MustCompile(`^\s*(` + xRangePlain + `)\s+-\s+(` + xRangePlain + `)\s*$`)
+	opTrimRe    = regexp.MustCompile(`(<=|>=|<|>|=|~>|~|\^)\s+`)
+	caretRe     = regexp.MustCompile(`^\^` + xRangePlain + `$`)
+	tildeRe     = regexp.MustCompile(`^~>?` + xRangePlain + `$`)
+	xRangeRe    = regexp.MustCompile(`^(<=|>=|<|>|=)?` + xRangePlain + `$`)
+	nullVersion = &Version{Prerelease: []string{"0"}}
+)
+
+type comparator struct {
+	op  string
+	ver *Version // nil passt auf jede Version
+}
+
+func (c comparator) test(v *Version) bool {
+	if c.ver == nil {
+		return true
+	}
+	cmp := v.Compare(c.ver)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+func (c comparator) String() string {
+	if c.ver == nil {
+		return "*"
+	}
+	if c.op == "=" {
+		return c.ver.String()
+	}
+	return c.op + c.ver.String()
+}
+
+// Options entsprechen den gleichnamigen Optionen von node-semver.
+type Options struct {
+	// IncludePrerelease lässt Prereleases auf jeden passenden Range zutreffen,
+	// nicht nur auf Ranges, die dasselbe major.minor.patch-Tupel nennen.
+	IncludePrerelease bool
+}
+
+// Range ist ein npm-Versionsbereich: ODER-verknüpfte Mengen von UND-verknüpften Vergleichen.
+type Range struct {
+	raw  string
+	sets [][]comparator
+	opts Options
+}
+
+// ParseRange liest einen Versionsbereich nach den Regeln von node-semver
+// (Hyphen-Ranges, x/*-Platzhalter, ~, ^, || und lose Versionen).
+func ParseRange(s string) (*Range, error) {
+	return ParseRangeWithOptions(s, Options{})
+}
+
+// ParseRangeWithOptions arbeitet wie ParseRange, berücksichtigt aber opts.
+func ParseRangeWithOptions(s string, opts Options) (*Range, error) {
+	r := &Range{raw: s, opts: opts}
+	for _, part := range orSplitRe.Split(strings.TrimSpace(s), -1) {
+		set, err := parseSet(part, opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version range %q: %v", s, err)
+		}
+		r.sets = append(r.sets, set)
+	}
+	return r, nil
+}
+
+// ValidRange meldet, ob s ein gültiger Versionsbereich ist.
+func ValidRange(s string) bool {
+	_, err := ParseRange(s)
+	return err == nil
+}
+
+func parseSet(part string, opts Options) ([]comparator, error) {
+	var comps []comparator
+	if m := hyphenRe.FindStringSubmatch(part); m != nil {
+		from, err := hyphenFrom(newPartial(m[2:7]), opts)
+		if err != nil {
+			return nil, err
+		}
+		to, err := hyphenTo(newPartial(m[8:13]), opts)
+		if err != nil {
+			return nil, err
+		}
+		comps = append(from, to...)
+	} else {
+		for _, tok := range strings.Fields(opTrimRe.ReplaceAllString(part, "$1")) {
+			c, err := parseComparator(tok, opts)
+			if err != nil {
+				return nil, err
+			}
+			comps = append(comps, c...)
+		}
+	}
+
+	// "Beliebig"-Vergleiche sind neben konkreten Vergleichen überflüssig
+	var set []comparator
+	for _, c := range comps {
+		if c.ver != nil {
+			set = append(set, c)
+		}
+	}
+	if len(set) == 0 {
+		set = []comparator{{}}
+	}
+	return set, nil
+}
+
+func parseComparator(tok string, opts Options) ([]comparator, error) {
+	if m := caretRe.FindStringSubmatch(tok); m != nil {
+		return caret(newPartial(m[1:6]), opts)
+	}
+	if m := tildeRe.FindStringSubmatch(tok); m != nil {
+		return tilde(newPartial(m[1:6]))
+	}
+	if m := xRangeRe.FindStringSubmatch(tok); m != nil {
+		return xRange(m[1], newPartial(m[2:7]), opts)
+	}
+	return nil, fmt.Errorf("invalid comparator %q", tok)
+}
+
+type partial struct {
+	major, minor, patch string
+	pre, build          string
+}
+
+func newPartial(m []string) partial {
+	return partial{major: m[0], minor: m[1], patch: m[2], pre: m[3], build: m[4]}
+}
+
+func isX(s string) bool {
+	return s == "" || s == "x" || s == "X" || s == "*"
+}
+
+func (p partial) xMajor() bool { return isX(p.major) }
+func (p partial) xMinor() bool { return p.xMajor() || isX(p.minor) }
+func (p partial) xPatch() bool { return p.xMinor() || isX(p.patch) }
+
+// numbers liefert die Stellen der Teilversion; Platzhalter zählen als 0.
+func (p partial) numbers() (major, minor, patch uint64, err error) {
+	parse := func(s string) (uint64, error) {
+		if isX(s) {
+			return 0, nil
+		}
+		return strconv.ParseUint(s, 10, 64)
+	}
+	if major, err = parse(p.major); err != nil {
+		return
+	}
+	if minor, err = parse(p.minor); err != nil {
+		return
+	}
+	patch, err = parse(p.patch)
+	return
+}
+
+func (p partial) prerelease() []string {
+	if p.pre == "" {
+		return nil
+	}
+	return strings.Split(p.pre, ".")
+}
+
+func ver(major, minor, patch uint64, pre ...string) *Version {
+	return &Version{Major: major, Minor: minor, Patch: patch, Prerelease: pre}
+}
+
+// lowest liefert die Prerelease-Kennung für Untergrenzen: mit IncludePrerelease
+// beginnt ein Bereich bei der kleinsten Prerelease ("-0") statt beim Release.
+func lowest(opts Options) []string {
+	if opts.IncludePrerelease {
+		return []string{"0"}
+	}
+	return nil
+}
+
+// bound liefert >=lower <upper; upper erhält "-0", damit keine Prereleases der Obergrenze passen.
+func bound(lower, upper *Version) []comparator {
+	return []comparator{{op: ">=", ver: lower}, {op: "<", ver: upper}}
+}
+
+// ~1.2.3 := >=1.2.3 <1.3.0-0, ~1.2 := >=1.2.0 <1.3.0-0, ~1 := >=1.0.0 <2.0.0-0
+func tilde(p partial) ([]comparator, error) {
+	M, m, pa, err := p.numbers()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.xMajor():
+		return []comparator{{}}, nil
+	case p.xMinor():
+		return bound(ver(M, 0, 0), ver(M+1, 0, 0, "0")), nil
+	case p.xPatch():
+		return bound(ver(M, m, 0), ver(M, m+1, 0, "0")), nil
+	}
+	return bound(ver(M, m, pa, p.prerelease()...), ver(M, m+1, 0, "0")), nil
+}
+
+// ^1.2.3 := >=1.2.3 <2.0.0-0, ^0.2.3 := >=0.2.3 <0.3.0-0, ^0.0.3 := >=0.0.3 <0.0.4-0;
+// mit IncludePrerelease beginnen ^0.x-Bereiche und x-Bereiche bei "-0"
+func caret(p partial, opts Options) ([]comparator, error) {
+	M, m, pa, err := p.numbers()
+	if err != nil {
+		return nil, err
+	}
+	z := lowest(opts)
+	switch {
+	case p.xMajor():
+		return []comparator{{}}, nil
+	case p.xMinor():
+		return bound(ver(M, 0, 0, z...), ver(M+1, 0, 0, "0")), nil
+	case p.xPatch():
+		if M == 0 {
+			return bound(ver(M, m, 0, z...), ver(M, m+1, 0, "0")), nil
+		}
+		return bound(ver(M, m, 0, z...), ver(M+1, 0, 0, "0")), nil
+	}
+	lower := ver(M, m, pa, p.prerelease()...)
+	if M == 0 && lower.Prerelease == nil {
+		// Wie node-semver beginnen Bereiche unter 1.0.0 mit IncludePrerelease bei "-0"
+		lower.Prerelease = z
+	}
+	switch {
+	case M == 0 && m == 0:
+		return bound(lower, ver(M, m, pa+1, "0")), nil
+	case M == 0:
+		return bound(lower, ver(M, m+1, 0, "0")), nil
+	}
+	return bound(lower, ver(M+1, 0, 0, "0")), nil
+}
+
+// 1.2 := >=1.2.0 <1.3.0-0, >1.2 := >=1.3.0, <=1.2 := <1.3.0-0, * := beliebig
+func xRange(op string, p partial, opts Options) ([]comparator, error) {
+	M, m, pa, err := p.numbers()
+	if err != nil {
+		return nil, err
+	}
+	if !p.xPatch() {
+		if op == "" {
+			op = "="
+		}
+		return []comparator{{op: op, ver: ver(M, m, pa, p.prerelease()...)}}, nil
+	}
+	if op == "=" {
+		op = ""
+	}
+
+	switch {
+	case p.xMajor():
+		if op == ">" || op == "<" {
+			// Nichts kann größer oder kleiner als "beliebig" sein
+			return []comparator{{op: "<", ver: nullVersion}}, nil
+		}
+		return []comparator{{}}, nil
+	case op != "":
+		if p.xMinor() {
+			m = 0
+		}
+		pa = 0
+		pre := lowest(opts)
+		switch op {
+		case ">":
+			op = ">="
+			if p.xMinor() {
+				M, m = M+1, 0
+			} else {
+				m++
+			}
+		case "<=":
+			op = "<"
+			if p.xMinor() {
+				M++
+			} else {
+				m++
+			}
+		}
+		if op == "<" {
+			pre = []string{"0"}
+		}
+		return []comparator{{op: op, ver: ver(M, m, pa, pre...)}}, nil
+	case p.xMinor():
+		return bound(ver(M, 0, 0, lowest(opts)...), ver(M+1, 0, 0, "0")), nil
+	}
+	return bound(ver(M, m, 0, lowest(opts)...), ver(M, m+1, 0, "0")), nil
+}
+
+// 1.2 - 2.3.4 := >=1.2.0 <=2.3.4, 1.2.3 - 2.3 := >=1.2.3 <2.4.0-0
+func hyphenFrom(p partial, opts Options) ([]comparator, error) {
+	M, m, pa, err := p.numbers()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.xMajor():
+		return nil, nil
+	case p.xMinor():
+		return []comparator{{op: ">=", ver: ver(M, 0, 0, lowest(opts)...)}}, nil
+	case p.xPatch():
+		return []comparator{{op: ">=", ver: ver(M, m, 0, lowest(opts)...)}}, nil
+	case p.pre != "":
+		return []comparator{{op: ">=", ver: ver(M, m, pa, p.prerelease()...)}}, nil
+	}
+	return []comparator{{op: ">=", ver: ver(M, m, pa, lowest(opts)...)}}, nil
+}
+
+func hyphenTo(p partial, opts Options) ([]comparator, error) {
+	M, m, pa, err := p.numbers()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.xMajor():
+		return nil, nil
+	case p.xMinor():
+		return []comparator{{op: "<", ver: ver(M+1, 0, 0, "0")}}, nil
+	case p.xPatch():
+		return []comparator{{op: "<", ver: ver(M, m+1, 0, "0")}}, nil
+	case p.pre == "" && opts.IncludePrerelease:
+		return []comparator{{op: "<", ver: ver(M, m, pa+1, "0")}}, nil
+	}
+	return []comparator{{op: "<=", ver: ver(M, m, pa, p.prerelease()...)}}, nil
+}
+
+// Test meldet, ob v im Bereich liegt. Prereleases passen ohne IncludePrerelease nur,
+// wenn ein Vergleich des erfüllten Teilbereichs dasselbe major.minor.patch-Tupel
+// mit Prerelease nennt: ^1.2.3-beta.1 erlaubt 1.2.3-beta.2, aber nicht 1.3.0-rc.1.
+func (r *Range) Test(v *Version) bool {
+	for _, set := range r.sets {
+		if testSet(set, v, r.opts) {
+			return true
+		}
+	}
+	return false
+}
+
+func testSet(set []comparator, v *Version, opts Options) bool {
+	for _, c := range set {
+		if !c.test(v) {
+			return false
+		}
+	}
+	if len(v.Prerelease) == 0 || opts.IncludePrerelease {
+		return true
+	}
+	for _, c := range set {
+		if c.ver == nil || len(c.ver.Prerelease) == 0 {
+			continue
+		}
+		if c.ver.Major == v.Major && c.ver.Minor == v.Minor && c.ver.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// String liefert die normalisierte Form, z. B. ">=1.2.3 <2.0.0-0".
+func (r *Range) String() string {
+	sets := make([]string, len(r.sets))
+	for i, set := range r.sets {
+		comps := make([]string, len(set))
+		for j, c := range set {
+			comps[j] = c.String()
+		}
+		sets[i] = strings.Join(comps, " ")
+	}
+	return strings.Join(sets, " || ")
+}
+
+// Satisfies meldet, ob version im Bereich rng liegt. Ungültige Eingaben passen nie.
+func Satisfies(version, rng string) bool {
+	return SatisfiesWithOptions(version, rng, Options{})
+}
+
+// SatisfiesWithOptions arbeitet wie Satisfies, berücksichtigt aber opts.
+func SatisfiesWithOptions(version, rng string, opts Options) bool {
+	v, err := Parse(version)
+	if err != nil {
+		return false
+	}
+	r, err := ParseRangeWithOptions(rng, opts)
+	if err != nil {
+		return false
+	}
+	return r.Test(v)
+}
+
+// MaxSatisfying liefert die höchste Version aus versions, die im Bereich liegt,
+// in ihrer ursprünglichen Schreibweise, oder "" wenn keine passt.
+func (r *Range) MaxSatisfying(versions []string) string {
+	var best *Version
+	var bestRaw string
+	for _, raw := range versions {
+		v, err := Parse(raw)
+		if err != nil || !r.Test(v) {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best, bestRaw = v, raw
+		}
+	}
+	return bestRaw
+}
@@ -0,0 +1,126 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	identLoose = `[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*`
+	prerelease = `(?:-?(` + identLoose + `))`
+	build      = `(?:\+(` + identLoose + `))`
+)
+
+// Lose Versionssyntax wie bei node-semver: "v1.2.3", "=1.2.3", "1.2.3beta"
+var versionRe = regexp.MustCompile(`^[v=\s]*(\d+)\.(\d+)\.(\d+)` + prerelease + `?` + build + `?$`)
+
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      []string
+}
+
+// Parse liest eine Version in der losen npm-Syntax.
+func Parse(s string) (*Version, error) {
+	m := versionRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, fmt.Errorf("invalid version: %q", s)
+	}
+	v := &Version{}
+	var err error
+	if v.Major, err = strconv.ParseUint(m[1], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid major version in %q: %v", s, err)
+	}
+	if v.Minor, err = strconv.ParseUint(m[2], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid minor version in %q: %v", s, err)
+	}
+	if v.Patch, err = strconv.ParseUint(m[3], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid patch version in %q: %v", s, err)
+	}
+	if m[4] != "" {
+		v.Prerelease = strings.Split(m[4], ".")
+	}
+	if m[5] != "" {
+		v.Build = strings.Split(m[5], ".")
+	}
+	return v, nil
+}
+
+// Valid meldet, ob s eine konkrete Version (kein Range, kein dist-tag) ist.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// IsPrerelease meldet, ob v eine Prerelease wie 1.2.0-rc.1 ist.
+func (v *Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	return s
+}
+
+// Compare liefert -1, 0 oder 1. Build-Metadaten werden wie bei npm ignoriert.
+func (v *Version) Compare(o *Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func comparePrerelease(a, b []string) int {
+	// Eine Version ohne Prerelease ist größer als jede Prerelease derselben Version
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(a)), uint64(len(b)))
+}
+
+func compareIdentifier(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareUint(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
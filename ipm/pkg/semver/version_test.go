@@ -0,0 +1,153 @@
+package semver
+
+import "testing"
+
+// Testvektoren aus node-semver (test/fixtures/comparisons.js, equality.js,
+// invalid-versions.js). ipm liest Versionen immer lose.
+
+func TestCompare(t *testing.T) {
+	// v0 > v1
+	tests := []struct{ v0, v1 string }{
+		{"0.0.0", "0.0.0-foo"},
+		{"0.0.1", "0.0.0"},
+		{"1.0.0", "0.9.9"},
+		{"0.10.0", "0.9.0"},
+		{"0.99.0", "0.10.0"},
+		{"2.0.0", "1.2.3"},
+		{"v0.0.0", "0.0.0-foo"},
+		{"v0.0.1", "0.0.0"},
+		{"v1.0.0", "0.9.9"},
+		{"v0.10.0", "0.9.0"},
+		{"v0.99.0", "0.10.0"},
+		{"v2.0.0", "1.2.3"},
+		{"0.0.0", "v0.0.0-foo"},
+		{"0.0.1", "v0.0.0"},
+		{"1.0.0", "v0.9.9"},
+		{"0.10.0", "v0.9.0"},
+		{"0.99.0", "v0.10.0"},
+		{"2.0.0", "v1.2.3"},
+		{"1.2.3", "1.2.3-asdf"},
+		{"1.2.3", "1.2.3-4"},
+		{"1.2.3", "1.2.3-4-foo"},
+		{"1.2.3-5-foo", "1.2.3-5"},
+		{"1.2.3-5", "1.2.3-4"},
+		{"1.2.3-5-foo", "1.2.3-5-Foo"},
+		{"3.0.0", "2.7.2+asdf"},
+		{"1.2.3-a.10", "1.2.3-a.5"},
+		{"1.2.3-a.b", "1.2.3-a.5"},
+		{"1.2.3-a.b", "1.2.3-a"},
+		{"1.2.3-a.b.c.10.d.5", "1.2.3-a.b.c.5.d.100"},
+		{"1.2.3-r2", "1.2.3-r100"},
+		{"1.2.3-r100", "1.2.3-R2"},
+	}
+	for _, tt := range tests {
+		v0, err := Parse(tt.v0)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.v0, err)
+		}
+		v1, err := Parse(tt.v1)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.v1, err)
+		}
+		if got := v0.Compare(v1); got != 1 {
+			t.Errorf("Compare(%q, %q) = %d, want 1", tt.v0, tt.v1, got)
+		}
+		if got := v1.Compare(v0); got != -1 {
+			t.Errorf("Compare(%q, %q) = %d, want -1", tt.v1, tt.v0, got)
+		}
+		if got := v0.Compare(v0); got != 0 {
+			t.Errorf("Compare(%q, %q) = %d, want 0", tt.v0, tt.v0, got)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct{ v0, v1 string }{
+		{"1.2.3", "v1.2.3"},
+		{"1.2.3", "=1.2.3"},
+		{"1.2.3", "v 1.2.3"},
+		{"1.2.3", "= 1.2.3"},
+		{"1.2.3", " v1.2.3"},
+		{"1.2.3", " =1.2.3"},
+		{"1.2.3", " v 1.2.3"},
+		{"1.2.3", " = 1.2.3"},
+		{"1.2.3-0", "v1.2.3-0"},
+		{"1.2.3-0", "=1.2.3-0"},
+		{"1.2.3-0", "v 1.2.3-0"},
+		{"1.2.3-0", "= 1.2.3-0"},
+		{"1.2.3-0", " v1.2.3-0"},
+		{"1.2.3-0", " =1.2.3-0"},
+		{"1.2.3-0", " v 1.2.3-0"},
+		{"1.2.3-0", " = 1.2.3-0"},
+		{"1.2.3-1", "v1.2.3-1"},
+		{"1.2.3-1", "=1.2.3-1"},
+		{"1.2.3-1", "v 1.2.3-1"},
+		{"1.2.3-1", "= 1.2.3-1"},
+		{"1.2.3-beta", "v1.2.3-beta"},
+		{"1.2.3-beta", "=1.2.3-beta"},
+		{"1.2.3-beta", "v 1.2.3-beta"},
+		{"1.2.3-beta", "= 1.2.3-beta"},
+		{"1.2.3-beta+build", " = 1.2.3-beta+otherbuild"},
+		{"1.2.3+build", " = 1.2.3+otherbuild"},
+		{"1.2.3-beta+build", "1.2.3-beta+otherbuild"},
+		{"1.2.3+build", "1.2.3+otherbuild"},
+		{"  v1.2.3+build", "1.2.3+otherbuild"},
+	}
+	for _, tt := range tests {
+		v0, err := Parse(tt.v0)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.v0, err)
+		}
+		v1, err := Parse(tt.v1)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.v1, err)
+		}
+		if got := v0.Compare(v1); got != 0 {
+			t.Errorf("Compare(%q, %q) = %d, want 0", tt.v0, tt.v1, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{"=1.2.3", "1.2.3"},
+		{" 1.2.3 ", "1.2.3"},
+		{"1.2.3-alpha.1", "1.2.3-alpha.1"},
+		{"1.2.3beta", "1.2.3-beta"},
+		{"1.2.3+build.5", "1.2.3"}, // wie node-semver ohne Build-Metadaten
+		{"1.2.3-rc.1+build", "1.2.3-rc.1"},
+	}
+	for _, tt := range tests {
+		v, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := v.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidVersions(t *testing.T) {
+	for _, in := range []string{
+		"1.2.3.4",
+		"NOT VALID",
+		"1.2",
+		"",
+		"Infinity.NaN.Infinity",
+		"1.2.x",
+		"^1.2.3",
+		"latest",
+		"99999999999999999999.0.0",
+	} {
+		if Valid(in) {
+			t.Errorf("Valid(%q) = true, want false", in)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type builtin func(s *Shell, args []string, stdout, stderr io.Writer) (int, error)
+
+// builtins sind Befehle, die auf keinem System als Programm vorausgesetzt werden.
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"echo":   echo,
+		"cd":     cd,
+		"pwd":    pwd,
+		"exit":   exit,
+		"export": export,
+		"unset":  unset,
+		"true":   func(*Shell, []string, io.Writer, io.Writer) (int, error) { return 0, nil },
+		":":      func(*Shell, []string, io.Writer, io.Writer) (int, error) { return 0, nil },
+		"false":  func(*Shell, []string, io.Writer, io.Writer) (int, error) { return 1, nil },
+	}
+}
+
+func echo(s *Shell, args []string, stdout, stderr io.Writer) (int, error) {
+	newline := true
+	if len(args) > 0 && args[0] == "-n" {
+		newline = false
+		args = args[1:]
+	}
+	out := strings.Join(args, " ")
+	if newline {
+		out += "\n"
+	}
+	if _, err := io.WriteString(stdout, out); err != nil {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func cd(s *Shell, args []string, stdout, stderr io.Writer) (int, error) {
+	if len(args) > 1 {
+		fmt.Fprintln(stderr, "ipm: cd: too many arguments")
+		return 1, nil
+	}
+	target := ""
+	if len(args) == 1 {
+		target = args[0]
+	} else if home, err := os.UserHomeDir(); err == nil {
+		target = home
+	}
+	dir := s.path(target)
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		fmt.Fprintf(stderr, "ipm: cd: %s: no such directory\n", target)
+		return 1, nil
+	}
+	s.Dir = filepath.Clean(dir)
+	s.env[envKey("PWD")] = s.Dir
+	return 0, nil
+}
+
+func pwd(s *Shell, args []string, stdout, stderr io.Writer) (int, error) {
+	fmt.Fprintln(stdout, s.Dir)
+	return 0, nil
+}
+
+func exit(s *Shell, args []string, stdout, stderr io.Writer) (int, error) {
+	code := s.status
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "ipm: exit: %s: numeric argument required\n", args[0])
+			n = 2
+		}
+		code = n
+	}
+	return code, &exitRequest{code: code}
+}
+
+func export(s *Shell, args []string, stdout, stderr io.Writer) (int, error) {
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			continue // Variablen der Shell sind ohnehin exportiert
+		}
+		s.env[envKey(name)] = value
+	}
+	return 0, nil
+}
+
+func unset(s *Shell, args []string, stdout, stderr io.Writer) (int, error) {
+	for _, name := range args {
+		delete(s.env, envKey(name))
+	}
+	return 0, nil
+}
@@ -0,0 +1,362 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// part ist ein Stück eines Worts. Variablen werden erst bei der Ausführung
+// eingesetzt, damit "FOO=1 && echo $FOO" wie in sh funktioniert.
+type part struct {
+	text     string
+	quoted   bool // in '…' oder "…": kein Globbing
+	variable bool // text ist ein Variablenname
+}
+
+type word []part
+
+type assignment struct {
+	name  string
+	value word
+}
+
+type redirect struct {
+	op     string // ">", ">>", "<", "2>", "2>>", "2>&1", ">&2"
+	target word
+}
+
+type command struct {
+	assigns []assignment
+	args    []word
+	redirs  []redirect
+}
+
+type pipeline []*command
+
+// list ist eine Folge von Pipelines, verbunden durch "&&", "||" oder ";".
+type list struct {
+	pipelines []pipeline
+	ops       []string // ops[i] steht zwischen pipelines[i] und pipelines[i+1]
+}
+
+type token struct {
+	op   string // leer für Wörter
+	word word
+}
+
+// lex zerlegt ein Skript in Wörter und Operatoren.
+func lex(script string) ([]token, error) {
+	var tokens []token
+	runes := []rune(script)
+	for idx := 0; idx < len(runes); {
+		c := runes[idx]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			idx++
+		case c == '\n':
+			tokens = append(tokens, token{op: ";"})
+			idx++
+		case c == '#':
+			for idx < len(runes) && runes[idx] != '\n' {
+				idx++
+			}
+		case c == '&' || c == '|' || c == ';' || c == '<' || c == '>' || c == '(' || c == ')':
+			op, n, err := lexOperator(runes[idx:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{op: op})
+			idx += n
+		case (c == '1' || c == '2') && idx+1 < len(runes) && runes[idx+1] == '>' && (idx == 0 || isBlank(runes[idx-1])):
+			op, n, err := lexOperator(runes[idx+1:])
+			if err != nil {
+				return nil, err
+			}
+			// "1>" ist dasselbe wie ">"
+			if c == '2' {
+				op = "2" + op
+			}
+			tokens = append(tokens, token{op: op})
+			idx += 1 + n
+		default:
+			w, n, err := lexWord(runes[idx:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{word: w})
+			idx += n
+		}
+	}
+	return tokens, nil
+}
+
+func isBlank(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func lexOperator(runes []rune) (string, int, error) {
+	s := string(runes)
+	for _, op := range []string{"&&", "||", ">>", ">&1", ">&2", ";", "|", "<", ">"} {
+		if strings.HasPrefix(s, op) {
+			if op == ">" && strings.HasPrefix(s, ">&") {
+				return "", 0, fmt.Errorf("only redirections to the file descriptors 1 and 2 are supported")
+			}
+			return op, len([]rune(op)), nil
+		}
+	}
+	switch runes[0] {
+	case '&':
+		return "", 0, fmt.Errorf("background jobs (&) are not supported")
+	case '(', ')':
+		return "", 0, fmt.Errorf("subshells are not supported")
+	}
+	return "", 0, fmt.Errorf("unexpected %q", runes[0])
+}
+
+// lexWord liest ein Wort bis zum nächsten Leerzeichen oder Operator.
+func lexWord(runes []rune) (word, int, error) {
+	var w word
+	var literal strings.Builder
+	flush := func(quoted bool) {
+		if literal.Len() > 0 || quoted {
+			w = append(w, part{text: literal.String(), quoted: quoted})
+			literal.Reset()
+		}
+	}
+
+	idx := 0
+	for idx < len(runes) {
+		c := runes[idx]
+		switch {
+		case isBlank(c) || strings.ContainsRune("&|;<>()", c):
+			flush(false)
+			return w, idx, nil
+		case c == '\\':
+			if idx+1 < len(runes) && runes[idx+1] == '\n' {
+				idx += 2 // Zeilenfortsetzung
+				continue
+			}
+			if idx+1 < len(runes) {
+				flush(false)
+				w = append(w, part{text: string(runes[idx+1]), quoted: true})
+				idx += 2
+				continue
+			}
+			idx++
+		case c == '\'':
+			flush(false)
+			end := indexRune(runes[idx+1:], '\'')
+			if end < 0 {
+				return nil, 0, fmt.Errorf("unterminated single quote")
+			}
+			literal.WriteString(string(runes[idx+1 : idx+1+end]))
+			flush(true)
+			idx += end + 2
+		case c == '"':
+			flush(false)
+			parts, n, err := lexDoubleQuoted(runes[idx+1:])
+			if err != nil {
+				return nil, 0, err
+			}
+			w = append(w, parts...)
+			idx += n + 2
+		case c == '`':
+			return nil, 0, fmt.Errorf("command substitution is not supported")
+		case c == '$':
+			name, n, err := lexVariable(runes[idx+1:])
+			if err != nil {
+				return nil, 0, err
+			}
+			if n == 0 {
+				literal.WriteRune('$')
+				idx++
+				continue
+			}
+			flush(false)
+			w = append(w, part{text: name, variable: true})
+			idx += n + 1
+		default:
+			literal.WriteRune(c)
+			idx++
+		}
+	}
+	flush(false)
+	return w, idx, nil
+}
+
+// lexDoubleQuoted liest bis zum schließenden '"'; n zählt ohne die Anführungszeichen.
+func lexDoubleQuoted(runes []rune) (word, int, error) {
+	var w word
+	var literal strings.Builder
+	idx := 0
+	for idx < len(runes) {
+		c := runes[idx]
+		switch {
+		case c == '"':
+			if literal.Len() > 0 || len(w) == 0 {
+				w = append(w, part{text: literal.String(), quoted: true})
+			}
+			return w, idx, nil
+		case c == '\\' && idx+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[idx+1]):
+			literal.WriteRune(runes[idx+1])
+			idx += 2
+		case c == '`':
+			return nil, 0, fmt.Errorf("command substitution is not supported")
+		case c == '$':
+			name, n, err := lexVariable(runes[idx+1:])
+			if err != nil {
+				return nil, 0, err
+			}
+			if n == 0 {
+				literal.WriteRune('$')
+				idx++
+				continue
+			}
+			if literal.Len() > 0 {
+				w = append(w, part{text: literal.String(), quoted: true})
+				literal.Reset()
+			}
+			w = append(w, part{text: name, quoted: true, variable: true})
+			idx += n + 1
+		default:
+			literal.WriteRune(c)
+			idx++
+		}
+	}
+	return nil, 0, fmt.Errorf("unterminated double quote")
+}
+
+// lexVariable liest den Namen nach "$": NAME, {NAME} oder "?". n = 0 heißt, "$" ist wörtlich gemeint.
+func lexVariable(runes []rune) (string, int, error) {
+	if len(runes) == 0 {
+		return "", 0, nil
+	}
+	switch {
+	case runes[0] == '{':
+		end := indexRune(runes, '}')
+		if end < 0 {
+			return "", 0, fmt.Errorf("unterminated ${")
+		}
+		return string(runes[1:end]), end + 1, nil
+	case runes[0] == '(':
+		return "", 0, fmt.Errorf("command substitution is not supported")
+	case runes[0] == '?':
+		return "?", 1, nil
+	}
+	n := 0
+	for n < len(runes) && (runes[n] == '_' || isAlnum(runes[n])) {
+		n++
+	}
+	return string(runes[:n]), n, nil
+}
+
+func isAlnum(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func indexRune(runes []rune, r rune) int {
+	for idx, c := range runes {
+		if c == r {
+			return idx
+		}
+	}
+	return -1
+}
+
+// parse baut aus den Tokens eine Liste von Pipelines.
+func parse(script string) (*list, error) {
+	tokens, err := lex(script)
+	if err != nil {
+		return nil, err
+	}
+	l := &list{}
+	var current pipeline
+	cmd := &command{}
+
+	endCommand := func() error {
+		if len(cmd.args) == 0 && len(cmd.assigns) == 0 {
+			if len(cmd.redirs) > 0 {
+				return fmt.Errorf("redirection without command")
+			}
+			return fmt.Errorf("syntax error: missing command")
+		}
+		current = append(current, cmd)
+		cmd = &command{}
+		return nil
+	}
+
+	for idx := 0; idx < len(tokens); idx++ {
+		tok := tokens[idx]
+		switch tok.op {
+		case "":
+			if name, value, ok := splitAssignment(tok.word); ok && len(cmd.args) == 0 {
+				cmd.assigns = append(cmd.assigns, assignment{name: name, value: value})
+				continue
+			}
+			cmd.args = append(cmd.args, tok.word)
+		case ">", ">>", "<", "2>", "2>>":
+			if idx+1 >= len(tokens) || tokens[idx+1].op != "" {
+				return nil, fmt.Errorf("syntax error: missing target for %s", tok.op)
+			}
+			idx++
+			cmd.redirs = append(cmd.redirs, redirect{op: tok.op, target: tokens[idx].word})
+		case "2>&1", ">&2":
+			cmd.redirs = append(cmd.redirs, redirect{op: tok.op})
+		case ">&1", "2>&2":
+			// leitet auf sich selbst um
+		case "|":
+			if err := endCommand(); err != nil {
+				return nil, err
+			}
+		case "&&", "||", ";":
+			if tok.op == ";" && len(current) == 0 && len(cmd.args) == 0 && len(cmd.assigns) == 0 {
+				continue // leere Zeilen
+			}
+			if err := endCommand(); err != nil {
+				return nil, err
+			}
+			l.pipelines = append(l.pipelines, current)
+			l.ops = append(l.ops, tok.op)
+			current = nil
+		default:
+			return nil, fmt.Errorf("unsupported operator %s", tok.op)
+		}
+	}
+	if len(cmd.args) > 0 || len(cmd.assigns) > 0 || len(cmd.redirs) > 0 {
+		if err := endCommand(); err != nil {
+			return nil, err
+		}
+	}
+	if len(current) > 0 {
+		l.pipelines = append(l.pipelines, current)
+	} else if len(l.ops) > 0 {
+		if last := l.ops[len(l.ops)-1]; last != ";" {
+			return nil, fmt.Errorf("syntax error: missing command after %s", last)
+		}
+		l.ops = l.ops[:len(l.ops)-1]
+	}
+	return l, nil
+}
+
+// splitAssignment erkennt NAME=wert; der Name muss unquotiert sein.
+func splitAssignment(w word) (string, word, bool) {
+	if len(w) == 0 || w[0].quoted || w[0].variable {
+		return "", nil, false
+	}
+	eq := strings.IndexByte(w[0].text, '=')
+	if eq <= 0 {
+		return "", nil, false
+	}
+	name := w[0].text[:eq]
+	for idx, c := range name {
+		if !(c == '_' || isAlnum(c)) || (idx == 0 && c >= '0' && c <= '9') {
+			return "", nil, false
+		}
+	}
+	value := word{}
+	if rest := w[0].text[eq+1:]; rest != "" {
+		value = append(value, part{text: rest})
+	}
+	value = append(value, w[1:]...)
+	return name, value, true
+}
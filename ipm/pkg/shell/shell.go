@@ -0,0 +1,477 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExitError meldet einen Exit-Code ungleich 0.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// exitRequest beendet das Skript über das eingebaute "exit".
+type exitRequest struct {
+	code int
+}
+
+func (e *exitRequest) Error() string {
+	return fmt.Sprintf("exit %d", e.code)
+}
+
+// Shell ist ein portabler Interpreter für die Teilmenge von sh, die in
+// npm-Skripten üblich ist: "&&", "||", ";", "|", Umleitungen (>, >>, <, 2>, 2>&1,
+// >&2, auch mit vorangestellter 1), Variablen ($NAME, ${NAME}, $?) mit
+// Worttrennung, Zuweisungen (NAME=wert [befehl]), Quoting und Globbing. Skripte
+// hängen so nicht von bash, cmd oder PowerShell ab.
+type Shell struct {
+	Dir    string
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	env    map[string]string
+	status int
+}
+
+// New erstellt eine Shell mit der Umgebung env ("NAME=wert").
+func New(dir string, env []string) *Shell {
+	s := &Shell{Dir: dir, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr, env: make(map[string]string)}
+	for _, entry := range env {
+		if name, value, ok := strings.Cut(entry, "="); ok && name != "" {
+			s.env[envKey(name)] = value
+		}
+	}
+	return s
+}
+
+// Run führt ein Skript aus. Ein Exit-Code ungleich 0 wird als *ExitError gemeldet.
+func (s *Shell) Run(script string) error {
+	l, err := parse(script)
+	if err != nil {
+		return fmt.Errorf("invalid script %q: %v", script, err)
+	}
+	code, err := s.runList(l)
+	var exit *exitRequest
+	if errors.As(err, &exit) {
+		code, err = exit.code, nil
+	}
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &ExitError{Code: code}
+	}
+	return nil
+}
+
+func (s *Shell) runList(l *list) (int, error) {
+	code := 0
+	for idx, p := range l.pipelines {
+		if idx > 0 {
+			switch l.ops[idx-1] {
+			case "&&":
+				if code != 0 {
+					continue
+				}
+			case "||":
+				if code == 0 {
+					continue
+				}
+			}
+		}
+		var err error
+		code, err = s.runPipeline(p)
+		if err != nil {
+			return code, err
+		}
+		s.status = code
+	}
+	return code, nil
+}
+
+// runPipeline verbindet die Befehle über Pipes; maßgeblich ist der Exit-Code des letzten.
+// Wie in sh laufen alle Befehle bis auf den letzten in einer Subshell (siehe
+// subshell), denn die Befehle laufen gleichzeitig.
+func (s *Shell) runPipeline(p pipeline) (int, error) {
+	if len(p) == 1 {
+		return s.runCommand(p[0], s.Stdin, s.Stdout, s.Stderr)
+	}
+	codes := make([]int, len(p))
+	errs := make([]error, len(p))
+	done := make(chan struct{})
+	stdin := s.Stdin
+	for idx, cmd := range p {
+		var stdout io.Writer = s.Stdout
+		var writer *io.PipeWriter
+		var reader *io.PipeReader
+		if idx < len(p)-1 {
+			reader, writer = io.Pipe()
+			stdout = writer
+		}
+		sh := s
+		if idx < len(p)-1 {
+			sh = s.subshell()
+		}
+		go func(idx int, sh *Shell, cmd *command, stdin io.Reader, stdout io.Writer, writer *io.PipeWriter) {
+			codes[idx], errs[idx] = sh.runCommand(cmd, stdin, stdout, s.Stderr)
+			var exit *exitRequest
+			if sh != s && errors.As(errs[idx], &exit) {
+				// exit beendet nur die Subshell
+				codes[idx], errs[idx] = exit.code, nil
+			}
+			if writer != nil {
+				writer.Close()
+			}
+			if r, ok := stdin.(*io.PipeReader); ok {
+				r.Close() // Vorgänger nicht blockieren, wenn nicht alles gelesen wurde
+			}
+			done <- struct{}{}
+		}(idx, sh, cmd, stdin, stdout, writer)
+		stdin = reader
+	}
+	for range p {
+		<-done
+	}
+	for _, err := range errs {
+		if err != nil {
+			return 1, err
+		}
+	}
+	return codes[len(codes)-1], nil
+}
+
+// subshell liefert eine Kopie der Shell. Zuweisungen, export, unset und cd
+// wirken darin nicht auf die Shell zurück.
+func (s *Shell) subshell() *Shell {
+	env := make(map[string]string, len(s.env))
+	for name, value := range s.env {
+		env[name] = value
+	}
+	return &Shell{Dir: s.Dir, Stdin: s.Stdin, Stdout: s.Stdout, Stderr: s.Stderr, env: env, status: s.status}
+}
+
+func (s *Shell) runCommand(cmd *command, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	// Zuweisungen ohne Befehl gelten für den Rest des Skripts
+	if len(cmd.args) == 0 {
+		for _, a := range cmd.assigns {
+			s.env[envKey(a.name)] = s.expandString(a.value)
+		}
+		return 0, nil
+	}
+
+	env := make(map[string]string, len(s.env))
+	for name, value := range s.env {
+		env[name] = value
+	}
+	for _, a := range cmd.assigns {
+		env[envKey(a.name)] = s.expandString(a.value)
+	}
+
+	var args []string
+	for _, w := range cmd.args {
+		for _, field := range s.split(w) {
+			args = append(args, s.expand(field)...)
+		}
+	}
+	if len(args) == 0 {
+		return 0, nil
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, r := range cmd.redirs {
+		switch r.op {
+		case "2>&1":
+			stderr = stdout
+			continue
+		case ">&2":
+			stdout = stderr
+			continue
+		}
+		target := s.path(s.expandString(r.target))
+		var f *os.File
+		var err error
+		switch r.op {
+		case "<":
+			f, err = os.Open(target)
+		case ">", "2>":
+			f, err = os.Create(target)
+		case ">>", "2>>":
+			f, err = os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "ipm: %v\n", err)
+			return 1, nil
+		}
+		files = append(files, f)
+		switch r.op {
+		case "<":
+			stdin = f
+		case ">", ">>":
+			stdout = f
+		default:
+			stderr = f
+		}
+	}
+
+	if builtin, ok := builtins[args[0]]; ok {
+		return builtin(s, args[1:], stdout, stderr)
+	}
+	return s.runExternal(args, env, stdin, stdout, stderr)
+}
+
+func (s *Shell) runExternal(args []string, env map[string]string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	path, err := lookPath(args[0], s.Dir, env)
+	if err != nil {
+		fmt.Fprintf(stderr, "ipm: %s: command not found\n", args[0])
+		return 127, nil
+	}
+	c := exec.Command(path, args[1:]...)
+	c.Dir = s.Dir
+	c.Env = environ(env)
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if code := exitErr.ExitCode(); code >= 0 {
+				return code, nil
+			}
+			return 1, nil
+		}
+		fmt.Fprintf(stderr, "ipm: %s: %v\n", args[0], err)
+		return 126, nil
+	}
+	return 0, nil
+}
+
+// split trennt ungequotete Variablen wie sh an den Leerraumzeichen aus IFS
+// (Standard: Leerzeichen, Tab, Zeilenumbruch) und liefert die einzelnen Wörter.
+// Die Stücke einer Variablen werden zu gequoteten Teilen, denn wie bisher
+// unterliegen eingesetzte Werte keinem Globbing. Eine leere ungequotete Variable
+// ergibt kein Wort.
+func (s *Shell) split(w word) []word {
+	ifs, ok := s.env[envKey("IFS")]
+	if !ok {
+		ifs = " \t\n"
+	}
+	isSeparator := func(c rune) bool {
+		return strings.ContainsRune(ifs, c) && (c == ' ' || c == '\t' || c == '\n')
+	}
+
+	var fields []word
+	var current word
+	open := false
+	flush := func() {
+		if open {
+			fields = append(fields, current)
+		}
+		current, open = nil, false
+	}
+	for _, p := range w {
+		if !p.variable || p.quoted {
+			current, open = append(current, p), true
+			continue
+		}
+		value := s.lookup(p.text)
+		pieces := strings.FieldsFunc(value, isSeparator)
+		if value == "" {
+			continue
+		}
+		if len(pieces) == 0 || isSeparator([]rune(value)[0]) {
+			flush()
+		}
+		for idx, piece := range pieces {
+			if idx > 0 {
+				flush()
+			}
+			current, open = append(current, part{text: piece, quoted: true}), true
+		}
+		if len(pieces) > 0 && strings.LastIndexFunc(value, isSeparator) == len(value)-1 {
+			flush()
+		}
+	}
+	flush()
+	return fields
+}
+
+// expand setzt Variablen ein und wendet Globbing an. Ergibt ein Muster keinen
+// Treffer, bleibt es wie in bash unverändert stehen.
+func (s *Shell) expand(w word) []string {
+	value := s.expandString(w)
+	if len(w) == 1 && !w[0].quoted && !w[0].variable && strings.HasPrefix(value, "~") {
+		if home, err := os.UserHomeDir(); err == nil && (value == "~" || strings.HasPrefix(value, "~/")) {
+			value = home + value[1:]
+		}
+	}
+
+	pattern, glob := s.globPattern(w)
+	if !glob {
+		return []string{value}
+	}
+	matches, err := filepath.Glob(s.path(pattern))
+	if err != nil || len(matches) == 0 {
+		return []string{value}
+	}
+	if !filepath.IsAbs(pattern) {
+		for idx, match := range matches {
+			if rel, err := filepath.Rel(s.Dir, match); err == nil {
+				matches[idx] = filepath.ToSlash(rel)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func (s *Shell) expandString(w word) string {
+	var b strings.Builder
+	for _, p := range w {
+		if p.variable {
+			b.WriteString(s.lookup(p.text))
+			continue
+		}
+		b.WriteString(p.text)
+	}
+	return b.String()
+}
+
+// globPattern baut das Glob-Muster; Metazeichen in gequoteten Teilen werden maskiert.
+func (s *Shell) globPattern(w word) (string, bool) {
+	var b strings.Builder
+	glob := false
+	for _, p := range w {
+		text := p.text
+		if p.variable {
+			text = s.lookup(p.text)
+		}
+		if p.quoted || p.variable {
+			if strings.ContainsAny(text, "*?[") {
+				if runtime.GOOS == "windows" {
+					return "", false // "\" ist dort ein Pfadtrenner und taugt nicht zum Maskieren
+				}
+				for _, c := range text {
+					if strings.ContainsRune("*?[\\", c) {
+						b.WriteRune('\\')
+					}
+					b.WriteRune(c)
+				}
+				continue
+			}
+			b.WriteString(text)
+			continue
+		}
+		if strings.ContainsAny(text, "*?[") {
+			glob = true
+		}
+		b.WriteString(text)
+	}
+	return b.String(), glob
+}
+
+func (s *Shell) lookup(name string) string {
+	if name == "?" {
+		return strconv.Itoa(s.status)
+	}
+	return s.env[envKey(name)]
+}
+
+// path löst einen Pfad relativ zum Arbeitsverzeichnis der Shell auf.
+func (s *Shell) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(p))
+}
+
+// envKey vereinheitlicht Variablennamen; unter Windows ist die Groß-/Kleinschreibung egal.
+func envKey(name string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+func environ(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for name, value := range env {
+		list = append(list, name+"="+value)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// lookPath sucht einen Befehl im PATH der Shell (nicht dem von ipm). Unter Windows
+// werden die Endungen aus PATHEXT probiert, z. B. für .cmd-Shims in node_modules/.bin.
+func lookPath(name, dir string, env map[string]string) (string, error) {
+	var exts []string
+	if runtime.GOOS == "windows" {
+		exts = []string{""}
+		pathext := env[envKey("PATHEXT")]
+		if pathext == "" {
+			pathext = ".COM;.EXE;.BAT;.CMD"
+		}
+		for _, ext := range strings.Split(pathext, ";") {
+			if ext != "" {
+				exts = append(exts, strings.ToLower(ext))
+			}
+		}
+	} else {
+		exts = []string{""}
+	}
+
+	candidates := []string{}
+	if strings.ContainsAny(name, `/\`) {
+		candidates = append(candidates, name)
+		if !filepath.IsAbs(name) {
+			candidates[0] = filepath.Join(dir, filepath.FromSlash(name))
+		}
+	} else {
+		for _, entry := range filepath.SplitList(env[envKey("PATH")]) {
+			if entry == "" {
+				entry = "."
+			}
+			if !filepath.IsAbs(entry) {
+				entry = filepath.Join(dir, entry)
+			}
+			candidates = append(candidates, filepath.Join(entry, name))
+		}
+	}
+	for _, candidate := range candidates {
+		for _, ext := range exts {
+			if isExecutable(candidate + ext) {
+				return candidate + ext, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%s: not found", name)
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return info.Mode()&0111 != 0
+}
@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"bytes"
+	"testing"
+)
+
+func run(t *testing.T, s *Shell, script string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	s.Stdout, s.Stderr = &stdout, &stderr
+	if err := s.Run(script); err != nil {
+		t.Fatalf("Run(%q): %v (stderr %q)", script, err, stderr.String())
+	}
+	return stdout.String()
+}
+
+// Die Befehle einer Pipeline laufen gleichzeitig; mit -race zeigt der Test, dass
+// sie sich keinen Zustand teilen.
+func TestPipelineSubshells(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"A=1 | B=2 | echo \"[$A$B]\"", "[]\n"},
+		{"export X=1 | unset HOME | cd .. | echo done", "done\n"},
+		{"A=1 | true; echo \"[$A]\"", "[]\n"},
+		{"export X=1 | true; echo \"[$X]\"", "[]\n"},
+		{"exit 3 | echo ok", "ok\n"},
+		{"true | A=2; echo \"[$A]\"", "[2]\n"},
+	}
+	for _, tt := range tests {
+		for n := 0; n < 20; n++ {
+			dir := t.TempDir()
+			s := New(dir, []string{"HOME=" + dir, "PATH="})
+			if got := run(t, s, tt.script); got != tt.want {
+				t.Fatalf("Run(%q) printed %q, want %q", tt.script, got, tt.want)
+			}
+			if s.Dir != dir {
+				t.Fatalf("Run(%q) changed the directory to %s", tt.script, s.Dir)
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package signing
+
+import (
+	"archive/tar"
+	"crypto"
+	"encoding/json"
+	"fmt"
+)
+
+// CosignTarball fügt einem signierten Tarball eine weitere Signatur mit key hinzu,
+// etwa für eine zweite Freigabe. Die vorhandenen Signaturen bleiben unverändert;
+// die neue steht mit eigenen Metadaten in signature.cosign.json und deckt
+// denselben Digest ab. Passt der Inhalt nicht mehr zur vorhandenen Signatur, wird
+// nicht mitsigniert.
+func CosignTarball(tgz []byte, key crypto.Signer, opts SignOptions) ([]byte, *Metadata, error) {
+	all, err := readEntries(tgz)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, signature, metaData, cosignData := splitSignatures(all)
+	if signature == nil {
+		return nil, nil, fmt.Errorf("package is not signed")
+	}
+	primary, err := parseMetadata(metaData)
+	if err != nil {
+		return nil, nil, err
+	}
+	cosignatures, err := parseCosignatures(cosignData)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyIDs := []string{primary.KeyID}
+	for _, c := range cosignatures {
+		keyIDs = append(keyIDs, c.KeyID)
+	}
+	cosignature, err := newCosignature(tgz, key, opts, primary, keyIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	cosignData, err = json.MarshalIndent(append(cosignatures, *cosignature), "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode signatures: %v", err)
+	}
+
+	entries = append(entries,
+		entry{header: &tar.Header{Name: SignatureFile, Mode: 0644, Size: int64(len(signature))}, data: signature},
+	)
+	if metaData != nil {
+		entries = append(entries, entry{header: &tar.Header{Name: MetadataFile, Mode: 0644, Size: int64(len(metaData))}, data: metaData})
+	}
+	entries = append(entries,
+		entry{header: &tar.Header{Name: CosignaturesFile, Mode: 0644, Size: int64(len(cosignData))}, data: cosignData},
+	)
+	signed, err := writeEntries(entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signed, &cosignature.Metadata, nil
+}
+
+// CosignDetached fügt der abgelösten Signatur envelope zu tgz eine weitere mit
+// key hinzu. Im JSON-Format kommt sie mit eigenen Metadaten nach cosignatures, im
+// DSSE-Format als weitere Signatur über dieselbe Nutzlast; dort hat sie keinen
+// eigenen Signer und Zeitpunkt, und es gilt der Standardalgorithmus des
+// Schlüssels.
+func CosignDetached(envelope, tgz []byte, key crypto.Signer, opts SignOptions) ([]byte, *Metadata, error) {
+	parsed, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, nil, err
+	}
+	var keyIDs []string
+	for _, s := range parsed.signatures {
+		keyIDs = append(keyIDs, s.KeyID)
+	}
+	for _, c := range parsed.cosignatures {
+		keyIDs = append(keyIDs, c.KeyID)
+	}
+
+	var out interface{}
+	var meta *Metadata
+	if parsed.dsse {
+		algorithm, err := DefaultAlgorithm(key.Public())
+		if err != nil {
+			return nil, nil, err
+		}
+		if opts.Algorithm != "" && opts.Algorithm != algorithm {
+			return nil, nil, fmt.Errorf("DSSE co-signatures use the default algorithm of the key (%s)", algorithm)
+		}
+		statement, err := newStatement(tgz, key.Public(), opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkCosign(statement, parsed.meta, keyIDs); err != nil {
+			return nil, nil, err
+		}
+		signature, err := Sign(key, algorithm, pae(PayloadType, parsed.payload))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to sign package: %v", err)
+		}
+		out = &dsseEnvelope{
+			PayloadType: PayloadType,
+			Payload:     parsed.payload,
+			Signatures:  append(parsed.signatures, dsseSignature{KeyID: statement.KeyID, Sig: signature}),
+		}
+		meta = cosignerMetadata(parsed.meta, statement.KeyID)
+		meta.Algorithm = algorithm
+	} else {
+		cosignature, err := newCosignature(tgz, key, opts, parsed.meta, keyIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = &Envelope{
+			Metadata:     *parsed.meta,
+			Signature:    parsed.signatures[0].Sig,
+			Cosignatures: append(parsed.cosignatures, *cosignature),
+		}
+		meta = &cosignature.Metadata
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode signature: %v", err)
+	}
+	return append(data, '\n'), meta, nil
+}
+
+// newCosignature signiert tgz als weitere Signatur neben primary; keyIDs sind
+// die Schlüssel, die bereits signiert haben.
+func newCosignature(tgz []byte, key crypto.Signer, opts SignOptions, primary *Metadata, keyIDs []string) (*Envelope, error) {
+	meta, err := newStatement(tgz, key.Public(), opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCosign(meta, primary, keyIDs); err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signature metadata: %v", err)
+	}
+	signature, err := Sign(key, meta.Algorithm, pae(meta.Type, payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign package: %v", err)
+	}
+	return &Envelope{Metadata: *meta, Signature: signature}, nil
+}
+
+// checkCosign stellt sicher, dass statement denselben Inhalt wie die vorhandene
+// Signatur primary abdeckt und der Schlüssel noch nicht signiert hat.
+func checkCosign(statement, primary *Metadata, keyIDs []string) error {
+	if primary.Digest == "" {
+		return fmt.Errorf("the existing signature has no content digest and cannot be co-signed; sign with --replace")
+	}
+	if statement.Digest != primary.Digest {
+		return fmt.Errorf("package contents do not match the existing signature (%s, signed %s); sign with --replace to discard it", statement.Digest, primary.Digest)
+	}
+	for _, id := range keyIDs {
+		if id == statement.KeyID {
+			return fmt.Errorf("package is already signed with key %s", statement.KeyID)
+		}
+	}
+	return nil
+}
+
+// cosignerMetadata sind die Metadaten einer weiteren DSSE-Signatur: Inhalt und
+// Paket der ersten, aber ohne deren Signer und Zeitpunkt.
+func cosignerMetadata(meta *Metadata, keyID string) *Metadata {
+	return &Metadata{Type: meta.Type, KeyID: keyID, Digest: meta.Digest, Package: meta.Package, Version: meta.Version}
+}
+
+// parseCosignatures liest signature.cosign.json; ohne Datei gibt es keine
+// weiteren Signaturen.
+func parseCosignatures(data []byte) ([]Envelope, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var cosignatures []Envelope
+	if err := json.Unmarshal(data, &cosignatures); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", CosignaturesFile, err)
+	}
+	if err := checkCosignatures(cosignatures); err != nil {
+		return nil, err
+	}
+	return cosignatures, nil
+}
+
+// checkCosignatures verlangt signierte Metadaten mit Digest, denn weitere
+// Signaturen gibt es erst seit dem Manifest.
+func checkCosignatures(cosignatures []Envelope) error {
+	for _, c := range cosignatures {
+		if c.Type != PayloadType || c.Algorithm == "" || c.KeyID == "" || c.Digest == "" {
+			return fmt.Errorf("co-signature by key %q names no type, algorithm or digest", c.KeyID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package signing
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestHeader leitet das kanonische Manifest ein und versioniert sein Format.
+const manifestHeader = "ipm-manifest-v1\n"
+
+// fileDigest ist eine Zeile des kanonischen Manifests.
+type fileDigest struct {
+	path   string
+	mode   string // "644" oder "755"; nur das Ausführbar-Bit zählt
+	sha256 string
+}
+
+// Manifest ist die kanonische Beschreibung eines Paketinhalts: je Datei eine Zeile
+// "pfad<TAB>modus<TAB>sha256", sortiert nach Pfad. Es hängt weder von gzip noch
+// von tar-Headern (Zeitstempel, Besitzer) ab und ist für einen Tarball und das
+// daraus entpackte Verzeichnis gleich. Signaturdateien gehören nicht dazu.
+type Manifest []byte
+
+// Digest ist der SHA-256 des Manifests, z. B. "sha256:3f2a…".
+func (m Manifest) Digest() string {
+	sum := sha256.Sum256(m)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// TarballManifest berechnet das Manifest eines gzip-komprimierten Tarballs.
+func TarballManifest(tgz []byte) (Manifest, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(tgz))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip: %v", err)
+	}
+	defer gzr.Close()
+
+	var files []fileDigest
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tarball: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, ok := packagePath(hdr.Name)
+		if !ok {
+			continue
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", hdr.Name, err)
+		}
+		files = append(files, fileDigest{path: name, mode: modeOf(hdr.FileInfo().Mode()), sha256: hex.EncodeToString(h.Sum(nil))})
+	}
+	return buildManifest(files)
+}
+
+// DirManifest berechnet das Manifest eines entpackten Pakets, z. B. im Cache. Alle
+// Dateien in dir gehören zum Inhalt; die Signaturdateien liegen in SignatureDir.
+func DirManifest(dir string) (Manifest, error) {
+	var files []fileDigest
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := path.Clean(filepath.ToSlash(rel))
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		files = append(files, fileDigest{path: name, mode: modeOf(info.Mode()), sha256: hex.EncodeToString(sum[:])})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package directory %s: %v", dir, err)
+	}
+	return buildManifest(files)
+}
+
+// packagePath normalisiert einen Pfad im Tarball wie beim Entpacken in den Cache
+// ("package/" entfällt) und schließt die Signaturdateien aus. Signaturdateien
+// liegen neben "package/"; gleichnamige Dateien des Pakets gehören zum Inhalt.
+func packagePath(name string) (string, bool) {
+	name = strings.TrimPrefix(name, "./")
+	if IsSignatureEntry(name) {
+		return "", false
+	}
+	name = path.Clean(strings.TrimPrefix(name, "package/"))
+	if name == "." {
+		return "", false
+	}
+	return name, true
+}
+
+// IsSignatureEntry meldet, ob der Tarball-Eintrag name eine eingebettete Signatur
+// ist, also signature.sig, signature.json oder signature.cosign.json neben "package/".
+func IsSignatureEntry(name string) bool {
+	switch strings.TrimPrefix(name, "./") {
+	case SignatureFile, MetadataFile, CosignaturesFile:
+		return true
+	}
+	return false
+}
+
+// SignatureDir ist die Ablage der eingebetteten Signaturdateien eines in dir
+// entpackten Pakets, neben dem Paketverzeichnis, damit sie nicht mit Dateien des
+// Pakets zusammenfallen.
+func SignatureDir(dir string) string {
+	return dir + ".signature"
+}
+
+func modeOf(mode fs.FileMode) string {
+	if mode&0111 != 0 {
+		return "755"
+	}
+	return "644"
+}
+
+func buildManifest(files []fileDigest) (Manifest, error) {
+	sort.Slice(files, func(a, b int) bool { return files[a].path < files[b].path })
+	var buf bytes.Buffer
+	buf.WriteString(manifestHeader)
+	for idx, f := range files {
+		if idx > 0 && files[idx-1].path == f.path {
+			return nil, fmt.Errorf("duplicate file %s in package", f.path)
+		}
+		if strings.ContainsAny(f.path, "\t\n") {
+			return nil, fmt.Errorf("unsupported file name %q in package", f.path)
+		}
+		fmt.Fprintf(&buf, "%s\t%s\t%s\n", f.path, f.mode, f.sha256)
+	}
+	return buf.Bytes(), nil
+}
+
+// Änderungsarten in ManifestChange.
+const (
+	FileModified = "modified"
+	FileAdded    = "added"
+	FileDeleted  = "deleted"
+	FileMode     = "mode"
+)
+
+// ManifestChange beschreibt eine Datei, die sich zwischen zwei Manifesten unterscheidet.
+type ManifestChange struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`           // FileModified, FileAdded, FileDeleted oder FileMode
+	Mode string `json:"mode,omitempty"` // bei FileMode: der neue Modus
+}
+
+// Diff vergleicht das Manifest m (z. B. bei der Installation festgehalten) mit
+// current, nach Pfad sortiert.
+func (m Manifest) Diff(current Manifest) ([]ManifestChange, error) {
+	before, err := m.files()
+	if err != nil {
+		return nil, err
+	}
+	after, err := current.files()
+	if err != nil {
+		return nil, err
+	}
+	var changes []ManifestChange
+	for p, old := range before {
+		now, ok := after[p]
+		switch {
+		case !ok:
+			changes = append(changes, ManifestChange{Path: p, Kind: FileDeleted})
+		case now.sha256 != old.sha256:
+			changes = append(changes, ManifestChange{Path: p, Kind: FileModified})
+		case now.mode != old.mode:
+			changes = append(changes, ManifestChange{Path: p, Kind: FileMode, Mode: now.mode})
+		}
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			changes = append(changes, ManifestChange{Path: p, Kind: FileAdded})
+		}
+	}
+	sort.Slice(changes, func(a, b int) bool { return changes[a].Path < changes[b].Path })
+	return changes, nil
+}
+
+// files liest die Zeilen des Manifests.
+func (m Manifest) files() (map[string]fileDigest, error) {
+	if !bytes.HasPrefix(m, []byte(manifestHeader)) {
+		return nil, fmt.Errorf("invalid package manifest")
+	}
+	files := map[string]fileDigest{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(m[len(manifestHeader):]), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid package manifest line %q", line)
+		}
+		files[fields[0]] = fileDigest{path: fields[0], mode: fields[1], sha256: fields[2]}
+	}
+	return files, nil
+}
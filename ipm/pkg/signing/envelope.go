@@ -0,0 +1,232 @@
+package signing
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Formate abgelöster Signaturen. Eine abgelöste Signatur liegt neben dem Tarball
+// in <datei>.sig und lässt den Tarball unverändert.
+const (
+	FormatJSON = "json"
+	FormatDSSE = "dsse"
+
+	DetachedSuffix = ".sig"
+
+	// PayloadType kennzeichnet signierte Metadaten; signiert wird nach DSSE
+	// PAE(PayloadType, Metadaten als JSON), eingebettet wie abgelöst.
+	PayloadType = "application/vnd.ipm.signature.v1+json"
+)
+
+// Envelope ist eine abgelöste Signatur im JSON-Format: die Metadaten mit der
+// Signatur (base64) daneben. Cosignatures sind weitere Signaturen desselben
+// Inhalts mit eigenen Metadaten (siehe CosignDetached).
+type Envelope struct {
+	Metadata
+	Signature    []byte     `json:"signature"`
+	Cosignatures []Envelope `json:"cosignatures,omitempty"`
+}
+
+// dsseEnvelope ist eine abgelöste Signatur im DSSE-Format; die Nutzlast sind die
+// Metadaten als JSON.
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     []byte          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   []byte `json:"sig"`
+}
+
+// SignDetached signiert einen Tarball, ohne ihn zu verändern, und liefert die
+// Signatur im Format format (FormatJSON oder FormatDSSE).
+func SignDetached(tgz []byte, key crypto.Signer, opts SignOptions, format string) ([]byte, *Metadata, error) {
+	if format != FormatJSON && format != FormatDSSE {
+		return nil, nil, fmt.Errorf("unsupported signature format %q (expected %s or %s)", format, FormatJSON, FormatDSSE)
+	}
+	meta, err := newStatement(tgz, key.Public(), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode signature metadata: %v", err)
+	}
+	signature, err := Sign(key, meta.Algorithm, pae(meta.Type, payload))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign package: %v", err)
+	}
+
+	var envelope interface{} = &Envelope{Metadata: *meta, Signature: signature}
+	if format == FormatDSSE {
+		envelope = &dsseEnvelope{
+			PayloadType: meta.Type,
+			Payload:     payload,
+			Signatures:  []dsseSignature{{KeyID: meta.KeyID, Sig: signature}},
+		}
+	}
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode signature: %v", err)
+	}
+	return append(data, '\n'), meta, nil
+}
+
+// ReadDetached liest die abgelöste Signatur zu file; ohne Datei ergibt sich nil.
+func ReadDetached(file string) ([]byte, error) {
+	data, err := os.ReadFile(file + DetachedSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read detached signature: %v", err)
+	}
+	return data, nil
+}
+
+// DetachedFormat liefert das Format einer abgelösten Signatur, FormatJSON oder
+// FormatDSSE.
+func DetachedFormat(envelope []byte) (string, error) {
+	parsed, err := parseEnvelope(envelope)
+	if err != nil {
+		return "", err
+	}
+	if parsed.dsse {
+		return FormatDSSE, nil
+	}
+	return FormatJSON, nil
+}
+
+// parsedEnvelope ist eine gelesene abgelöste Signatur: Metadaten, signierte
+// Nutzlast, die Signaturen darüber und weitere Signaturen mit eigenen Metadaten.
+type parsedEnvelope struct {
+	meta         *Metadata
+	payload      []byte
+	signatures   []dsseSignature
+	cosignatures []Envelope
+	dsse         bool
+}
+
+// parseEnvelope liest eine abgelöste Signatur im JSON- oder DSSE-Format.
+func parseEnvelope(data []byte) (*parsedEnvelope, error) {
+	var probe struct {
+		PayloadType string `json:"payloadType"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid detached signature: %v", err)
+	}
+
+	var meta Metadata
+	parsed := &parsedEnvelope{meta: &meta, dsse: probe.PayloadType != ""}
+	if parsed.dsse {
+		var env dsseEnvelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, fmt.Errorf("invalid DSSE envelope: %v", err)
+		}
+		if env.PayloadType != PayloadType {
+			return nil, fmt.Errorf("unsupported DSSE payload type %q", env.PayloadType)
+		}
+		if err := json.Unmarshal(env.Payload, &meta); err != nil {
+			return nil, fmt.Errorf("invalid DSSE payload: %v", err)
+		}
+		parsed.payload = env.Payload
+		parsed.signatures = env.Signatures
+	} else {
+		var env Envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, fmt.Errorf("invalid detached signature: %v", err)
+		}
+		if env.Type != PayloadType {
+			return nil, fmt.Errorf("unsupported signature type %q", env.Type)
+		}
+		meta = env.Metadata
+		var err error
+		if parsed.payload, err = json.Marshal(&meta); err != nil {
+			return nil, fmt.Errorf("failed to encode signature metadata: %v", err)
+		}
+		if len(env.Signature) > 0 {
+			parsed.signatures = []dsseSignature{{KeyID: meta.KeyID, Sig: env.Signature}}
+		}
+		if err := checkCosignatures(env.Cosignatures); err != nil {
+			return nil, err
+		}
+		parsed.cosignatures = env.Cosignatures
+	}
+
+	if meta.Algorithm == "" || meta.Digest == "" {
+		return nil, fmt.Errorf("detached signature names no algorithm or digest")
+	}
+	if len(parsed.signatures) == 0 {
+		return nil, fmt.Errorf("detached signature contains no signatures")
+	}
+	return parsed, nil
+}
+
+// pae ist die Pre-Authentication Encoding von DSSE; sie bindet die Signatur an
+// den Typ der Nutzlast.
+func pae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// SignPayload signiert eine beliebige Nutzlast vom Typ payloadType, etwa eine
+// Sperrliste, und liefert sie als DSSE-Umschlag. Der Algorithmus ist der
+// Standard des Schlüsseltyps.
+func SignPayload(key crypto.Signer, payloadType string, payload []byte) ([]byte, error) {
+	algorithm, err := DefaultAlgorithm(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	signature, err := Sign(key, algorithm, pae(payloadType, payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %s: %v", payloadType, err)
+	}
+	data, err := json.MarshalIndent(&dsseEnvelope{
+		PayloadType: payloadType,
+		Payload:     payload,
+		Signatures:  []dsseSignature{{KeyID: keyID, Sig: signature}},
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode envelope: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// VerifyPayload prüft einen DSSE-Umschlag von SignPayload gegen keys und liefert
+// die Nutzlast und die ID des Schlüssels, dessen Signatur gültig ist.
+func VerifyPayload(data []byte, payloadType string, keys []crypto.PublicKey) ([]byte, string, error) {
+	var env dsseEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, "", fmt.Errorf("invalid DSSE envelope: %v", err)
+	}
+	if env.PayloadType != payloadType {
+		return nil, "", fmt.Errorf("unexpected DSSE payload type %q (expected %s)", env.PayloadType, payloadType)
+	}
+	if len(env.Signatures) == 0 {
+		return nil, "", fmt.Errorf("envelope contains no signatures")
+	}
+	message := pae(env.PayloadType, env.Payload)
+	err := fmt.Errorf("signed with key %s, which is not trusted", env.Signatures[0].KeyID)
+	for _, s := range env.Signatures {
+		for _, pub := range keys {
+			if id, idErr := KeyID(pub); idErr != nil || id != s.KeyID {
+				continue
+			}
+			algorithm, algErr := DefaultAlgorithm(pub)
+			if algErr != nil {
+				return nil, "", algErr
+			}
+			if err = Verify(pub, algorithm, message, s.Sig); err == nil {
+				return env.Payload, s.KeyID, nil
+			}
+		}
+	}
+	return nil, "", err
+}
@@ -0,0 +1,266 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Unterstützte Signaturalgorithmen. Der Name steht in den Signatur-Metadaten.
+const (
+	RSAPSS    = "rsa-pss-sha256"
+	RSAPKCS1  = "rsa-pkcs1v15-sha256"
+	ECDSAP256 = "ecdsa-p256-sha256"
+	ECDSAP384 = "ecdsa-p384-sha384"
+	Ed25519   = "ed25519"
+
+	// LegacyAlgorithm gilt für Signaturen ohne Metadaten aus älteren ipm-Versionen
+	LegacyAlgorithm = RSAPKCS1
+)
+
+// Schlüsseltypen für GenerateKey.
+const (
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+)
+
+// ErrPassphraseRequired meldet einen verschlüsselten privaten Schlüssel ohne Passphrase.
+var ErrPassphraseRequired = errors.New("private key is encrypted and needs a passphrase")
+
+// LoadPrivateKey liest einen privaten Schlüssel im PEM-Format: PKCS#8
+// ("PRIVATE KEY", verschlüsselt "ENCRYPTED PRIVATE KEY"), PKCS#1 ("RSA PRIVATE
+// KEY") oder SEC 1 ("EC PRIVATE KEY"). passphrase wird nur für verschlüsselte
+// Schlüssel gebraucht.
+func LoadPrivateKey(path string, passphrase []byte) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key format")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		if passphrase == nil {
+			return nil, ErrPassphraseRequired
+		}
+		der, decryptErr := decryptPKCS8(block.Bytes, passphrase)
+		if decryptErr != nil {
+			return nil, decryptErr
+		}
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	if _, err := DefaultAlgorithm(signer.Public()); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// GenerateKey erzeugt ein Schlüsselpaar: Ed25519, RSA mit bits Bits (0 = 3072)
+// oder ECDSA auf curve ("p256", Standard, oder "p384").
+func GenerateKey(keyType string, bits int, curve string) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	case KeyTypeRSA:
+		if bits == 0 {
+			bits = 3072
+		}
+		if bits < 2048 {
+			return nil, fmt.Errorf("RSA keys need at least 2048 bits")
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case KeyTypeECDSA:
+		switch strings.ToLower(curve) {
+		case "", "p256", "p-256":
+			return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		case "p384", "p-384":
+			return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		}
+		return nil, fmt.Errorf("unsupported ECDSA curve %q (expected p256 or p384)", curve)
+	}
+	return nil, fmt.Errorf("unsupported key type %q (expected %s, %s or %s)", keyType, KeyTypeEd25519, KeyTypeRSA, KeyTypeECDSA)
+}
+
+// MarshalPrivateKey kodiert einen privaten Schlüssel als PKCS#8-PEM, mit
+// passphrase verschlüsselt, sofern sie nicht leer ist.
+func MarshalPrivateKey(key crypto.Signer, passphrase []byte) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %v", err)
+	}
+	if len(passphrase) == 0 {
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+	encrypted, err := encryptPKCS8(der, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted}), nil
+}
+
+// MarshalPublicKey kodiert einen öffentlichen Schlüssel als PKIX-PEM.
+func MarshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// LoadPublicKey liest einen öffentlichen Schlüssel im PEM-Format: PKIX
+// ("PUBLIC KEY") oder PKCS#1 ("RSA PUBLIC KEY").
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %v", err)
+	}
+	return ParsePublicKey(data)
+}
+
+// ParsePublicKey liest einen PEM-kodierten öffentlichen Schlüssel.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key format")
+	}
+	var key crypto.PublicKey
+	var err error
+	if block.Type == "RSA PUBLIC KEY" {
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	if _, err := DefaultAlgorithm(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// KeyID ist ein kurzer Fingerabdruck des öffentlichen Schlüssels (SHA-256 über PKIX).
+func KeyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode public key: %v", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// DefaultAlgorithm wählt den Algorithmus für einen Schlüsseltyp. Für RSA ist das
+// RSA-PSS; PKCS#1 v1.5 lässt sich ausdrücklich wählen.
+func DefaultAlgorithm(pub crypto.PublicKey) (string, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return RSAPSS, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return ECDSAP256, nil
+		case elliptic.P384():
+			return ECDSAP384, nil
+		}
+		return "", fmt.Errorf("unsupported ECDSA curve %s (expected P-256 or P-384)", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return Ed25519, nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", pub)
+}
+
+// checkAlgorithm prüft, ob der Algorithmus zum Schlüsseltyp passt.
+func checkAlgorithm(pub crypto.PublicKey, algorithm string) error {
+	switch algorithm {
+	case RSAPSS, RSAPKCS1, ECDSAP256, ECDSAP384, Ed25519:
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", algorithm)
+	}
+	def, err := DefaultAlgorithm(pub)
+	if err != nil {
+		return err
+	}
+	if algorithm == def || (def == RSAPSS && algorithm == RSAPKCS1) {
+		return nil
+	}
+	return fmt.Errorf("algorithm %s does not match the %s key", algorithm, def)
+}
+
+// Sign signiert message mit algorithm.
+func Sign(key crypto.Signer, algorithm string, message []byte) ([]byte, error) {
+	if err := checkAlgorithm(key.Public(), algorithm); err != nil {
+		return nil, err
+	}
+	switch algorithm {
+	case Ed25519:
+		return key.Sign(rand.Reader, message, crypto.Hash(0))
+	case ECDSAP384:
+		digest := sha512.Sum384(message)
+		return key.Sign(rand.Reader, digest[:], crypto.SHA384) // ASN.1-kodiert
+	case RSAPSS:
+		digest := sha256.Sum256(message)
+		return key.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	default: // RSAPKCS1, ECDSAP256
+		digest := sha256.Sum256(message)
+		return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+}
+
+// Verify prüft eine mit algorithm erstellte Signatur über message.
+func Verify(pub crypto.PublicKey, algorithm string, message, signature []byte) error {
+	if err := checkAlgorithm(pub, algorithm); err != nil {
+		return err
+	}
+	ok := false
+	switch algorithm {
+	case Ed25519:
+		ok = ed25519.Verify(pub.(ed25519.PublicKey), message, signature)
+	case ECDSAP256:
+		digest := sha256.Sum256(message)
+		ok = ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], signature)
+	case ECDSAP384:
+		digest := sha512.Sum384(message)
+		ok = ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], signature)
+	case RSAPSS:
+		digest := sha256.Sum256(message)
+		ok = rsa.VerifyPSS(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case RSAPKCS1:
+		digest := sha256.Sum256(message)
+		ok = rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	}
+	if !ok {
+		return fmt.Errorf("invalid %s signature", algorithm)
+	}
+	return nil
+}
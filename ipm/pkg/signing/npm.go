@@ -0,0 +1,391 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipm/pkg/types"
+)
+
+// Prädikate der Attestierungen, die die npm-Registry unter dist.attestations ablegt.
+const (
+	PublishPredicate    = "https://github.com/npm/attestation/tree/main/specs/publish/v0.1"
+	ProvenancePredicate = "https://slsa.dev/provenance/v1"
+
+	inTotoPayloadType = "application/vnd.in-toto+json"
+)
+
+// oidFulcioIssuer ist die Zertifikatserweiterung mit dem OIDC-Aussteller der Identität.
+var oidFulcioIssuer = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+
+// RegistryKey ist ein Signaturschlüssel der npm-Registry, wie ihn /-/npm/v1/keys liefert.
+type RegistryKey struct {
+	KeyID   string  `json:"keyid"` // "SHA256:…"
+	KeyType string  `json:"keytype"`
+	Scheme  string  `json:"scheme"` // "ecdsa-sha2-nistp256"
+	Key     string  `json:"key"`    // SPKI, DER in Base64
+	Expires *string `json:"expires"`
+
+	pub crypto.PublicKey
+}
+
+// RegistryKeys ist die Antwort von /-/npm/v1/keys.
+type RegistryKeys struct {
+	Keys []RegistryKey `json:"keys"`
+}
+
+// ParseRegistryKeys liest die Schlüssel einer Registry, etwa aus einer lokal
+// abgelegten Kopie von /-/npm/v1/keys.
+func ParseRegistryKeys(data []byte) (*RegistryKeys, error) {
+	var keys RegistryKeys
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid registry keys: %v", err)
+	}
+	for idx := range keys.Keys {
+		k := &keys.Keys[idx]
+		der, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry key %s: %v", k.KeyID, err)
+		}
+		if k.pub, err = x509.ParsePKIXPublicKey(der); err != nil {
+			return nil, fmt.Errorf("invalid registry key %s: %v", k.KeyID, err)
+		}
+	}
+	return &keys, nil
+}
+
+func (r *RegistryKeys) find(keyID string) (*RegistryKey, error) {
+	for idx := range r.Keys {
+		if r.Keys[idx].KeyID == keyID {
+			return &r.Keys[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("no registry key %s", keyID)
+}
+
+// verify prüft eine Signatur des Schlüssels über message. Ein abgelaufener
+// Schlüssel gilt nur für Signaturen, die laut signedAt vor dem Ablauf entstanden;
+// ohne signedAt (dist.signatures) bleibt der Ablauf ungeprüft.
+func (k *RegistryKey) verify(message, signature []byte, signedAt time.Time) error {
+	if k.Expires != nil && !signedAt.IsZero() {
+		expires, err := time.Parse(time.RFC3339, *k.Expires)
+		if err == nil && signedAt.After(expires) {
+			return fmt.Errorf("registry key %s expired at %s", k.KeyID, *k.Expires)
+		}
+	}
+	algorithm, err := DefaultAlgorithm(k.pub)
+	if err != nil {
+		return err
+	}
+	return Verify(k.pub, algorithm, message, signature)
+}
+
+// VerifyRegistrySignature prüft dist.signatures eines Pakets: die Registry signiert
+// "<name>@<version>:<dist.integrity>". Geliefert wird die ID des Schlüssels, dessen
+// Signatur gültig ist.
+func VerifyRegistrySignature(keys *RegistryKeys, pkg types.Package) (string, error) {
+	if len(pkg.Signatures) == 0 {
+		return "", fmt.Errorf("%s@%s has no registry signature", pkg.Name, pkg.Version)
+	}
+	if pkg.Integrity == "" {
+		return "", fmt.Errorf("%s@%s has registry signatures but no dist.integrity", pkg.Name, pkg.Version)
+	}
+	message := []byte(fmt.Sprintf("%s@%s:%s", pkg.Name, pkg.Version, pkg.Integrity))
+	var err error
+	for _, s := range pkg.Signatures {
+		key, keyErr := keys.find(s.KeyID)
+		if keyErr != nil {
+			if err == nil {
+				err = keyErr
+			}
+			continue
+		}
+		sig, decodeErr := base64.StdEncoding.DecodeString(s.Sig)
+		if decodeErr != nil {
+			err = fmt.Errorf("invalid registry signature: %v", decodeErr)
+			continue
+		}
+		if err = key.verify(message, sig, time.Time{}); err == nil {
+			return key.KeyID, nil
+		}
+	}
+	return "", fmt.Errorf("registry signature of %s@%s is invalid: %v", pkg.Name, pkg.Version, err)
+}
+
+// Status einer Attestierung, siehe Attestation.
+const (
+	AttestationVerified   = "verified"
+	AttestationUnverified = "unverified" // Zertifikat ohne vertrauenswürdige Wurzel geprüft
+)
+
+// Attestation ist eine geprüfte Attestierung aus einem Bundle der Registry.
+type Attestation struct {
+	PredicateType string `json:"predicateType"`
+	// Status ist AttestationVerified oder AttestationUnverified, wenn keine Wurzeln
+	// gesetzt sind: dann passt die Signatur zwar zum Zertifikat im Bundle, doch das
+	// kann jeder ausstellen. Identity, Issuer und Source bleiben in dem Fall leer.
+	Status string `json:"status"`
+	// KeyID ist der Registry-Schlüssel (Publish-Attestierung), Identity und Issuer
+	// stammen aus dem Zertifikat einer Provenance, z. B. der Workflow, der gebaut hat
+	KeyID    string `json:"keyId,omitempty"`
+	Identity string `json:"identity,omitempty"`
+	Issuer   string `json:"issuer,omitempty"`
+	// Source ist das Repository aus der Provenance
+	Source string `json:"source,omitempty"`
+	// CertificateVerified meldet, ob das Zertifikat auf eine vertrauenswürdige Wurzel zurückgeht
+	CertificateVerified bool `json:"certificateVerified,omitempty"`
+}
+
+// attestationBundles ist die Antwort von dist.attestations.url.
+type attestationBundles struct {
+	Attestations []struct {
+		PredicateType string         `json:"predicateType"`
+		Bundle        sigstoreBundle `json:"bundle"`
+	} `json:"attestations"`
+}
+
+type sigstoreBundle struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		PublicKey *struct {
+			Hint string `json:"hint"`
+		} `json:"publicKey"`
+		X509CertificateChain *struct {
+			Certificates []struct {
+				RawBytes []byte `json:"rawBytes"`
+			} `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		Certificate *struct {
+			RawBytes []byte `json:"rawBytes"`
+		} `json:"certificate"`
+		TlogEntries []struct {
+			IntegratedTime json.Number `json:"integratedTime"`
+		} `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	DSSEEnvelope *dsseEnvelope `json:"dsseEnvelope"`
+}
+
+// inTotoStatement ist die Nutzlast einer Attestierung.
+type inTotoStatement struct {
+	Type    string `json:"_type"`
+	Subject []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// VerifyAttestations prüft ein Attestierungs-Bundle der Registry offline: die
+// DSSE-Signatur mit dem Registry-Schlüssel oder dem Zertifikat im Bundle und das
+// Subjekt gegen Name, Version und dist.integrity. Zertifikate werden gegen roots
+// geprüft, sofern angegeben, zum Zeitpunkt des Transparenzlog-Eintrags. Die
+// Einträge im Transparenzlog selbst lassen sich ohne Netz nicht prüfen.
+func VerifyAttestations(data []byte, keys *RegistryKeys, roots *x509.CertPool, pkg types.Package) ([]Attestation, error) {
+	var bundles attestationBundles
+	if err := json.Unmarshal(data, &bundles); err != nil {
+		return nil, fmt.Errorf("invalid attestation bundle: %v", err)
+	}
+	if len(bundles.Attestations) == 0 {
+		return nil, fmt.Errorf("attestation bundle of %s@%s is empty", pkg.Name, pkg.Version)
+	}
+	var results []Attestation
+	for _, a := range bundles.Attestations {
+		result, err := verifyAttestation(a.Bundle, a.PredicateType, keys, roots, pkg)
+		if err != nil {
+			return nil, fmt.Errorf("attestation %s of %s@%s: %v", a.PredicateType, pkg.Name, pkg.Version, err)
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+func verifyAttestation(bundle sigstoreBundle, predicateType string, keys *RegistryKeys, roots *x509.CertPool, pkg types.Package) (*Attestation, error) {
+	env := bundle.DSSEEnvelope
+	if env == nil || len(env.Signatures) == 0 {
+		return nil, fmt.Errorf("bundle contains no signed DSSE envelope")
+	}
+	if env.PayloadType != inTotoPayloadType {
+		return nil, fmt.Errorf("unsupported payload type %q", env.PayloadType)
+	}
+	var statement inTotoStatement
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return nil, fmt.Errorf("invalid in-toto statement: %v", err)
+	}
+	if statement.PredicateType != predicateType {
+		return nil, fmt.Errorf("statement has predicate %s, bundle announces %s", statement.PredicateType, predicateType)
+	}
+	if err := checkSubject(statement, pkg); err != nil {
+		return nil, err
+	}
+
+	result := &Attestation{PredicateType: predicateType, Status: AttestationVerified}
+	message := pae(env.PayloadType, env.Payload)
+	signature := env.Signatures[0].Sig
+	var signedAt time.Time
+	if entries := bundle.VerificationMaterial.TlogEntries; len(entries) > 0 {
+		if seconds, err := strconv.ParseInt(entries[0].IntegratedTime.String(), 10, 64); err == nil {
+			signedAt = time.Unix(seconds, 0)
+		}
+	}
+
+	material := bundle.VerificationMaterial
+	switch {
+	case material.PublicKey != nil:
+		key, err := keys.find(material.PublicKey.Hint)
+		if err != nil {
+			return nil, err
+		}
+		if err := key.verify(message, signature, signedAt); err != nil {
+			return nil, err
+		}
+		result.KeyID = key.KeyID
+	default:
+		var chain [][]byte
+		if material.Certificate != nil {
+			chain = append(chain, material.Certificate.RawBytes)
+		} else if material.X509CertificateChain != nil {
+			for _, c := range material.X509CertificateChain.Certificates {
+				chain = append(chain, c.RawBytes)
+			}
+		}
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("bundle contains neither a key hint nor a certificate")
+		}
+		cert, err := verifyCertificate(chain, roots, signedAt)
+		if err != nil {
+			return nil, err
+		}
+		algorithm, err := DefaultAlgorithm(cert.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := Verify(cert.PublicKey, algorithm, message, signature); err != nil {
+			return nil, err
+		}
+		if roots == nil {
+			// Ohne Wurzel belegt das Zertifikat keine Herkunft
+			result.Status = AttestationUnverified
+			return result, nil
+		}
+		result.Identity, result.Issuer = certificateIdentity(cert)
+		result.CertificateVerified = true
+	}
+	if predicateType == ProvenancePredicate {
+		result.Source = provenanceSource(statement.Predicate)
+	}
+	return result, nil
+}
+
+// checkSubject vergleicht das Subjekt "pkg:npm/<name>@<version>" samt sha512 mit
+// dem Paket; "@" von Scopes steht dort als "%40".
+func checkSubject(statement inTotoStatement, pkg types.Package) error {
+	want := "pkg:npm/" + pkg.Name + "@" + pkg.Version
+	digest, err := integrityHex(pkg.Integrity)
+	if err != nil {
+		return err
+	}
+	for _, s := range statement.Subject {
+		if name, err := url.PathUnescape(s.Name); err != nil || name != want {
+			continue
+		}
+		if s.Digest["sha512"] != digest {
+			return fmt.Errorf("subject digest does not match dist.integrity")
+		}
+		return nil
+	}
+	return fmt.Errorf("statement is not about %s", want)
+}
+
+// integrityHex wandelt einen SRI-Hash "sha512-<base64>" in Hex um.
+func integrityHex(integrity string) (string, error) {
+	encoded := strings.TrimPrefix(integrity, "sha512-")
+	if encoded == integrity {
+		return "", fmt.Errorf("dist.integrity %q is not a sha512 hash", integrity)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid dist.integrity: %v", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// verifyCertificate liest die Zertifikatskette und prüft sie gegen roots, wenn gesetzt.
+func verifyCertificate(chain [][]byte, roots *x509.CertPool, signedAt time.Time) (*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, len(chain))
+	for idx, raw := range chain {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %v", err)
+		}
+		certs[idx] = cert
+	}
+	if roots == nil {
+		return certs[0], nil
+	}
+	if signedAt.IsZero() {
+		return nil, fmt.Errorf("bundle has no transparency log time to check the certificate at")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("certificate is not trusted: %v", err)
+	}
+	return certs[0], nil
+}
+
+// certificateIdentity liefert die signierende Identität (URI oder E-Mail im SAN)
+// und den OIDC-Aussteller eines Fulcio-Zertifikats.
+func certificateIdentity(cert *x509.Certificate) (string, string) {
+	var identity, issuer string
+	if len(cert.URIs) > 0 {
+		identity = cert.URIs[0].String()
+	} else if len(cert.EmailAddresses) > 0 {
+		identity = cert.EmailAddresses[0]
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidFulcioIssuer) {
+			issuer = string(ext.Value)
+		}
+	}
+	return identity, issuer
+}
+
+// provenanceSource liest das Quell-Repository aus einer SLSA-Provenance v1.
+func provenanceSource(predicate json.RawMessage) string {
+	var p struct {
+		BuildDefinition struct {
+			ExternalParameters struct {
+				Workflow struct {
+					Repository string `json:"repository"`
+					Ref        string `json:"ref"`
+				} `json:"workflow"`
+			} `json:"externalParameters"`
+		} `json:"buildDefinition"`
+	}
+	if json.Unmarshal(predicate, &p) != nil {
+		return ""
+	}
+	workflow := p.BuildDefinition.ExternalParameters.Workflow
+	if workflow.Repository == "" || workflow.Ref == "" {
+		return workflow.Repository
+	}
+	return workflow.Repository + "@" + workflow.Ref
+}
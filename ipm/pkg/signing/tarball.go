@@ -0,0 +1,283 @@
+package signing
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Einträge, die eine Signatur im Tarball ablegt. Weitere Signaturen desselben
+// Inhalts (siehe CosignTarball) stehen in CosignaturesFile.
+const (
+	SignatureFile    = "signature.sig"
+	MetadataFile     = "signature.json"
+	CosignaturesFile = "signature.cosign.json"
+)
+
+// Metadata beschreibt eine Signatur, damit Prüfer den Algorithmus nicht raten müssen.
+// Digest ist der Digest des kanonischen Manifests; fehlt er, wurde wie bei älteren
+// ipm-Versionen über den neu gepackten Tarball signiert. Ist Type gesetzt, sind die
+// Metadaten selbst signiert (siehe PayloadType), sonst nur das Manifest.
+type Metadata struct {
+	Type      string `json:"type,omitempty"`
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyId"`
+	Digest    string `json:"digest,omitempty"`
+	Package   string `json:"package,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Signer    string `json:"signer,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"` // RFC 3339
+
+	signed bool // Metadaten von der geprüften Signatur abgedeckt
+}
+
+// SignedAt liefert den Zeitpunkt, zu dem die Gültigkeit des Schlüssels geprüft
+// wird: Timestamp, sofern die geprüfte Signatur die Metadaten abdeckt, sonst die
+// aktuelle Zeit, denn ein unsignierter Zeitpunkt ließe sich zurückdatieren.
+func (m *Metadata) SignedAt() time.Time {
+	if m.signed {
+		if at, err := time.Parse(time.RFC3339, m.Timestamp); err == nil {
+			return at
+		}
+	}
+	return time.Now()
+}
+
+// String beschreibt, wer wann mit welchem Schlüssel signiert hat.
+func (m *Metadata) String() string {
+	var parts []string
+	if m.Signer != "" {
+		parts = append(parts, "signed by "+m.Signer)
+	} else {
+		parts = append(parts, "signed")
+	}
+	if m.KeyID != "" {
+		parts = append(parts, fmt.Sprintf("with key %s (%s)", m.KeyID, m.Algorithm))
+	} else {
+		parts = append(parts, fmt.Sprintf("(%s, no key ID)", m.Algorithm))
+	}
+	if m.Timestamp != "" {
+		parts = append(parts, "at "+m.Timestamp)
+	}
+	return strings.Join(parts, " ")
+}
+
+// SignOptions steuert das Signieren. Ist Algorithm leer, gilt der Standard für den
+// Schlüsseltyp; ist Time null, die aktuelle Zeit.
+type SignOptions struct {
+	Algorithm string
+	Signer    string
+	Time      time.Time
+}
+
+// newStatement erstellt die zu signierenden Metadaten eines Tarballs.
+func newStatement(tgz []byte, pub crypto.PublicKey, opts SignOptions) (*Metadata, error) {
+	algorithm := opts.Algorithm
+	if algorithm == "" {
+		var err error
+		if algorithm, err = DefaultAlgorithm(pub); err != nil {
+			return nil, err
+		}
+	}
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := TarballManifest(tgz)
+	if err != nil {
+		return nil, err
+	}
+	name, version, err := tarballPackage(tgz)
+	if err != nil {
+		return nil, err
+	}
+	at := opts.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return &Metadata{
+		Type:      PayloadType,
+		Algorithm: algorithm,
+		KeyID:     keyID,
+		Digest:    manifest.Digest(),
+		Package:   name,
+		Version:   version,
+		Signer:    opts.Signer,
+		Timestamp: at.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// SignTarball signiert einen gzip-komprimierten Tarball und liefert ihn mit
+// signature.sig und signature.json. Signiert werden die Metadaten samt Digest des
+// kanonischen Manifests. Vorhandene Signaturen werden ersetzt.
+func SignTarball(tgz []byte, key crypto.Signer, opts SignOptions) ([]byte, *Metadata, error) {
+	meta, err := newStatement(tgz, key.Public(), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	metaData, err := json.Marshal(meta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode signature metadata: %v", err)
+	}
+	signature, err := Sign(key, meta.Algorithm, pae(meta.Type, metaData))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign package: %v", err)
+	}
+
+	all, err := readEntries(tgz)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, _, _, _ := splitSignatures(all)
+	entries = append(entries,
+		entry{header: &tar.Header{Name: SignatureFile, Mode: 0644, Size: int64(len(signature))}, data: signature},
+		entry{header: &tar.Header{Name: MetadataFile, Mode: 0644, Size: int64(len(metaData))}, data: metaData},
+	)
+	signed, err := writeEntries(entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signed, meta, nil
+}
+
+// Unsign trennt Signatur, Metadaten und weitere Signaturen vom Tarball und
+// liefert den Tarball so, wie er signiert wurde.
+func Unsign(tgz []byte) ([]byte, []byte, *Metadata, error) {
+	all, err := readEntries(tgz)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	entries, signature, metaData, _ := splitSignatures(all)
+	if signature == nil {
+		return nil, nil, nil, nil
+	}
+
+	meta, err := parseMetadata(metaData)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	unsigned, err := writeEntries(entries)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return unsigned, signature, meta, nil
+}
+
+// splitSignatures trennt die Einträge eines Tarballs in Paketinhalt, Signatur,
+// Metadaten und weitere Signaturen.
+func splitSignatures(all []entry) ([]entry, []byte, []byte, []byte) {
+	var entries []entry
+	var signature, metaData, cosignatures []byte
+	for _, e := range all {
+		switch e.header.Name {
+		case SignatureFile:
+			signature = e.data
+		case MetadataFile:
+			metaData = e.data
+		case CosignaturesFile:
+			cosignatures = e.data
+		default:
+			entries = append(entries, e)
+		}
+	}
+	return entries, signature, metaData, cosignatures
+}
+
+// parseMetadata liest signature.json; ohne Datei gilt der Algorithmus älterer
+// ipm-Versionen.
+func parseMetadata(data []byte) (*Metadata, error) {
+	meta := &Metadata{Algorithm: LegacyAlgorithm}
+	if data == nil {
+		return meta, nil
+	}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", MetadataFile, err)
+	}
+	if meta.Algorithm == "" {
+		return nil, fmt.Errorf("%s names no algorithm", MetadataFile)
+	}
+	return meta, nil
+}
+
+// tarballPackage liest Name und Version aus der package.json eines Tarballs.
+func tarballPackage(tgz []byte) (string, string, error) {
+	entries, err := readEntries(tgz)
+	if err != nil {
+		return "", "", err
+	}
+	for _, e := range entries {
+		if name, ok := packagePath(e.header.Name); !ok || name != "package.json" {
+			continue
+		}
+		var manifest struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal(e.data, &manifest); err != nil {
+			return "", "", fmt.Errorf("invalid package.json in tarball: %v", err)
+		}
+		return manifest.Name, manifest.Version, nil
+	}
+	return "", "", fmt.Errorf("package.json not found in tarball")
+}
+
+type entry struct {
+	header *tar.Header
+	data   []byte
+}
+
+func readEntries(tgz []byte) ([]entry, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(tgz))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip: %v", err)
+	}
+	defer gzr.Close()
+
+	var entries []entry
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tarball: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", hdr.Name, err)
+		}
+		entries = append(entries, entry{header: hdr, data: data})
+	}
+	return entries, nil
+}
+
+// writeEntries schreibt die Einträge als gzip-komprimierten Tarball. Gleiche
+// Einträge ergeben mit derselben gzip-Implementierung dieselben Bytes; darauf
+// beruht nur noch die Prüfung von Signaturen ohne Digest.
+func writeEntries(entries []entry) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		if err := tw.WriteHeader(e.header); err != nil {
+			return nil, fmt.Errorf("failed to write header: %v", err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", e.header.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish tarball: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish gzip: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,297 @@
+package signing
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// VerifyPackage prüft die Signatur eines Tarballs gegen die vertrauenswürdigen
+// Schlüssel keys. Eine abgelöste Signatur (detached) hat Vorrang vor der
+// eingebetteten. Ein Tarball ohne Signatur ergibt (nil, nil). Bei mehreren
+// Signaturen liefert es die erste gültige, siehe VerifyPackageAll.
+func VerifyPackage(tgz, detached []byte, keys []crypto.PublicKey) (*Metadata, error) {
+	return first(VerifyPackageAll(tgz, detached, keys))
+}
+
+// VerifyPackageAll prüft wie VerifyPackage alle Signaturen eines Tarballs und
+// liefert die gültigen. Signaturen von Schlüsseln außerhalb von keys zählen nicht;
+// eine ungültige Signatur eines vertrauenswürdigen Schlüssels ist ein Fehler, ebenso
+// wenn keine Signatur von einem vertrauenswürdigen Schlüssel stammt.
+func VerifyPackageAll(tgz, detached []byte, keys []crypto.PublicKey) ([]*Metadata, error) {
+	if detached == nil {
+		return verifyTarball(tgz, keys)
+	}
+	manifest, err := TarballManifest(tgz)
+	if err != nil {
+		return nil, err
+	}
+	return verifyDetached(manifest, detached, keys)
+}
+
+// VerifyTarball prüft die eingebettete Signatur eines Tarballs mit pub. Ein Tarball
+// ohne Signatur ergibt (nil, nil); ohne signature.json gilt der Algorithmus älterer
+// ipm-Versionen.
+func VerifyTarball(tgz []byte, pub crypto.PublicKey) (*Metadata, error) {
+	return first(verifyTarball(tgz, []crypto.PublicKey{pub}))
+}
+
+// VerifyDetached prüft eine abgelöste Signatur (JSON oder DSSE) eines Tarballs mit pub.
+func VerifyDetached(tgz, envelope []byte, pub crypto.PublicKey) (*Metadata, error) {
+	return VerifyPackage(tgz, envelope, []crypto.PublicKey{pub})
+}
+
+// VerifyDir prüft die Signatur eines entpackten Pakets, etwa im Cache: die
+// abgelöste Signatur detached oder sonst signature.sig und signature.json in
+// SignatureDir(dir). Ein Paket ohne Signatur ergibt (nil, nil). Signaturen älterer
+// ipm-Versionen lassen sich ohne Tarball nicht prüfen.
+func VerifyDir(dir string, detached []byte, keys []crypto.PublicKey) (*Metadata, error) {
+	manifest, err := DirManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyManifest(manifest, dir, detached, keys)
+}
+
+// VerifyManifest prüft wie VerifyDir, aber gegen ein bereits berechnetes oder
+// bei der Installation festgehaltenes Manifest des Pakets in dir.
+func VerifyManifest(manifest Manifest, dir string, detached []byte, keys []crypto.PublicKey) (*Metadata, error) {
+	return first(VerifyManifestAll(manifest, dir, detached, keys))
+}
+
+// VerifyManifestAll prüft wie VerifyManifest alle Signaturen des Pakets in dir,
+// einschließlich signature.cosign.json in SignatureDir(dir), und liefert die gültigen (siehe
+// VerifyPackageAll).
+func VerifyManifestAll(manifest Manifest, dir string, detached []byte, keys []crypto.PublicKey) ([]*Metadata, error) {
+	if detached != nil {
+		return verifyDetached(manifest, detached, keys)
+	}
+	sigDir := SignatureDir(dir)
+	signature, err := os.ReadFile(filepath.Join(sigDir, SignatureFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %v", err)
+	}
+	metaData, err := os.ReadFile(filepath.Join(sigDir, MetadataFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", MetadataFile, err)
+	}
+	meta, err := parseMetadata(metaData)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Digest == "" {
+		return nil, fmt.Errorf("package was signed without a content digest; verify the tarball instead")
+	}
+	cosignData, err := os.ReadFile(filepath.Join(sigDir, CosignaturesFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", CosignaturesFile, err)
+	}
+	candidates, err := embeddedCandidates(manifest, meta, signature, cosignData)
+	if err != nil {
+		return nil, err
+	}
+	return verifyCandidates(candidates, keys)
+}
+
+func verifyTarball(tgz []byte, keys []crypto.PublicKey) ([]*Metadata, error) {
+	all, err := readEntries(tgz)
+	if err != nil {
+		return nil, err
+	}
+	entries, signature, metaData, cosignData := splitSignatures(all)
+	if signature == nil {
+		return nil, nil
+	}
+	meta, err := parseMetadata(metaData)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Digest == "" {
+		unsigned, err := writeEntries(entries)
+		if err != nil {
+			return nil, err
+		}
+		return verifyCandidates([]candidate{{meta: meta, verify: func(pub crypto.PublicKey) error {
+			if err := Verify(pub, meta.Algorithm, unsigned, signature); err != nil {
+				return fmt.Errorf("package signature verification failed: %v", err)
+			}
+			return nil
+		}}}, keys)
+	}
+	manifest, err := TarballManifest(tgz)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := embeddedCandidates(manifest, meta, signature, cosignData)
+	if err != nil {
+		return nil, err
+	}
+	return verifyCandidates(candidates, keys)
+}
+
+// verifyDetached prüft alle Signaturen einer abgelösten Signatur.
+func verifyDetached(manifest Manifest, envelope []byte, keys []crypto.PublicKey) ([]*Metadata, error) {
+	parsed, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+	message := pae(PayloadType, parsed.payload)
+	var candidates []candidate
+	for _, s := range parsed.signatures {
+		s := s
+		meta := parsed.meta
+		if s.KeyID != meta.KeyID {
+			// Weitere DSSE-Signatur über dieselbe Nutzlast, siehe CosignDetached
+			meta = cosignerMetadata(parsed.meta, s.KeyID)
+		}
+		candidates = append(candidates, candidate{meta: meta, signed: true, verify: func(pub crypto.PublicKey) error {
+			if meta != parsed.meta {
+				algorithm, err := DefaultAlgorithm(pub)
+				if err != nil {
+					return err
+				}
+				meta.Algorithm = algorithm
+			}
+			return verifyMessage(pub, meta, manifest, message, s.Sig)
+		}})
+	}
+	for idx := range parsed.cosignatures {
+		candidates = append(candidates, cosignatureCandidate(manifest, &parsed.cosignatures[idx]))
+	}
+	return verifyCandidates(candidates, keys)
+}
+
+// candidate ist eine einzelne Signatur eines Pakets; verify prüft sie mit einem
+// Schlüssel. signed meldet, ob die Signatur auch meta abdeckt.
+type candidate struct {
+	meta   *Metadata
+	signed bool
+	verify func(crypto.PublicKey) error
+}
+
+// embeddedCandidates liefert die eingebettete Signatur und die weiteren aus
+// signature.cosign.json.
+func embeddedCandidates(manifest Manifest, meta *Metadata, signature, cosignData []byte) ([]candidate, error) {
+	cosignatures, err := parseCosignatures(cosignData)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []candidate{{meta: meta, signed: meta.Type != "", verify: func(pub crypto.PublicKey) error {
+		return verifyEmbedded(pub, meta, manifest, signature)
+	}}}
+	for idx := range cosignatures {
+		candidates = append(candidates, cosignatureCandidate(manifest, &cosignatures[idx]))
+	}
+	return candidates, nil
+}
+
+func cosignatureCandidate(manifest Manifest, c *Envelope) candidate {
+	return candidate{meta: &c.Metadata, signed: c.Metadata.Type != "", verify: func(pub crypto.PublicKey) error {
+		return verifyEmbedded(pub, &c.Metadata, manifest, c.Signature)
+	}}
+}
+
+// verifyCandidates prüft jede Signatur mit dem passenden Schlüssel aus keys und
+// liefert die gültigen. Signaturen anderer Schlüssel werden übergangen; stammt
+// keine von einem vertrauenswürdigen Schlüssel, ist das der Fehler. KeyID der
+// gelieferten Metadaten ist die des Schlüssels, der die Signatur bestätigt hat,
+// nicht die angegebene, die bei älteren Formaten fehlen kann.
+func verifyCandidates(candidates []candidate, keys []crypto.PublicKey) ([]*Metadata, error) {
+	var verified []*Metadata
+	var untrusted error
+	for _, c := range candidates {
+		pubs, err := keysFor(keys, c.meta.KeyID)
+		if err != nil {
+			if untrusted == nil {
+				untrusted = err
+			}
+			continue
+		}
+		pub, err := tryKeys(pubs, c.verify)
+		if err != nil {
+			return nil, err
+		}
+		keyID, err := KeyID(pub)
+		if err != nil {
+			return nil, err
+		}
+		meta := *c.meta
+		meta.KeyID, meta.signed = keyID, c.signed
+		verified = append(verified, &meta)
+	}
+	if len(verified) == 0 {
+		return nil, untrusted
+	}
+	return verified, nil
+}
+
+// first liefert die erste gültige Signatur für die Funktionen mit einer Signatur.
+func first(verified []*Metadata, err error) (*Metadata, error) {
+	if err != nil || len(verified) == 0 {
+		return nil, err
+	}
+	return verified[0], nil
+}
+
+// verifyEmbedded prüft eine eingebettete Signatur mit Digest: über die Metadaten,
+// wenn Type gesetzt ist, sonst über das Manifest.
+func verifyEmbedded(pub crypto.PublicKey, meta *Metadata, manifest Manifest, signature []byte) error {
+	message := []byte(manifest)
+	if meta.Type != "" {
+		if meta.Type != PayloadType {
+			return fmt.Errorf("unsupported signature type %q", meta.Type)
+		}
+		payload, err := json.Marshal(meta)
+		if err != nil {
+			return fmt.Errorf("failed to encode signature metadata: %v", err)
+		}
+		message = pae(meta.Type, payload)
+	}
+	return verifyMessage(pub, meta, manifest, message, signature)
+}
+
+// verifyMessage prüft die Signatur über message. Zuerst wird der Digest mit dem
+// Manifest verglichen, damit veränderte Inhalte als solche erkannt werden.
+func verifyMessage(pub crypto.PublicKey, meta *Metadata, manifest Manifest, message, signature []byte) error {
+	if digest := manifest.Digest(); digest != meta.Digest {
+		return fmt.Errorf("package contents do not match the signed digest (%s, signed %s)", digest, meta.Digest)
+	}
+	if err := Verify(pub, meta.Algorithm, message, signature); err != nil {
+		return fmt.Errorf("package signature verification failed: %v", err)
+	}
+	return nil
+}
+
+// keysFor wählt unter keys den Schlüssel mit der ID keyID. Ohne ID (ältere
+// ipm-Versionen) kommen alle Schlüssel in Frage.
+func keysFor(keys []crypto.PublicKey, keyID string) ([]crypto.PublicKey, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no trusted keys to verify the package signature")
+	}
+	if keyID == "" {
+		return keys, nil
+	}
+	for _, pub := range keys {
+		if id, err := KeyID(pub); err == nil && id == keyID {
+			return []crypto.PublicKey{pub}, nil
+		}
+	}
+	return nil, fmt.Errorf("package is signed with key %s, which is not trusted", keyID)
+}
+
+// tryKeys liefert den ersten Schlüssel, für den verify gelingt, sonst den
+// letzten Fehler.
+func tryKeys(keys []crypto.PublicKey, verify func(crypto.PublicKey) error) (crypto.PublicKey, error) {
+	var err error
+	for _, pub := range keys {
+		if err = verify(pub); err == nil {
+			return pub, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,148 @@
+package signing
+
+import (
+	"archive/tar"
+	"crypto"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// testEntries sind die Einträge eines kleinen Pakets.
+func testEntries() []entry {
+	files := map[string]string{
+		"package/package.json": `{"name":"demo","version":"1.0.0"}`,
+		"package/index.js":     "module.exports = 1\n",
+	}
+	var entries []entry
+	for _, name := range []string{"package/package.json", "package/index.js"} {
+		data := []byte(files[name])
+		entries = append(entries, entry{header: &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}, data: data})
+	}
+	return entries
+}
+
+func testTarball(t *testing.T) []byte {
+	t.Helper()
+	tgz, err := writeEntries(testEntries())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tgz
+}
+
+func testKey(t *testing.T, keyType string) crypto.Signer {
+	t.Helper()
+	key, err := GenerateKey(keyType, 2048, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// withSignature hängt Signatur und, falls meta gesetzt ist, signature.json an.
+func withSignature(t *testing.T, entries []entry, signature []byte, meta *Metadata) []byte {
+	t.Helper()
+	entries = append(entries, entry{header: &tar.Header{Name: SignatureFile, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(signature))}, data: signature})
+	if meta != nil {
+		data, err := json.Marshal(meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry{header: &tar.Header{Name: MetadataFile, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}, data: data})
+	}
+	tgz, err := writeEntries(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tgz
+}
+
+// legacySigned signiert wie ältere ipm-Versionen den Tarball ohne signature.json.
+func legacySigned(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+	signature, err := Sign(key, LegacyAlgorithm, testTarball(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return withSignature(t, testEntries(), signature, nil)
+}
+
+// manifestSigned signiert nur das Manifest; signature.json nennt weder Typ noch
+// Schlüssel, der Zeitstempel ist unsigniert.
+func manifestSigned(t *testing.T, key crypto.Signer, timestamp string) []byte {
+	t.Helper()
+	manifest, err := TarballManifest(testTarball(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	algorithm, err := DefaultAlgorithm(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := Sign(key, algorithm, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &Metadata{Algorithm: algorithm, Digest: manifest.Digest(), Timestamp: timestamp}
+	return withSignature(t, testEntries(), signature, meta)
+}
+
+func TestVerifyDerivesKeyID(t *testing.T) {
+	rsaKey := testKey(t, KeyTypeRSA)
+	edKey := testKey(t, KeyTypeEd25519)
+	other := testKey(t, KeyTypeEd25519)
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		tgz  []byte
+	}{
+		{"legacy", rsaKey, legacySigned(t, rsaKey)},
+		{"manifest only", edKey, manifestSigned(t, edKey, "2001-01-01T00:00:00Z")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := KeyID(tt.key.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Ohne Schlüssel-ID kommen alle Schlüssel in Frage; gemeldet wird der passende
+			metas, err := VerifyPackageAll(tt.tgz, nil, []crypto.PublicKey{other.Public(), tt.key.Public()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(metas) != 1 || metas[0].KeyID != want {
+				t.Fatalf("VerifyPackageAll() = %+v, want key %s", metas, want)
+			}
+			if _, err := VerifyPackageAll(tt.tgz, nil, []crypto.PublicKey{other.Public()}); err == nil {
+				t.Error("signature verified with an unrelated key")
+			}
+		})
+	}
+}
+
+func TestSignedAt(t *testing.T) {
+	key := testKey(t, KeyTypeEd25519)
+	at := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	signed, _, err := SignTarball(testTarball(t), key, SignOptions{Time: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metas, err := VerifyPackageAll(signed, nil, []crypto.PublicKey{key.Public()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := metas[0].SignedAt(); !got.Equal(at) {
+		t.Errorf("SignedAt() of signed metadata = %s, want %s", got, at)
+	}
+
+	// Ein unsignierter Zeitstempel zählt nicht, sonst ließe er sich zurückdatieren
+	metas, err = VerifyPackageAll(manifestSigned(t, key, at.Format(time.RFC3339)), nil, []crypto.PublicKey{key.Public()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := metas[0].SignedAt(); time.Since(got) > time.Minute {
+		t.Errorf("SignedAt() of unsigned metadata = %s, want the current time", got)
+	}
+}
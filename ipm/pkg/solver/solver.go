@@ -3,30 +3,76 @@ package solver
 import (
 	"fmt"
 	"ipm/pkg/log"
+	"ipm/pkg/overrides"
+	"ipm/pkg/platform"
 	"ipm/pkg/registry"
+	"ipm/pkg/semver"
+	"ipm/pkg/source"
+	"ipm/pkg/types"
+	"sort"
+	"strings"
 )
 
 type DependencyNode struct {
-	Name    string
-	Version string
-	Deps    map[string]string
+	Name         string
+	Version      string
+	Deps         map[string]string
+	PeerDeps     map[string]string
+	PeerDepsMeta map[string]types.PeerDependencyMeta
+	OptionalDeps map[string]string
+	Source       *source.Resolved // nil für Pakete aus der Standard-Registry
 }
 
 type Solver struct {
+	// IncludePrerelease muss zur Einstellung der Registry passen (--include-prerelease)
+	IncludePrerelease bool
+	// Overrides ersetzen Versionsangaben, bevor sie aufgelöst werden
+	Overrides *overrides.Set
+	// Sources lädt Angaben, die nicht auf die Standard-Registry verweisen (npm:, URL, file:, link:)
+	Sources *source.Sources
+	// Workspace enthält die Mitglieder eines Workspaces nach Name; passende Angaben werden auf sie verlinkt
+	Workspace map[string]*source.Resolved
+
 	reg           registry.Registry
 	nodes         map[string]*DependencyNode
 	conflicts     []Conflict
 	resolvedCache map[string]string // name:versionRange → resolvedVersion
+	skipped       []SkippedDependency
+	optionalDepth int                  // > 0, solange ein optionaler Teilbaum aufgelöst wird
+	path          []overrides.Ancestor // Vorfahren der gerade aufgelösten Kante
+	base          string               // Verzeichnis der package.json der gerade aufgelösten Kante, leer für das Projekt
+	overridden    []OverriddenEdge
+}
+
+// OverriddenEdge ist eine Abhängigkeitskante, deren Versionsangabe durch einen Override ersetzt wurde.
+type OverriddenEdge struct {
+	Parent overrides.Ancestor // leer für Pakete, die direkt installiert werden
+	Name   string
+	From   string
+	To     string
+	Rule   string
+}
+
+// SkippedDependency beschreibt eine optionalDependency, die nicht aufgelöst werden konnte.
+type SkippedDependency struct {
+	Name       string
+	Range      string
+	RequiredBy string
+	Reason     string
 }
 
 type Conflict struct {
 	Package    string
 	Versions   []string
 	Dependents []string
+	// Peer kennzeichnet eine unerfüllte peerDependency: Versions enthält dann die
+	// aufgelöste Version und den vom Dependent erwarteten Range.
+	Peer bool
 }
 
 func NewSolver(reg registry.Registry) *Solver {
 	return &Solver{
+		Sources:       source.New(reg),
 		reg:           reg,
 		nodes:         make(map[string]*DependencyNode),
 		resolvedCache: make(map[string]string),
@@ -34,6 +80,41 @@ func NewSolver(reg registry.Registry) *Solver {
 }
 
 func (s *Solver) AddPackage(name, versionRange string) error {
+	if member, ok := s.Workspace[name]; ok && s.usesMember(member, versionRange) {
+		return s.addNode(name, member.Package.Version, member)
+	}
+
+	base := s.base
+	rule, err := s.Overrides.Match(s.path, name, func() (string, error) {
+		return s.resolveVersion(name, versionRange)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply overrides to %s@%s: %v", name, versionRange, err)
+	}
+	if rule != nil && rule.Spec != versionRange {
+		edge := OverriddenEdge{Name: name, From: versionRange, To: rule.Spec, Rule: rule.Source}
+		if len(s.path) > 0 {
+			edge.Parent = s.path[len(s.path)-1]
+		}
+		s.overridden = append(s.overridden, edge)
+		log.Info("Applying dependency override", map[string]interface{}{
+			"package": name,
+			"from":    versionRange,
+			"to":      rule.Spec,
+			"rule":    rule.Source,
+		})
+		versionRange = rule.Spec
+		base = "" // Overrides stehen in der package.json des Projekts
+	}
+
+	if !source.IsRegistry(versionRange) {
+		res, err := s.Sources.Resolve(source.ParseIn(base, name, versionRange))
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s@%s: %v", name, versionRange, err)
+		}
+		return s.addNode(name, res.Package.Version, res)
+	}
+
 	cacheKey := fmt.Sprintf("%s@%s", name, versionRange)
 	if cachedVersion, ok := s.resolvedCache[cacheKey]; ok {
 		log.Debug("Using cached resolved version", map[string]interface{}{
@@ -41,7 +122,19 @@ func (s *Solver) AddPackage(name, versionRange string) error {
 			"range":   versionRange,
 			"version": cachedVersion,
 		})
-		return s.addNode(name, cachedVersion)
+		return s.addNode(name, cachedVersion, s.sourceOf(name, cachedVersion))
+	}
+
+	// Eine bereits gewählte Version wiederverwenden, wenn sie den Range erfüllt,
+	// statt eine zweite Version desselben Pakets einzuführen
+	if existing := s.satisfyingNode(name, versionRange); existing != "" {
+		log.Debug("Reusing already resolved version", map[string]interface{}{
+			"package": name,
+			"range":   versionRange,
+			"version": existing,
+		})
+		s.resolvedCache[cacheKey] = existing
+		return s.addNode(name, existing, s.sourceOf(name, existing))
 	}
 
 	version, err := s.reg.ResolveVersion(name, versionRange)
@@ -50,49 +143,349 @@ func (s *Solver) AddPackage(name, versionRange string) error {
 	}
 
 	s.resolvedCache[cacheKey] = version
-	return s.addNode(name, version)
+	return s.addNode(name, version, nil)
 }
 
-func (s *Solver) addNode(name, version string) error {
-	key := fmt.Sprintf("%s@%s", name, version)
+// usesMember meldet, ob eine Angabe auf das Workspace-Mitglied verweist: entweder
+// direkt oder über einen Range, den dessen Version erfüllt. Sonst gilt wie bei npm die Registry.
+func (s *Solver) usesMember(member *source.Resolved, versionRange string) bool {
+	if versionRange == member.Spec.Raw {
+		return true
+	}
+	return semver.SatisfiesWithOptions(member.Package.Version, versionRange, semver.Options{IncludePrerelease: s.IncludePrerelease})
+}
+
+// resolveVersion liefert die Version, auf die eine Angabe beliebiger Quellart auflöst.
+func (s *Solver) resolveVersion(name, versionRange string) (string, error) {
+	if source.IsRegistry(versionRange) {
+		return s.reg.ResolveVersion(name, versionRange)
+	}
+	res, err := s.Sources.Resolve(source.ParseIn(s.base, name, versionRange))
+	if err != nil {
+		return "", err
+	}
+	return res.Package.Version, nil
+}
+
+// satisfyingNode sucht eine bereits gewählte Version, die den Range erfüllt. Aliase
+// kommen nicht in Frage, da sie unter dem Namen ein anderes Paket installieren.
+func (s *Solver) satisfyingNode(name, versionRange string) string {
+	constraint, err := semver.ParseRangeWithOptions(versionRange, semver.Options{IncludePrerelease: s.IncludePrerelease})
+	if err != nil {
+		return ""
+	}
+	var versions []string
+	for _, node := range s.nodes {
+		if node.Name == name && (node.Source == nil || node.Source.Package.Name == name) {
+			versions = append(versions, node.Version)
+		}
+	}
+	return constraint.MaxSatisfying(versions)
+}
+
+// sourceOf liefert die Quelle des Knotens name@version, nil für Registry-Pakete.
+func (s *Solver) sourceOf(name, version string) *source.Resolved {
+	for _, node := range s.nodes {
+		if node.Name == name && node.Version == version && node.Source != nil {
+			return node.Source
+		}
+	}
+	return nil
+}
+
+// nodeKey identifiziert einen Knoten; Pakete anderer Quellen werden über ihre feste Quelle unterschieden.
+func nodeKey(name, version string, res *source.Resolved) string {
+	if res != nil {
+		return fmt.Sprintf("%s@%s", name, res.Resolved)
+	}
+	return fmt.Sprintf("%s@%s", name, version)
+}
+
+// dirOf liefert das Verzeichnis eines Pakets aus file: oder link:, gegen das
+// dessen eigene Angaben aufgelöst werden; sonst leer.
+func dirOf(res *source.Resolved) string {
+	if res == nil {
+		return ""
+	}
+	return res.Dir
+}
+
+func resolvedOf(res *source.Resolved) string {
+	if res == nil {
+		return ""
+	}
+	return res.Resolved
+}
+
+func (s *Solver) addNode(name, version string, res *source.Resolved) error {
+	key := nodeKey(name, version, res)
 	if _, ok := s.nodes[key]; ok {
 		return nil
 	}
 
-	_, pkg, err := s.reg.FetchPackageTarball(name, version)
-	if err != nil {
-		return fmt.Errorf("failed to fetch %s@%s: %v", name, version, err)
+	var pkg types.Package
+	if res != nil {
+		pkg = res.Package
+	} else {
+		_, fetched, err := s.reg.FetchPackageTarball(name, version)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s@%s: %v", name, version, err)
+		}
+		pkg = fetched
+	}
+
+	// Innerhalb optionaler Teilbäume führen os/cpu/engines-Abweichungen zum Überspringen
+	if s.optionalDepth > 0 {
+		if err := platform.Check(pkg); err != nil {
+			return err
+		}
+		if err := platform.CheckEngines(pkg); err != nil {
+			return err
+		}
 	}
 
 	for existingKey, node := range s.nodes {
-		if node.Name == name && node.Version != version {
+		if node.Name == name && (node.Version != version || resolvedOf(node.Source) != resolvedOf(res)) {
 			s.conflicts = append(s.conflicts, Conflict{
 				Package:    name,
-				Versions:   []string{node.Version, version},
+				Versions:   []string{strings.TrimPrefix(existingKey, name+"@"), strings.TrimPrefix(key, name+"@")},
 				Dependents: []string{existingKey, key},
 			})
 		}
 	}
 
+	// Verlinkte Pakete (link:) verwalten ihre Abhängigkeiten selbst
+	if res != nil && res.Spec.Kind == source.Link {
+		s.nodes[key] = &DependencyNode{Name: name, Version: version, Source: res}
+		return nil
+	}
 	s.nodes[key] = &DependencyNode{
-		Name:    name,
-		Version: version,
-		Deps:    pkg.Deps,
+		Name:         name,
+		Version:      version,
+		Deps:         pkg.Deps,
+		PeerDeps:     pkg.PeerDeps,
+		PeerDepsMeta: pkg.PeerDepsMeta,
+		OptionalDeps: pkg.OptionalDeps,
+		Source:       res,
 	}
 
-	for depName, depVersion := range pkg.Deps {
-		if err := s.AddPackage(depName, depVersion); err != nil {
+	s.path = append(s.path, overrides.Ancestor{Name: name, Version: version})
+	parentBase := s.base
+	s.base = dirOf(res)
+	defer func() { s.path, s.base = s.path[:len(s.path)-1], parentBase }()
+
+	// Sortiert, damit die Auflösung und die Anwendung pfadabhängiger Overrides deterministisch sind
+	for _, depName := range sortedKeys(pkg.Deps) {
+		// npm führt optionale Abhängigkeiten zusätzlich unter "dependencies" auf
+		if _, optional := pkg.OptionalDeps[depName]; optional {
+			continue
+		}
+		if err := s.AddPackage(depName, pkg.Deps[depName]); err != nil {
 			return err
 		}
 	}
 
+	for _, depName := range sortedKeys(pkg.OptionalDeps) {
+		s.AddOptional(key, depName, pkg.OptionalDeps[depName])
+	}
+
+	return nil
+}
+
+// AddOptional löst eine optionalDependency von parent auf. Schlägt das fehl, werden
+// alle dabei hinzugefügten Knoten und Konflikte verworfen und die Abhängigkeit übersprungen.
+func (s *Solver) AddOptional(parent, name, versionRange string) {
+	before := make(map[string]bool, len(s.nodes)+len(s.resolvedCache))
+	for key := range s.nodes {
+		before[key] = true
+	}
+	for key := range s.resolvedCache {
+		before[key] = true
+	}
+	conflicts := len(s.conflicts)
+	overridden := len(s.overridden)
+
+	s.optionalDepth++
+	err := s.AddPackage(name, versionRange)
+	s.optionalDepth--
+	if err == nil {
+		return
+	}
+
+	for key := range s.nodes {
+		if !before[key] {
+			delete(s.nodes, key)
+		}
+	}
+	for key := range s.resolvedCache {
+		if !before[key] {
+			delete(s.resolvedCache, key)
+		}
+	}
+	s.conflicts = s.conflicts[:conflicts]
+	s.overridden = s.overridden[:overridden]
+	s.skipped = append(s.skipped, SkippedDependency{
+		Name:       name,
+		Range:      versionRange,
+		RequiredBy: parent,
+		Reason:     err.Error(),
+	})
+	log.Warn("Skipping optional dependency", map[string]interface{}{
+		"package":    name,
+		"range":      versionRange,
+		"requiredBy": parent,
+		"reason":     err.Error(),
+	})
+}
+
+// Forget verwirft die Knoten und zwischengespeicherten Auflösungen von name, etwa
+// wenn die Installation eines optionalen Teilbaums scheitert. Eine spätere Angabe
+// wird dann neu aufgelöst.
+func (s *Solver) Forget(name string) {
+	for key, node := range s.nodes {
+		if node.Name == name {
+			delete(s.nodes, key)
+		}
+	}
+	for key := range s.resolvedCache {
+		if strings.HasPrefix(key, name+"@") {
+			delete(s.resolvedCache, key)
+		}
+	}
+}
+
+// Overridden liefert alle Kanten, auf die ein Override angewendet wurde.
+func (s *Solver) Overridden() []OverriddenEdge {
+	return s.overridden
+}
+
+// Skipped liefert die übersprungenen optionalDependencies.
+func (s *Solver) Skipped() []SkippedDependency {
+	return s.skipped
+}
+
+// ResolvePeers prüft die peerDependencies aller Knoten gegen die eine aufgelöste
+// Version des jeweiligen Pakets. Fehlende, nicht optionale Peers werden
+// nachgezogen; unerfüllte Peers werden als Konflikt gemeldet.
+func (s *Solver) ResolvePeers() error {
+	// Fehlende Peers hinzufügen, bis keine neuen Knoten mehr entstehen
+	for {
+		added := false
+		for _, key := range s.sortedNodeKeys() {
+			node := s.nodes[key]
+			for _, peer := range sortedKeys(node.PeerDeps) {
+				if len(s.versionsOf(peer)) > 0 {
+					continue
+				}
+				peerRange := node.PeerDeps[peer]
+				if node.PeerDepsMeta[peer].Optional {
+					log.Debug("Skipping missing optional peer dependency", map[string]interface{}{
+						"package": key,
+						"peer":    peer,
+						"range":   peerRange,
+					})
+					continue
+				}
+				log.Info("Adding missing peer dependency", map[string]interface{}{
+					"package": key,
+					"peer":    peer,
+					"range":   peerRange,
+				})
+				s.base = dirOf(node.Source)
+				err := s.AddPackage(peer, peerRange)
+				s.base = ""
+				if err != nil {
+					return fmt.Errorf("failed to resolve peer dependency %s@%s of %s: %v", peer, peerRange, key, err)
+				}
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	for _, key := range s.sortedNodeKeys() {
+		node := s.nodes[key]
+		for _, peer := range sortedKeys(node.PeerDeps) {
+			peerRange := node.PeerDeps[peer]
+			constraint, err := semver.ParseRangeWithOptions(peerRange, semver.Options{IncludePrerelease: s.IncludePrerelease})
+			if err != nil {
+				return fmt.Errorf("invalid peer dependency range %s@%s in %s: %v", peer, peerRange, key, err)
+			}
+			for _, version := range s.versionsOf(peer) {
+				v, err := semver.Parse(version)
+				if err == nil && constraint.Test(v) {
+					continue
+				}
+				s.conflicts = append(s.conflicts, Conflict{
+					Package:    peer,
+					Versions:   []string{version, peerRange},
+					Dependents: []string{fmt.Sprintf("%s@%s", peer, version), key},
+					Peer:       true,
+				})
+			}
+		}
+	}
 	return nil
 }
 
+// ResolvedVersion liefert die aufgelöste Version eines Pakets, sofern es genau eine gibt.
+func (s *Solver) ResolvedVersion(name string) (string, bool) {
+	versions := s.versionsOf(name)
+	if len(versions) != 1 {
+		return "", false
+	}
+	return versions[0], true
+}
+
+// ResolvedSource liefert die feste Quelle eines Pakets, sofern es genau einmal und
+// nicht aus der Standard-Registry aufgelöst wurde.
+func (s *Solver) ResolvedSource(name string) (*source.Resolved, bool) {
+	if _, ok := s.ResolvedVersion(name); !ok {
+		return nil, false
+	}
+	for _, node := range s.nodes {
+		if node.Name == name && node.Source != nil {
+			return node.Source, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Solver) versionsOf(name string) []string {
+	var versions []string
+	for _, node := range s.nodes {
+		if node.Name == name {
+			versions = append(versions, node.Version)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func (s *Solver) sortedNodeKeys() []string {
+	keys := make([]string, 0, len(s.nodes))
+	for key := range s.nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (s *Solver) HasConflicts() bool {
 	return len(s.conflicts) > 0
 }
 
 func (s *Solver) GetConflicts() []Conflict {
 	return s.conflicts
-}
\ No newline at end of file
+}
@@ -0,0 +1,133 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"ipm/pkg/log"
+	"ipm/pkg/registry"
+)
+
+// aliasFetcher lädt "npm:other@^1" als Paket other aus der Registry.
+type aliasFetcher struct {
+	reg registry.Registry
+}
+
+func (f *aliasFetcher) Fetch(spec Spec) (*Resolved, error) {
+	version, err := f.reg.ResolveVersion(spec.Target, spec.Range)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve alias %s: %v", spec.Raw, err)
+	}
+	tarball, pkg, err := f.reg.FetchPackageTarball(spec.Target, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alias %s: %v", spec.Raw, err)
+	}
+	defer tarball.Close()
+	data, err := io.ReadAll(tarball)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tarball of %s@%s: %v", spec.Target, version, err)
+	}
+	return &Resolved{
+		Spec:      spec,
+		Package:   pkg,
+		Resolved:  fmt.Sprintf("npm:%s@%s", pkg.Name, pkg.Version),
+		Integrity: Integrity(data),
+		tarball:   data,
+	}, nil
+}
+
+// tarballFetcher lädt einen Tarball von einer beliebigen URL.
+type tarballFetcher struct {
+	client *http.Client
+}
+
+func (f *tarballFetcher) Fetch(spec Spec) (*Resolved, error) {
+	log.Debug("Downloading tarball", map[string]interface{}{
+		"url": spec.Location,
+	})
+	resp, err := f.client.Get(spec.Location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", spec.Location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tarball request for %s failed with status: %s", spec.Location, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", spec.Location, err)
+	}
+	pkg, err := ReadTarballManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tarball %s: %v", spec.Location, err)
+	}
+	return &Resolved{
+		Spec:      spec,
+		Package:   pkg,
+		Resolved:  spec.Location,
+		Integrity: Integrity(data),
+		tarball:   data,
+	}, nil
+}
+
+// fileFetcher liest "file:" als lokalen Tarball oder als Verzeichnis. Verzeichnisse
+// werden wie bei npm verlinkt, ihre Abhängigkeiten aber im Projekt installiert.
+type fileFetcher struct{}
+
+func (f *fileFetcher) Fetch(spec Spec) (*Resolved, error) {
+	info, err := os.Stat(spec.Path())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", spec.Raw, err)
+	}
+	if info.IsDir() {
+		return readDirectory(spec, "file:")
+	}
+
+	data, err := os.ReadFile(spec.Path())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", spec.Raw, err)
+	}
+	pkg, err := ReadTarballManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tarball %s: %v", spec.Location, err)
+	}
+	return &Resolved{
+		Spec:      spec,
+		Package:   pkg,
+		Resolved:  "file:" + spec.projectPath(),
+		Integrity: Integrity(data),
+		tarball:   data,
+	}, nil
+}
+
+// linkFetcher verlinkt ein Verzeichnis. Das verlinkte Paket verwaltet seine
+// Abhängigkeiten selbst; sie werden nicht ins Projekt installiert.
+type linkFetcher struct{}
+
+func (f *linkFetcher) Fetch(spec Spec) (*Resolved, error) {
+	return readDirectory(spec, "link:")
+}
+
+func readDirectory(spec Spec, prefix string) (*Resolved, error) {
+	dir, err := filepath.Abs(spec.Path())
+	if err != nil {
+		return nil, fmt.Errorf("invalid path in %s: %v", spec.Raw, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json of %s: %v", spec.Raw, err)
+	}
+	pkg, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package.json in %s: %v", spec.Location, err)
+	}
+	return &Resolved{
+		Spec:     spec,
+		Package:  pkg,
+		Resolved: prefix + spec.projectPath(),
+		Dir:      dir,
+	}, nil
+}
@@ -0,0 +1,189 @@
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"ipm/pkg/cache"
+	"ipm/pkg/log"
+	"ipm/pkg/semver"
+)
+
+// parseGitSpec zerlegt "git+ssh://host/repo.git#ref" bzw. "github:user/repo#semver:^1"
+// in die Repository-URL für git, die Referenz und einen semver-Range für Tags.
+// URLs und Referenzen, die mit "-" beginnen, läse git als Option; sie sind ein Fehler.
+func parseGitSpec(raw string) (repo, ref, semverRange string, err error) {
+	repo, fragment, _ := strings.Cut(raw, "#")
+	switch {
+	case strings.HasPrefix(repo, "github:"):
+		repo = "https://github.com/" + strings.TrimSuffix(strings.TrimPrefix(repo, "github:"), ".git") + ".git"
+	case strings.HasPrefix(repo, "git+"):
+		repo = strings.TrimPrefix(repo, "git+")
+	}
+	if strings.HasPrefix(repo, "-") {
+		return "", "", "", fmt.Errorf("invalid git repository %q in %s", repo, raw)
+	}
+	if strings.HasPrefix(fragment, "semver:") {
+		return repo, "", strings.TrimPrefix(fragment, "semver:"), nil
+	}
+	if strings.HasPrefix(fragment, "-") {
+		return "", "", "", fmt.Errorf("invalid git ref %q in %s", fragment, raw)
+	}
+	return repo, fragment, "", nil
+}
+
+// gitBase liefert die Angabe ohne "#ref"; mit angehängtem Commit bildet sie die feste Quelle.
+func gitBase(raw string) string {
+	base, _, _ := strings.Cut(raw, "#")
+	return base
+}
+
+// gitFetcher klont Repositories als Mirror in den Cache, löst "#ref" bzw.
+// "#semver:" zu einem Commit auf und packt diesen deterministisch mit git archive.
+type gitFetcher struct {
+	fetched map[string]bool // Repositories, die in diesem Lauf bereits aktualisiert wurden
+}
+
+func (f *gitFetcher) Fetch(spec Spec) (*Resolved, error) {
+	dir, err := f.mirror(spec.Location)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := resolveCommit(dir, spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", spec.Raw, err)
+	}
+	data, err := packCommit(dir, commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s at %s: %v", spec.Location, commit, err)
+	}
+	pkg, err := ReadTarballManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package in %s at %s: %v", spec.Location, commit, err)
+	}
+	return &Resolved{
+		Spec:      spec,
+		Package:   pkg,
+		Resolved:  gitBase(spec.Raw) + "#" + commit,
+		Integrity: Integrity(data),
+		tarball:   data,
+	}, nil
+}
+
+// mirror legt das Repository als Mirror im Cache an oder aktualisiert ihn einmal je Lauf.
+func (f *gitFetcher) mirror(repo string) (string, error) {
+	c, err := cache.NewCache()
+	if err != nil {
+		return "", fmt.Errorf("failed to open cache: %v", err)
+	}
+	sum := sha256.Sum256([]byte(repo))
+	dir := filepath.Join(c.CacheDir, "_git", hex.EncodeToString(sum[:8]))
+
+	if _, err := os.Stat(dir); err == nil {
+		if f.fetched[dir] {
+			return dir, nil
+		}
+		log.Debug("Updating git mirror", map[string]interface{}{
+			"repo": repo,
+			"dir":  dir,
+		})
+		if _, err := runGit(dir, "fetch", "--quiet", "--prune", "origin"); err != nil {
+			return "", fmt.Errorf("failed to fetch %s: %v", repo, err)
+		}
+		f.fetched[dir] = true
+		return dir, nil
+	}
+
+	log.Debug("Cloning git repository", map[string]interface{}{
+		"repo": repo,
+		"dir":  dir,
+	})
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create git cache: %v", err)
+	}
+	if _, err := runGit("", "clone", "--quiet", "--mirror", "--", repo, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to clone %s: %v", repo, err)
+	}
+	f.fetched[dir] = true
+	return dir, nil
+}
+
+// resolveCommit löst die Referenz der Angabe zu einem Commit auf. Bei "#semver:"
+// gewinnt der höchste passende Tag, ohne Referenz gilt HEAD.
+func resolveCommit(dir string, spec Spec) (string, error) {
+	ref := spec.Ref
+	if spec.Range != "" {
+		constraint, err := semver.ParseRange(spec.Range)
+		if err != nil {
+			return "", fmt.Errorf("invalid semver range %s: %v", spec.Range, err)
+		}
+		out, err := runGit(dir, "for-each-ref", "--format=%(refname:short)", "refs/tags")
+		if err != nil {
+			return "", err
+		}
+		tag := constraint.MaxSatisfying(strings.Fields(out))
+		if tag == "" {
+			return "", fmt.Errorf("no tag matches semver:%s", spec.Range)
+		}
+		ref = "refs/tags/" + tag
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	commit, err := runGit(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown ref %s", ref)
+	}
+	return commit, nil
+}
+
+// packCommit erzeugt den Tarball eines Commits. git archive setzt Zeitstempel und
+// Reihenfolge aus dem Commit, gzip ohne Namen und Zeit; gleiche Commits ergeben
+// so dieselbe Integrität.
+func packCommit(dir, commit string) ([]byte, error) {
+	cmd := exec.Command("git", "--git-dir", dir, "archive", "--format=tar", "--prefix=package/", commit)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	tarData, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git archive: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var buf bytes.Buffer
+	gw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gw.Write(tarData); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// runGit führt git ohne interaktive Abfragen aus; dir ist das Mirror-Repository oder leer.
+func runGit(dir string, args ...string) (string, error) {
+	command := args[0]
+	if dir != "" {
+		args = append([]string{"--git-dir", dir}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,180 @@
+package source
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ipm/pkg/lockfile"
+	"ipm/pkg/log"
+)
+
+func TestMain(m *testing.M) {
+	// Wie ipm ohne --log-level: keine Logs
+	if err := log.Init("", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// testRepo ist ein lokales Bare-Repository mit den Tags v1.0.0, v1.1.0 und v2.0.0
+// auf main und einem Branch next mit 3.0.0-next.
+type testRepo struct {
+	url     string
+	commits map[string]string // Version → Commit
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=ipm", "GIT_AUTHOR_EMAIL=ipm@example.com", "GIT_AUTHOR_DATE=2024-01-01T00:00:00Z",
+		"GIT_COMMITTER_NAME=ipm", "GIT_COMMITTER_EMAIL=ipm@example.com", "GIT_COMMITTER_DATE=2024-01-01T00:00:00Z",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func newTestRepo(t *testing.T) testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	// Mirrors legt der Fetcher im Cache unter HOME ab
+	t.Setenv("HOME", t.TempDir())
+
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	bare := filepath.Join(root, "demo.git")
+	if err := os.Mkdir(work, 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, work, "init", "--quiet", "--initial-branch=main")
+	repo := testRepo{url: "git+file://" + filepath.ToSlash(bare), commits: map[string]string{}}
+	commit := func(version string) {
+		manifest := `{"name":"demo","version":"` + version + `"}`
+		if err := os.WriteFile(filepath.Join(work, "package.json"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitCmd(t, work, "add", "-A")
+		gitCmd(t, work, "commit", "--quiet", "-m", version)
+		repo.commits[version] = gitCmd(t, work, "rev-parse", "HEAD")
+	}
+	for _, version := range []string{"1.0.0", "1.1.0", "2.0.0"} {
+		commit(version)
+		gitCmd(t, work, "tag", "v"+version)
+	}
+	gitCmd(t, work, "checkout", "--quiet", "-b", "next")
+	commit("3.0.0-next")
+	gitCmd(t, work, "checkout", "--quiet", "main")
+	gitCmd(t, root, "clone", "--quiet", "--bare", work, bare)
+	return repo
+}
+
+func TestGitResolve(t *testing.T) {
+	repo := newTestRepo(t)
+	tests := []struct {
+		fragment string
+		version  string
+	}{
+		{"", "2.0.0"},
+		{"#next", "3.0.0-next"},
+		{"#v1.1.0", "1.1.0"},
+		{"#" + repo.commits["1.0.0"], "1.0.0"},
+		{"#semver:^1", "1.1.0"},
+		{"#semver:~1.0.0", "1.0.0"},
+	}
+	sources := New(nil)
+	for _, tt := range tests {
+		res, err := sources.Resolve(Parse("demo", repo.url+tt.fragment))
+		if err != nil {
+			t.Fatalf("Resolve(%s): %v", tt.fragment, err)
+		}
+		if res.Package.Version != tt.version {
+			t.Errorf("Resolve(%s) = %s, want %s", tt.fragment, res.Package.Version, tt.version)
+		}
+		if want := repo.url + "#" + repo.commits[tt.version]; res.Resolved != want {
+			t.Errorf("Resolve(%s) resolved to %s, want %s", tt.fragment, res.Resolved, want)
+		}
+	}
+	if _, err := sources.Resolve(Parse("demo", repo.url+"#semver:^4")); err == nil {
+		t.Error("Resolve(#semver:^4) succeeded without a matching tag")
+	}
+}
+
+func TestGitPinnedByLockfile(t *testing.T) {
+	repo := newTestRepo(t)
+	first, err := New(nil).Resolve(Parse("demo", repo.url+"#v1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ein gesperrter Commit gilt, solange er den Range erfüllt, auch wenn ein
+	// neuerer Tag passt
+	sources := New(nil)
+	sources.Locked = &lockfile.Lockfile{Packages: map[string]lockfile.Package{
+		"demo": {Version: "1.0.0", Resolved: first.Resolved, Integrity: first.Integrity},
+	}}
+	res, err := sources.Resolve(Parse("demo", repo.url+"#semver:^1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Resolved != first.Resolved {
+		t.Errorf("locked resolve = %s, want %s", res.Resolved, first.Resolved)
+	}
+	res, err = sources.Resolve(Parse("demo", repo.url+"#semver:^2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Package.Version != "2.0.0" {
+		t.Errorf("resolve outside the locked range = %s, want 2.0.0", res.Package.Version)
+	}
+
+	// Die Lockfile pinnt auch den Inhalt
+	sources = New(nil)
+	sources.Locked = &lockfile.Lockfile{Packages: map[string]lockfile.Package{
+		"demo": {Version: "1.0.0", Resolved: first.Resolved, Integrity: "sha512-AAAA"},
+	}}
+	if _, err := sources.Resolve(Parse("demo", repo.url+"#v1.0.0")); err == nil || !strings.Contains(err.Error(), "integrity mismatch") {
+		t.Errorf("Resolve with a different locked integrity: %v, want an integrity mismatch", err)
+	}
+}
+
+func TestGitPackDeterministic(t *testing.T) {
+	repo := newTestRepo(t)
+	first, err := New(nil).Resolve(Parse("demo", repo.url+"#v1.1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neuer Mirror, neuer Lauf: derselbe Commit ergibt dieselbe Integrität
+	t.Setenv("HOME", t.TempDir())
+	second, err := New(nil).Resolve(Parse("demo", repo.url+"#"+repo.commits["1.1.0"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Integrity != second.Integrity {
+		t.Errorf("integrity differs between packs: %s, %s", first.Integrity, second.Integrity)
+	}
+	if first.Integrity == "" || string(first.TarballData()) != string(second.TarballData()) {
+		t.Error("packed tarballs differ")
+	}
+}
+
+func TestGitRejectsOptions(t *testing.T) {
+	for _, raw := range []string{
+		"git+--upload-pack=touch pwned",
+		"git+-oProxyCommand=touch pwned#main",
+		"git+file:///repo.git#--output=pwned",
+	} {
+		if _, err := New(nil).Resolve(Parse("demo", raw)); err == nil || !strings.Contains(err.Error(), "invalid git") {
+			t.Errorf("Resolve(%q) = %v, want an invalid git spec", raw, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"ipm/pkg/platform"
+	"ipm/pkg/types"
+)
+
+// ParseManifest liest die für die Installation relevanten Felder einer package.json.
+func ParseManifest(data []byte) (types.Package, error) {
+	var pkg struct {
+		Name                 string                              `json:"name"`
+		Version              string                              `json:"version"`
+		Dependencies         map[string]string                   `json:"dependencies"`
+		PeerDependencies     map[string]string                   `json:"peerDependencies"`
+		PeerDependenciesMeta map[string]types.PeerDependencyMeta `json:"peerDependenciesMeta"`
+		OptionalDependencies map[string]string                   `json:"optionalDependencies"`
+		Os                   []string                            `json:"os"`
+		Cpu                  []string                            `json:"cpu"`
+		Engines              json.RawMessage                     `json:"engines"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return types.Package{}, fmt.Errorf("failed to parse package.json: %v", err)
+	}
+	if pkg.Name == "" || pkg.Version == "" {
+		return types.Package{}, fmt.Errorf("package.json must contain name and version")
+	}
+	return types.Package{
+		Name:         pkg.Name,
+		Version:      pkg.Version,
+		Deps:         pkg.Dependencies,
+		PeerDeps:     pkg.PeerDependencies,
+		PeerDepsMeta: pkg.PeerDependenciesMeta,
+		OptionalDeps: pkg.OptionalDependencies,
+		Os:           pkg.Os,
+		Cpu:          pkg.Cpu,
+		Engines:      platform.ParseEngines(pkg.Engines),
+	}, nil
+}
+
+// ReadTarballManifest liest package/package.json aus einem gzip-komprimierten Tarball.
+func ReadTarballManifest(tarballData []byte) (types.Package, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(tarballData))
+	if err != nil {
+		return types.Package{}, fmt.Errorf("failed to read gzip: %v", err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return types.Package{}, fmt.Errorf("failed to read tarball: %v", err)
+		}
+		// Nur die package.json an der Wurzel des Pakets, nicht die eines Unterverzeichnisses
+		if strings.TrimPrefix(hdr.Name, "./") == "package/package.json" {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return types.Package{}, fmt.Errorf("failed to read package.json: %v", err)
+			}
+			return ParseManifest(data)
+		}
+	}
+	return types.Package{}, fmt.Errorf("package.json not found in tarball")
+}
@@ -0,0 +1,350 @@
+package source
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"ipm/pkg/lockfile"
+	"ipm/pkg/log"
+	"ipm/pkg/registry"
+	"ipm/pkg/semver"
+	"ipm/pkg/types"
+)
+
+// Kind ist die Quellart einer Abhängigkeitsangabe.
+type Kind int
+
+const (
+	Registry Kind = iota // "^1.2.0", "latest"
+	Alias                // "npm:other@^1"
+	Tarball              // "https://example.com/x.tgz"
+	File                 // "file:../lib" oder "file:../lib.tgz"
+	Link                 // "link:../lib"
+	Git                  // "git+ssh://host/repo.git#v1", "github:user/repo#semver:^1"
+)
+
+// Spec ist eine zerlegte Abhängigkeitsangabe.
+type Spec struct {
+	Name     string // Name unter node_modules; leer, wenn er erst aus package.json hervorgeht
+	Raw      string // ursprüngliche Angabe
+	Kind     Kind
+	Target   string // Alias: Paketname in der Registry
+	Range    string // Registry und Alias: Versionsangabe, Git: semver-Range für Tags
+	Location string // Tarball: URL, File und Link: Pfad relativ zu Base, Git: Repository-URL
+	Ref      string // Git: Branch, Tag oder Commit; leer für HEAD
+	// Base ist das Verzeichnis der package.json, in der die Angabe steht; leer für
+	// das Projekt. Wie bei npm gelten file: und link: relativ dazu.
+	Base string
+
+	err error // ungültige Angabe, gemeldet von Resolve
+}
+
+// ParseIn arbeitet wie Parse für eine Angabe aus der package.json in dir.
+func ParseIn(dir, name, raw string) Spec {
+	spec := Parse(name, raw)
+	spec.Base = dir
+	return spec
+}
+
+// Path liefert den Pfad einer file:- oder link:-Angabe, aufgelöst gegen Base.
+func (s Spec) Path() string {
+	if s.Base == "" || filepath.IsAbs(s.Location) {
+		return s.Location
+	}
+	return filepath.Join(s.Base, s.Location)
+}
+
+// projectPath liefert Path relativ zum Projekt (dem Arbeitsverzeichnis), damit
+// die Lockfile dieselbe Quelle unabhängig vom deklarierenden Paket gleich nennt.
+func (s Spec) projectPath() string {
+	path := s.Path()
+	if s.Base != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			if cwd, err := os.Getwd(); err == nil {
+				if rel, err := filepath.Rel(cwd, abs); err == nil {
+					path = rel
+				}
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// key unterscheidet Angaben im Zwischenspeicher von Resolve; gleiche relative
+// Pfade aus verschiedenen Paketen zeigen auf verschiedene Quellen.
+func (s Spec) key() string {
+	if s.Kind == File || s.Kind == Link {
+		return strings.SplitN(s.Raw, ":", 2)[0] + ":" + s.projectPath()
+	}
+	return s.Raw
+}
+
+// Parse bestimmt die Quellart einer Angabe aus "dependencies" oder der Kommandozeile.
+func Parse(name, raw string) Spec {
+	spec := Spec{Name: name, Raw: raw}
+	switch {
+	case strings.HasPrefix(raw, "npm:"):
+		spec.Kind = Alias
+		target := strings.TrimPrefix(raw, "npm:")
+		spec.Target, spec.Range = target, "latest"
+		if idx := strings.LastIndex(target, "@"); idx > 0 {
+			spec.Target, spec.Range = target[:idx], target[idx+1:]
+		}
+	case strings.HasPrefix(raw, "link:"):
+		spec.Kind = Link
+		spec.Location = strings.TrimPrefix(raw, "link:")
+	case strings.HasPrefix(raw, "file:"):
+		spec.Kind = File
+		spec.Location = strings.TrimPrefix(raw, "file:")
+	case strings.HasPrefix(raw, "git+"), strings.HasPrefix(raw, "git://"), strings.HasPrefix(raw, "github:"):
+		spec.Kind = Git
+		spec.Location, spec.Ref, spec.Range, spec.err = parseGitSpec(raw)
+	case strings.HasPrefix(raw, "http://"), strings.HasPrefix(raw, "https://"):
+		spec.Kind = Tarball
+		spec.Location = raw
+	default:
+		spec.Kind = Registry
+		spec.Range = raw
+	}
+	return spec
+}
+
+// IsRegistry meldet, ob raw eine gewöhnliche Versionsangabe für die Standard-Registry ist.
+func IsRegistry(raw string) bool {
+	return Parse("", raw).Kind == Registry
+}
+
+// Resolved ist eine auf eine feste Quelle festgelegte Angabe.
+type Resolved struct {
+	Spec      Spec
+	Package   types.Package // Metadaten aus package.json bzw. der Registry
+	Resolved  string        // feste Quelle für die Lockfile, z. B. "npm:other@1.2.3" oder die Tarball-URL
+	Integrity string        // SRI-Hash des Tarballs, leer bei Verzeichnissen
+	Dir       string        // absoluter Pfad bei Verzeichnissen (file:, link:), sonst leer
+	tarball   []byte
+}
+
+// Name liefert den Namen unter node_modules.
+func (r *Resolved) Name() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Package.Name
+}
+
+// Tarball liefert den Inhalt eines Tarball-Pakets.
+func (r *Resolved) Tarball() io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(r.tarball))
+}
+
+// TarballData liefert den Tarball als Bytes, z. B. für die Signaturprüfung.
+func (r *Resolved) TarballData() []byte {
+	return r.tarball
+}
+
+// Fetcher legt Angaben einer Quellart fest und lädt deren Inhalt.
+type Fetcher interface {
+	Fetch(spec Spec) (*Resolved, error)
+}
+
+// Sources verteilt Angaben auf die Fetcher ihrer Quellart. Ergebnisse werden je
+// Angabe zwischengespeichert, damit Solver und Installer nur einmal laden.
+type Sources struct {
+	// Locked ist die bestehende Lockfile; ihre Einträge pinnen Aliase und Tarball-Inhalte
+	Locked *lockfile.Lockfile
+
+	fetchers map[Kind]Fetcher
+	resolved map[string]*Resolved
+}
+
+func New(reg registry.Registry) *Sources {
+	client := &http.Client{}
+	return &Sources{
+		fetchers: map[Kind]Fetcher{
+			Alias:   &aliasFetcher{reg: reg},
+			Tarball: &tarballFetcher{client: client},
+			File:    &fileFetcher{},
+			Link:    &linkFetcher{},
+			Git:     &gitFetcher{fetched: make(map[string]bool)},
+		},
+		resolved: make(map[string]*Resolved),
+	}
+}
+
+// Resolve legt spec auf eine feste Quelle fest. Gewöhnliche Registry-Angaben
+// löst weiterhin der Solver auf und werden hier abgelehnt.
+func (s *Sources) Resolve(spec Spec) (*Resolved, error) {
+	if spec.err != nil {
+		return nil, spec.err
+	}
+	fetcher, ok := s.fetchers[spec.Kind]
+	if !ok {
+		return nil, fmt.Errorf("no fetcher for %s", spec.Raw)
+	}
+	spec = s.pin(spec)
+	if res, ok := s.resolved[spec.key()]; ok {
+		return s.withName(res, spec.Name), nil
+	}
+
+	res, err := fetcher.Fetch(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkIntegrity(res); err != nil {
+		return nil, err
+	}
+	log.Debug("Resolved dependency source", map[string]interface{}{
+		"spec":      spec.Raw,
+		"package":   res.Package.Name,
+		"version":   res.Package.Version,
+		"resolved":  res.Resolved,
+		"integrity": res.Integrity,
+	})
+	s.resolved[spec.key()] = res
+	return s.withName(res, spec.Name), nil
+}
+
+// withName liefert res für den Namen, unter dem spec angefordert wurde.
+func (s *Sources) withName(res *Resolved, name string) *Resolved {
+	if res.Spec.Name == name {
+		return res
+	}
+	named := *res
+	named.Spec.Name = name
+	return &named
+}
+
+// pin ersetzt den Range eines Alias bzw. einer "#semver:"-Git-Angabe durch die
+// Version bzw. den Commit aus der Lockfile, solange diese den Range noch erfüllt.
+func (s *Sources) pin(spec Spec) Spec {
+	locked, ok := s.lockedEntry(spec)
+	if !ok {
+		return spec
+	}
+	switch spec.Kind {
+	case Alias:
+		if locked.Resolved != fmt.Sprintf("npm:%s@%s", spec.Target, locked.Version) {
+			return spec
+		}
+		if spec.Range != "latest" && !semver.Satisfies(locked.Version, spec.Range) {
+			return spec
+		}
+		spec.Range = locked.Version
+	case Git:
+		commit := strings.TrimPrefix(locked.Resolved, gitBase(spec.Raw)+"#")
+		if spec.Range == "" || commit == locked.Resolved || !semver.Satisfies(locked.Version, spec.Range) {
+			return spec
+		}
+		spec.Range, spec.Ref = "", commit
+	default:
+		return spec
+	}
+	log.Debug("Using locked version", map[string]interface{}{
+		"package":  spec.Name,
+		"spec":     spec.Raw,
+		"resolved": locked.Resolved,
+	})
+	return spec
+}
+
+// lockedEntry sucht den Lockfile-Eintrag zu spec. Ohne Namen (z. B. "ipm install
+// git+file:///repo.git") wird er über die Repository-Angabe gefunden.
+func (s *Sources) lockedEntry(spec Spec) (lockfile.Package, bool) {
+	if s.Locked == nil {
+		return lockfile.Package{}, false
+	}
+	if spec.Name != "" {
+		locked, ok := s.Locked.Packages[spec.Name]
+		return locked, ok
+	}
+	if spec.Kind != Git {
+		return lockfile.Package{}, false
+	}
+	for _, name := range sortedNames(s.Locked.Packages) {
+		if locked := s.Locked.Packages[name]; strings.HasPrefix(locked.Resolved, gitBase(spec.Raw)+"#") {
+			return locked, true
+		}
+	}
+	return lockfile.Package{}, false
+}
+
+func sortedNames(packages map[string]lockfile.Package) []string {
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkIntegrity vergleicht den Inhalt mit der Integrität, die die Lockfile für dieselbe Quelle festhält.
+func (s *Sources) checkIntegrity(res *Resolved) error {
+	if s.Locked == nil || res.Integrity == "" {
+		return nil
+	}
+	for name, locked := range s.Locked.Packages {
+		if locked.Resolved != res.Resolved || locked.Integrity == "" {
+			continue
+		}
+		if locked.Integrity != res.Integrity {
+			return fmt.Errorf("integrity mismatch for %s (%s): lockfile has %s, got %s", name, res.Resolved, locked.Integrity, res.Integrity)
+		}
+	}
+	return nil
+}
+
+// integrityAlgorithms sind die unterstützten SRI-Algorithmen, der stärkste zuerst.
+var integrityAlgorithms = []struct {
+	name string
+	sum  func([]byte) []byte
+}{
+	{"sha512", func(data []byte) []byte { sum := sha512.Sum512(data); return sum[:] }},
+	{"sha384", func(data []byte) []byte { sum := sha512.Sum384(data); return sum[:] }},
+	{"sha256", func(data []byte) []byte { sum := sha256.Sum256(data); return sum[:] }},
+	{"sha1", func(data []byte) []byte { sum := sha1.Sum(data); return sum[:] }},
+}
+
+// CheckIntegrity prüft data gegen einen SRI-Wert wie "sha512-…" oder "sha1-…".
+// Enthält er mehrere Hashes, zählt wie bei npm der stärkste unterstützte
+// Algorithmus; ohne unterstützten Algorithmus ist das ein Fehler.
+func CheckIntegrity(data []byte, integrity string) error {
+	hashes := map[string][]string{}
+	for _, token := range strings.Fields(integrity) {
+		if idx := strings.Index(token, "?"); idx >= 0 {
+			token = token[:idx]
+		}
+		if algorithm, digest, ok := strings.Cut(token, "-"); ok {
+			hashes[algorithm] = append(hashes[algorithm], digest)
+		}
+	}
+	for _, algorithm := range integrityAlgorithms {
+		digests, ok := hashes[algorithm.name]
+		if !ok {
+			continue
+		}
+		actual := base64.StdEncoding.EncodeToString(algorithm.sum(data))
+		for _, digest := range digests {
+			if digest == actual {
+				return nil
+			}
+		}
+		return fmt.Errorf("integrity mismatch (%s-%s, expected %s)", algorithm.name, actual, integrity)
+	}
+	return fmt.Errorf("integrity %q uses no supported algorithm (sha512, sha384, sha256, sha1)", integrity)
+}
+
+// Integrity liefert den SRI-Hash (sha512) von data, wie ihn npm verwendet.
+func Integrity(data []byte) string {
+	sum := sha512.Sum512(data)
+	return "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+}
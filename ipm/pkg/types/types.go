@@ -1,7 +1,39 @@
 package types
 
 type Package struct {
-    Name    string
-    Version string
-    Deps    map[string]string // z. B. "statuses": "~1.3.1"
-}
\ No newline at end of file
+    Name         string
+    Version      string
+    Deps         map[string]string             // z. B. "statuses": "~1.3.1"
+    PeerDeps     map[string]string             // vom Host erwartete Pakete, z. B. "plc-compiler": "^2.0.0"
+    PeerDepsMeta map[string]PeerDependencyMeta // peerDependenciesMeta
+    OptionalDeps map[string]string             // dürfen fehlschlagen, ohne die Installation abzubrechen
+    Os           []string                      // z. B. ["linux", "win32"] oder ["!darwin"]
+    Cpu          []string                      // z. B. ["x64", "arm64"]
+    Engines      map[string]string             // z. B. "node": ">=18"
+    Deprecated   string                        // Deprecation-Hinweis aus der Registry, leer wenn aktuell
+    Tarball      string                        // dist.tarball aus der Registry, leer bei anderen Quellen
+    Integrity    string                        // dist.integrity (SRI), von der Registry signiert
+    Signatures   []RegistrySignature           // dist.signatures der npm-Registry
+    Attestations *Attestations                 // dist.attestations, nil ohne Provenance
+}
+
+// PeerDependencyMeta entspricht einem Eintrag in peerDependenciesMeta.
+type PeerDependencyMeta struct {
+    Optional bool
+}
+
+// RegistrySignature ist ein Eintrag in dist.signatures: eine Signatur der Registry
+// über "<name>@<version>:<integrity>".
+type RegistrySignature struct {
+    KeyID string `json:"keyid"`
+    Sig   string `json:"sig"`
+}
+
+// Attestations entspricht dist.attestations: wo das Bundle liegt und welche
+// Provenance es enthält.
+type Attestations struct {
+    URL        string `json:"url"`
+    Provenance struct {
+        PredicateType string `json:"predicateType"`
+    } `json:"provenance"`
+}